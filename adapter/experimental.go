@@ -3,13 +3,77 @@ package adapter
 import (
 	"context"
 	"net"
+	"time"
 
+	"github.com/sagernet/sing-box/common/urltest"
 	N "github.com/sagernet/sing/common/network"
 )
 
 type ClashServer interface {
 	Service
 	TrafficController
+	DNSQueryLogger
+	HistoryStorage() *urltest.HistoryStorage
+}
+
+type CacheFile interface {
+	Service
+	FakeIPStorage
+	LoadSelected(group string) string
+	StoreSelected(group string, selected string) error
+	LoadURLTestHistory(storage *urltest.HistoryStorage) error
+	StoreURLTestHistory(storage *urltest.HistoryStorage) error
+	LoadRuleSet(tag string) *SavedRemoteContent
+	SaveRuleSet(tag string, set *SavedRemoteContent) error
+	LoadOutboundProvider(tag string) *SavedRemoteContent
+	SaveOutboundProvider(tag string, content *SavedRemoteContent) error
+	LoadDNSCache() []*SavedDNSResponse
+	StoreDNSCache(responses []*SavedDNSResponse) error
+}
+
+type SavedRemoteContent struct {
+	Content     []byte    `json:"content"`
+	LastUpdated time.Time `json:"last_updated"`
+	LastEtag    string    `json:"last_etag,omitempty"`
+}
+
+type SavedDNSResponse struct {
+	Transport string    `json:"transport"`
+	Response  []byte    `json:"response"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpireAt  time.Time `json:"expire_at"`
+}
+
+type DNSQueryLogger interface {
+	LogDNSQuery(query *DNSQuery)
+}
+
+// DNSQuery is a record of the query log.
+type DNSQuery struct {
+	Time      time.Time `json:"time"`
+	Domain    string    `json:"domain"`
+	QueryType string    `json:"query_type"`
+	Client    string    `json:"client,omitempty"`
+	Inbound   string    `json:"inbound,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Transport string    `json:"transport,omitempty"`
+	RCode     string    `json:"rcode"`
+	Answers   []string  `json:"answers,omitempty"`
+	Latency   int64     `json:"latency"`
+	CacheHit  bool      `json:"cache_hit"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type dnsQueryContextKey struct{}
+
+// WithDNSQuery returns a context for Router.Exchange to fill the record of the query in.
+func WithDNSQuery(ctx context.Context, query *DNSQuery) context.Context {
+	return context.WithValue(ctx, (*dnsQueryContextKey)(nil), query)
+}
+
+func DNSQueryFromContext(ctx context.Context) *DNSQuery {
+	query, _ := ctx.Value((*dnsQueryContextKey)(nil)).(*DNSQuery)
+	return query
 }
 
 type Tracker interface {
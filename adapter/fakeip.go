@@ -0,0 +1,36 @@
+package adapter
+
+import (
+	"net/netip"
+
+	"github.com/sagernet/sing-dns"
+)
+
+type FakeIPStore interface {
+	Service
+	Contains(address netip.Addr) bool
+	Create(domain string, isIPv6 bool) (netip.Addr, error)
+	Lookup(address netip.Addr) (string, bool)
+	Reset() error
+}
+
+type FakeIPStorage interface {
+	FakeIPMetadata() *FakeIPMetadata
+	FakeIPSaveMetadata(metadata *FakeIPMetadata) error
+	FakeIPStore(address netip.Addr, domain string, metadata *FakeIPMetadata) error
+	FakeIPLoad(address netip.Addr) (string, bool)
+	FakeIPLoadDomain(domain string, isIPv6 bool) (netip.Addr, bool)
+	FakeIPReset() error
+}
+
+type FakeIPTransport interface {
+	dns.Transport
+	Store() FakeIPStore
+}
+
+type FakeIPMetadata struct {
+	Inet4Range   netip.Prefix `json:"inet4_range"`
+	Inet6Range   netip.Prefix `json:"inet6_range"`
+	Inet4Current netip.Addr   `json:"inet4_current"`
+	Inet6Current netip.Addr   `json:"inet6_current"`
+}
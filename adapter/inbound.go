@@ -28,6 +28,7 @@ type InboundContext struct {
 	Inbound     string
 	InboundType string
 	IPVersion   int
+	QueryType   uint16
 	Network     string
 	Source      M.Socksaddr
 	Destination M.Socksaddr
@@ -48,6 +49,8 @@ type InboundContext struct {
 	SourceGeoIPCode          string
 	GeoIPCode                string
 	ProcessInfo              *process.Info
+	FakeIP                   bool
+	ReverseMapping           bool
 }
 
 type inboundContextKey struct{}
@@ -0,0 +1,20 @@
+package adapter
+
+import (
+	"context"
+	"time"
+)
+
+type OutboundProvider interface {
+	Service
+	Tag() string
+	Type() string
+	Outbounds() []Outbound
+	Outbound(tag string) (Outbound, bool)
+	UpdatedAt() time.Time
+	Update(ctx context.Context) error
+	HealthCheck(ctx context.Context) (map[string]uint16, error)
+	RegisterCallback(callback OutboundProviderUpdateCallback)
+}
+
+type OutboundProviderUpdateCallback = func(provider OutboundProvider)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/netip"
+	"time"
 
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-dns"
@@ -20,16 +21,24 @@ type Router interface {
 	Outbounds() []Outbound
 	Outbound(tag string) (Outbound, bool)
 	DefaultOutbound(network string) Outbound
+	OutboundProvider(tag string) (OutboundProvider, bool)
+	OutboundProviders() []OutboundProvider
 
 	RouteConnection(ctx context.Context, conn net.Conn, metadata InboundContext) error
 	RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error
 
+	FakeIPStore() FakeIPStore
+
 	GeoIPReader() *geoip.Reader
 	LoadGeosite(code string) (Rule, error)
 
+	RuleSet(tag string) (RuleSet, bool)
+	RuleSets() []RuleSet
+
 	Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error)
 	Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error)
 	LookupDefault(ctx context.Context, domain string) ([]netip.Addr, error)
+	DNS64Prefix() netip.Prefix
 
 	InterfaceBindManager() control.BindManager
 	DefaultInterface() string
@@ -39,7 +48,10 @@ type Router interface {
 	InterfaceMonitor() tun.DefaultInterfaceMonitor
 	PackageManager() tun.PackageManager
 	Rules() []Rule
-	SetTrafficController(controller TrafficController)
+	ClashServer() ClashServer
+	SetClashServer(controller ClashServer)
+	CacheFile() CacheFile
+	SetCacheFile(cacheFile CacheFile)
 }
 
 type Rule interface {
@@ -54,4 +66,18 @@ type Rule interface {
 type DNSRule interface {
 	Rule
 	DisableCache() bool
+	// ResponseAction returns the action for responses with unmatched addresses, or empty if the rule has no response items.
+	ResponseAction() string
+	MatchAddress(address netip.Addr) bool
+}
+
+type RuleSet interface {
+	Service
+	Tag() string
+	Type() string
+	Match(metadata *InboundContext) bool
+	RuleCount() int
+	ContainsProcessRule() bool
+	UpdatedAt() time.Time
+	Update(ctx context.Context) error
 }
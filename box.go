@@ -6,14 +6,15 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/experimental/cachefile"
 	"github.com/sagernet/sing-box/inbound"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
-	"github.com/sagernet/sing-box/outbound"
 	"github.com/sagernet/sing-box/route"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -23,15 +24,21 @@ import (
 var _ adapter.Service = (*Box)(nil)
 
 type Box struct {
-	createdAt   time.Time
-	router      adapter.Router
-	inbounds    []adapter.Inbound
-	outbounds   []adapter.Outbound
-	logFactory  log.Factory
-	logger      log.ContextLogger
-	logFile     *os.File
-	clashServer adapter.ClashServer
-	done        chan struct{}
+	createdAt      time.Time
+	ctx            context.Context
+	options        option.Options
+	access         sync.Mutex
+	router         *route.ReloadableRouter
+	generation     *generation
+	retired        []*generation
+	inbounds       []adapter.Inbound
+	inboundOptions []option.Inbound
+	logFactory     log.Factory
+	logger         log.ContextLogger
+	logFile        *os.File
+	clashServer    adapter.ClashServer
+	cacheFile      adapter.CacheFile
+	done           chan struct{}
 }
 
 func New(ctx context.Context, options option.Options) (*Box, error) {
@@ -88,62 +95,33 @@ func New(ctx context.Context, options option.Options) (*Box, error) {
 		}
 	}
 
-	router, err := route.NewRouter(
-		ctx,
-		logFactory.NewLogger("router"),
-		logFactory.NewLogger("dns"),
-		common.PtrValueOrDefault(options.Route),
-		common.PtrValueOrDefault(options.DNS),
-		options.Inbounds,
-	)
+	var cacheFile adapter.CacheFile
+	if options.Experimental != nil && options.Experimental.CacheFile != nil && options.Experimental.CacheFile.Enabled {
+		cachePath := options.Experimental.CacheFile.Path
+		if cachePath == "" {
+			cachePath = "cache.db"
+		}
+		var err error
+		cacheFile, err = cachefile.Open(cachePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	current, err := newGeneration(ctx, logFactory, options, cacheFile, nil)
 	if err != nil {
-		return nil, E.Cause(err, "parse route options")
+		return nil, err
 	}
+	router := route.NewReloadableRouter(current.router)
 	inbounds := make([]adapter.Inbound, 0, len(options.Inbounds))
-	outbounds := make([]adapter.Outbound, 0, len(options.Outbounds))
 	for i, inboundOptions := range options.Inbounds {
 		var in adapter.Inbound
-		var tag string
-		if inboundOptions.Tag != "" {
-			tag = inboundOptions.Tag
-		} else {
-			tag = F.ToString(i)
-		}
-		in, err = inbound.New(
-			ctx,
-			router,
-			logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
-			inboundOptions,
-		)
+		in, err = newInbound(ctx, router, logFactory, i, inboundOptions)
 		if err != nil {
-			return nil, E.Cause(err, "parse inbound[", i, "]")
+			return nil, err
 		}
 		inbounds = append(inbounds, in)
 	}
-	for i, outboundOptions := range options.Outbounds {
-		var out adapter.Outbound
-		var tag string
-		if outboundOptions.Tag != "" {
-			tag = outboundOptions.Tag
-		} else {
-			tag = F.ToString(i)
-		}
-		out, err = outbound.New(
-			ctx,
-			router,
-			logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
-			outboundOptions)
-		if err != nil {
-			return nil, E.Cause(err, "parse outbound[", i, "]")
-		}
-		outbounds = append(outbounds, out)
-	}
-	err = router.Initialize(inbounds, outbounds, func() adapter.Outbound {
-		out, oErr := outbound.New(ctx, router, logFactory.NewLogger("outbound/direct"), option.Outbound{Type: "direct", Tag: "default"})
-		common.Must(oErr)
-		outbounds = append(outbounds, out)
-		return out
-	})
+	err = current.initialize(ctx, logFactory, inbounds)
 	if err != nil {
 		return nil, err
 	}
@@ -154,23 +132,55 @@ func New(ctx context.Context, options option.Options) (*Box, error) {
 		if err != nil {
 			return nil, E.Cause(err, "create clash api server")
 		}
-		router.SetTrafficController(clashServer)
+		current.router.SetClashServer(clashServer)
 	}
 	return &Box{
-		router:      router,
-		inbounds:    inbounds,
-		outbounds:   outbounds,
-		createdAt:   createdAt,
-		logFactory:  logFactory,
-		logger:      logFactory.NewLogger(""),
-		logFile:     logFile,
-		clashServer: clashServer,
-		done:        make(chan struct{}),
+		router:         router,
+		ctx:            ctx,
+		options:        options,
+		generation:     current,
+		inbounds:       inbounds,
+		inboundOptions: options.Inbounds,
+		createdAt:      createdAt,
+		logFactory:     logFactory,
+		logger:         logFactory.NewLogger(""),
+		logFile:        logFile,
+		clashServer:    clashServer,
+		cacheFile:      cacheFile,
+		done:           make(chan struct{}),
 	}, nil
 }
 
+func newInbound(ctx context.Context, router adapter.Router, logFactory log.Factory, index int, options option.Inbound) (adapter.Inbound, error) {
+	var tag string
+	if options.Tag != "" {
+		tag = options.Tag
+	} else {
+		tag = F.ToString(index)
+	}
+	in, err := inbound.New(
+		ctx,
+		router,
+		logFactory.NewLogger(F.ToString("inbound/", options.Type, "[", tag, "]")),
+		options,
+	)
+	if err != nil {
+		return nil, E.Cause(err, "parse inbound[", index, "]")
+	}
+	return in, nil
+}
+
 func (s *Box) Start() error {
-	err := s.start()
+	return s.closeOnError(s.start())
+}
+
+// PreStart starts outbound providers, outbounds and the router, without starting inbounds.
+// It is used to inspect the routing of a configuration, and must not be followed by Start.
+func (s *Box) PreStart() error {
+	return s.closeOnError(s.generation.start())
+}
+
+func (s *Box) closeOnError(err error) error {
 	if err != nil {
 		// TODO: remove catch error
 		defer func() {
@@ -187,21 +197,7 @@ func (s *Box) Start() error {
 }
 
 func (s *Box) start() error {
-	for i, out := range s.outbounds {
-		if starter, isStarter := out.(common.Starter); isStarter {
-			err := starter.Start()
-			if err != nil {
-				var tag string
-				if out.Tag() == "" {
-					tag = F.ToString(i)
-				} else {
-					tag = out.Tag()
-				}
-				return E.Cause(err, "initialize outbound/", out.Type(), "[", tag, "]")
-			}
-		}
-	}
-	err := s.router.Start()
+	err := s.generation.start()
 	if err != nil {
 		return err
 	}
@@ -234,16 +230,24 @@ func (s *Box) Close() error {
 	default:
 		close(s.done)
 	}
+	s.access.Lock()
+	defer s.access.Unlock()
 	for _, in := range s.inbounds {
 		in.Close()
 	}
-	for _, out := range s.outbounds {
-		common.Close(out)
+	s.generation.Close()
+	for _, retired := range s.retired {
+		retired.Close()
 	}
+	s.retired = nil
 	return common.Close(
-		s.router,
 		s.logFactory,
 		s.clashServer,
+		s.cacheFile,
 		common.PtrOrNil(s.logFile),
 	)
 }
+
+func (s *Box) Router() adapter.Router {
+	return s.router.Current()
+}
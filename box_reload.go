@@ -0,0 +1,305 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing-box/provider"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// ErrRestartRequired is returned by Reload if the changed options can only be applied by recreating the box.
+var ErrRestartRequired = E.New("restart required")
+
+// generation holds the components that are recreated on reload.
+type generation struct {
+	router            *route.Router
+	outbounds         []adapter.Outbound
+	outboundProviders []adapter.OutboundProvider
+}
+
+func newGeneration(ctx context.Context, logFactory log.Factory, options option.Options, cacheFile adapter.CacheFile, previous *generation) (*generation, error) {
+	var previousRouter *route.Router
+	if previous != nil {
+		previousRouter = previous.router
+	}
+	router, err := route.NewRouter(
+		ctx,
+		logFactory.NewLogger("router"),
+		logFactory.NewLogger("dns"),
+		common.PtrValueOrDefault(options.Route),
+		common.PtrValueOrDefault(options.DNS),
+		options.Inbounds,
+		previousRouter,
+	)
+	if err != nil {
+		return nil, E.Cause(err, "parse route options")
+	}
+	if cacheFile != nil {
+		router.SetCacheFile(cacheFile)
+	}
+	g := &generation{
+		router:            router,
+		outbounds:         make([]adapter.Outbound, 0, len(options.Outbounds)),
+		outboundProviders: make([]adapter.OutboundProvider, 0, len(options.OutboundProviders)),
+	}
+	for i, outboundOptions := range options.Outbounds {
+		var out adapter.Outbound
+		var tag string
+		if outboundOptions.Tag != "" {
+			tag = outboundOptions.Tag
+		} else {
+			tag = F.ToString(i)
+		}
+		out, err = outbound.New(
+			ctx,
+			router,
+			logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
+			outboundOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse outbound[", i, "]")
+		}
+		g.outbounds = append(g.outbounds, out)
+	}
+	for i, providerOptions := range options.OutboundProviders {
+		var outboundProvider adapter.OutboundProvider
+		outboundProvider, err = provider.New(ctx, router, logFactory, providerOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse outbound provider[", i, "]")
+		}
+		g.outboundProviders = append(g.outboundProviders, outboundProvider)
+	}
+	return g, nil
+}
+
+func (g *generation) initialize(ctx context.Context, logFactory log.Factory, inbounds []adapter.Inbound) error {
+	return g.router.Initialize(inbounds, g.outbounds, g.outboundProviders, func() adapter.Outbound {
+		out, oErr := outbound.New(ctx, g.router, logFactory.NewLogger("outbound/direct"), option.Outbound{Type: "direct", Tag: "default"})
+		common.Must(oErr)
+		g.outbounds = append(g.outbounds, out)
+		return out
+	})
+}
+
+func (g *generation) start() error {
+	for i, out := range g.outbounds {
+		if starter, isStarter := out.(common.Starter); isStarter {
+			err := starter.Start()
+			if err != nil {
+				var tag string
+				if out.Tag() == "" {
+					tag = F.ToString(i)
+				} else {
+					tag = out.Tag()
+				}
+				return E.Cause(err, "initialize outbound/", out.Type(), "[", tag, "]")
+			}
+		}
+	}
+	// providers may download through groups, which are ready once outbounds are started
+	for _, outboundProvider := range g.outboundProviders {
+		err := outboundProvider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize outbound provider[", outboundProvider.Tag(), "]")
+		}
+	}
+	return g.router.Start()
+}
+
+func (g *generation) Close() error {
+	for _, out := range g.outbounds {
+		common.Close(out)
+	}
+	for _, outboundProvider := range g.outboundProviders {
+		outboundProvider.Close()
+	}
+	return common.Close(g.router)
+}
+
+// Reload applies new options without recreating the box.
+//
+// Rules, DNS, outbounds and outbound providers are recreated and swapped in, while inbounds with unchanged
+// options keep their listeners. Connections routed before the reload finish on the previous outbounds,
+// which are closed once all of them are done. The fakeip store, DNS cache and query log are carried over
+// if their options are unchanged, without cached answers of DNS servers changed under the same tag.
+//
+// If a new inbound fails to start, the replaced inbounds are restored and the previous options stay in use.
+//
+// ErrRestartRequired is returned without changing anything if log or experimental options changed.
+func (s *Box) Reload(options option.Options) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	select {
+	case <-s.done:
+		return E.New("reload closed service")
+	default:
+	}
+	if !sameOptions(s.options.Log, options.Log) || !sameOptions(s.options.Experimental, options.Experimental) {
+		return ErrRestartRequired
+	}
+	next, err := newGeneration(s.ctx, s.logFactory, options, s.cacheFile, s.generation)
+	if err != nil {
+		return err
+	}
+	if s.clashServer != nil {
+		next.router.SetClashServer(s.clashServer)
+	}
+	oldInbounds := make(map[string]adapter.Inbound)
+	oldInboundOptions := make(map[string]option.Inbound)
+	for i, in := range s.inbounds {
+		key := inboundKey(i, s.inboundOptions[i])
+		oldInbounds[key] = in
+		oldInboundOptions[key] = s.inboundOptions[i]
+	}
+	inbounds := make([]adapter.Inbound, 0, len(options.Inbounds))
+	var newInbounds []adapter.Inbound
+	keptInbounds := make(map[adapter.Inbound]bool)
+	for i, inboundOptions := range options.Inbounds {
+		key := inboundKey(i, inboundOptions)
+		if oldInbound, loaded := oldInbounds[key]; loaded && sameOptions(oldInboundOptions[key], inboundOptions) {
+			inbounds = append(inbounds, oldInbound)
+			keptInbounds[oldInbound] = true
+			continue
+		}
+		var in adapter.Inbound
+		in, err = newInbound(s.ctx, s.router, s.logFactory, i, inboundOptions)
+		if err != nil {
+			next.Close()
+			return err
+		}
+		inbounds = append(inbounds, in)
+		newInbounds = append(newInbounds, in)
+	}
+	err = next.initialize(s.ctx, s.logFactory, inbounds)
+	if err != nil {
+		next.Close()
+		return err
+	}
+	err = next.start()
+	if err != nil {
+		next.Close()
+		return err
+	}
+	var closedInbounds []int
+	for i, in := range s.inbounds {
+		if !keptInbounds[in] {
+			in.Close()
+			closedInbounds = append(closedInbounds, i)
+		}
+	}
+	for _, in := range newInbounds {
+		err = in.Start()
+		if err != nil {
+			err = E.Cause(err, "initialize inbound/", in.Type(), "[", in.Tag(), "]")
+			return E.Errors(err, s.rollbackInbounds(newInbounds, closedInbounds, next))
+		}
+	}
+	restoreSelected(s.generation, next)
+	previous := s.generation
+	next.router.TakeOver(previous.router)
+	s.router.Swap(next.router)
+	s.generation = next
+	s.inbounds = inbounds
+	s.inboundOptions = options.Inbounds
+	s.options = options
+	s.retire(previous)
+	s.logger.Info("sing-box reloaded, ", len(keptInbounds), " of ", len(inbounds), " inbounds kept")
+	return nil
+}
+
+// rollbackInbounds restores the inbounds closed by a failed reload, which are recreated from their options.
+func (s *Box) rollbackInbounds(newInbounds []adapter.Inbound, closedInbounds []int, next *generation) error {
+	for _, in := range newInbounds {
+		in.Close()
+	}
+	next.Close()
+	var errors []error
+	for _, i := range closedInbounds {
+		in, err := newInbound(s.ctx, s.router, s.logFactory, i, s.inboundOptions[i])
+		if err == nil {
+			err = in.Start()
+		}
+		if err != nil {
+			errors = append(errors, E.Cause(err, "restore inbound[", i, "]"))
+			continue
+		}
+		s.inbounds[i] = in
+		s.generation.router.ReplaceInbound(in)
+	}
+	return E.Errors(errors...)
+}
+
+// retire closes the previous generation once connections routed by it are closed.
+func (s *Box) retire(previous *generation) {
+	s.retired = append(s.retired, previous)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for previous.router.ActiveConnections() > 0 {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+		s.access.Lock()
+		defer s.access.Unlock()
+		for i, retired := range s.retired {
+			if retired == previous {
+				s.retired = append(s.retired[:i], s.retired[i+1:]...)
+				err := previous.Close()
+				if err != nil {
+					s.logger.Error(E.Cause(err, "close previous router"))
+				}
+				return
+			}
+		}
+	}()
+}
+
+// restoreSelected keeps the manual choices of selectors that exist in both generations.
+func restoreSelected(previous *generation, next *generation) {
+	for _, detour := range next.outbounds {
+		selector, isSelector := detour.(*outbound.Selector)
+		if !isSelector {
+			continue
+		}
+		previousDetour, loaded := previous.router.Outbound(detour.Tag())
+		if !loaded {
+			continue
+		}
+		previousSelector, isSelector := previousDetour.(*outbound.Selector)
+		if !isSelector {
+			continue
+		}
+		selector.SelectOutbound(previousSelector.Now())
+	}
+}
+
+func inboundKey(index int, options option.Inbound) string {
+	if options.Tag != "" {
+		return options.Tag
+	}
+	return F.ToString(index)
+}
+
+func sameOptions(a any, b any) bool {
+	aContent, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bContent, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aContent, bContent)
+}
@@ -30,6 +30,10 @@ func check() error {
 	if err != nil {
 		return err
 	}
+	if options.Experimental != nil && options.Experimental.CacheFile != nil {
+		// the cache file is locked while the service is running
+		options.Experimental.CacheFile.Enabled = false
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	_, err = box.New(ctx, options)
 	cancel()
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/process"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagRouteInbound     string
+	flagRouteNetwork     string
+	flagRouteDomain      string
+	flagRouteIP          string
+	flagRoutePort        uint16
+	flagRouteSource      string
+	flagRouteProtocol    string
+	flagRouteProcessName string
+	flagRouteProcessPath string
+	flagRoutePackageName string
+	flagRouteUser        string
+	flagRouteUserID      int32
+	flagRouteAuthUser    string
+	flagRouteResolve     bool
+	flagRouteOnline      bool
+)
+
+var commandRoute = &cobra.Command{
+	Use:   "route",
+	Short: "Explain how a connection is routed",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := explainRoute()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	commandRoute.Flags().StringVar(&flagRouteInbound, "inbound", "", "inbound tag")
+	commandRoute.Flags().StringVarP(&flagRouteNetwork, "network", "n", N.NetworkTCP, "network: tcp or udp")
+	commandRoute.Flags().StringVarP(&flagRouteDomain, "domain", "d", "", "destination domain")
+	commandRoute.Flags().StringVarP(&flagRouteIP, "ip", "i", "", "destination IP address")
+	commandRoute.Flags().Uint16VarP(&flagRoutePort, "port", "p", 443, "destination port")
+	commandRoute.Flags().StringVarP(&flagRouteSource, "source", "s", "", "source address, with optional port")
+	commandRoute.Flags().StringVar(&flagRouteProtocol, "protocol", "", "sniffed protocol")
+	commandRoute.Flags().StringVar(&flagRouteProcessName, "process-name", "", "process name")
+	commandRoute.Flags().StringVar(&flagRouteProcessPath, "process-path", "", "process path")
+	commandRoute.Flags().StringVar(&flagRoutePackageName, "package-name", "", "android package name")
+	commandRoute.Flags().StringVar(&flagRouteUser, "user", "", "process user name")
+	commandRoute.Flags().Int32Var(&flagRouteUserID, "user-id", -1, "process user id")
+	commandRoute.Flags().StringVar(&flagRouteAuthUser, "auth-user", "", "inbound authentication user")
+	commandRoute.Flags().BoolVar(&flagRouteResolve, "resolve", false, "resolve the destination domain before matching ip rules")
+	commandRoute.Flags().BoolVar(&flagRouteOnline, "online", false, "start outbounds, outbound providers and remote rule-sets to follow groups")
+	mainCommand.AddCommand(commandRoute)
+}
+
+func explainRoute() error {
+	options, err := readConfig()
+	if err != nil {
+		return err
+	}
+	metadata, err := routeMetadata(options)
+	if err != nil {
+		return err
+	}
+	if options.Experimental != nil && options.Experimental.CacheFile != nil {
+		// the cache file is locked while the service is running
+		options.Experimental.CacheFile.Enabled = false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance, err := box.New(ctx, options)
+	if err != nil {
+		return E.Cause(err, "create service")
+	}
+	defer instance.Close()
+	router, isRouter := instance.Router().(*route.Router)
+	if !isRouter {
+		return E.New("unexpected router type")
+	}
+	if flagRouteOnline {
+		err = instance.PreStart()
+	} else {
+		err = router.PrepareExplain()
+		for _, ruleSet := range router.RuleSets() {
+			if ruleSet.Type() == C.RuleSetTypeRemote {
+				log.Warn("remote rule-set ", ruleSet.Tag(), " is not loaded without --online")
+			}
+		}
+	}
+	if err != nil {
+		return E.Cause(err, "start service")
+	}
+	explanation, err := router.Explain(ctx, metadata, flagRouteResolve)
+	if err != nil {
+		return err
+	}
+	printExplanation(explanation)
+	return nil
+}
+
+func routeMetadata(options option.Options) (adapter.InboundContext, error) {
+	var metadata adapter.InboundContext
+	switch flagRouteNetwork {
+	case N.NetworkTCP, N.NetworkUDP:
+		metadata.Network = flagRouteNetwork
+	default:
+		return metadata, E.New("unknown network: ", flagRouteNetwork)
+	}
+	if flagRouteInbound != "" {
+		metadata.Inbound = flagRouteInbound
+		for _, inboundOptions := range options.Inbounds {
+			if inboundOptions.Tag == flagRouteInbound {
+				metadata.InboundType = inboundOptions.Type
+			}
+		}
+		if metadata.InboundType == "" {
+			return metadata, E.New("inbound not found: ", flagRouteInbound)
+		}
+	}
+	switch {
+	case flagRouteIP != "":
+		address, err := netip.ParseAddr(flagRouteIP)
+		if err != nil {
+			return metadata, E.Cause(err, "parse destination ip")
+		}
+		metadata.Destination = M.SocksaddrFrom(address, flagRoutePort)
+		metadata.Domain = flagRouteDomain
+	case flagRouteDomain != "":
+		metadata.Destination = M.Socksaddr{
+			Fqdn: flagRouteDomain,
+			Port: flagRoutePort,
+		}
+	default:
+		return metadata, E.New("missing destination domain or ip")
+	}
+	if flagRouteSource != "" {
+		source := M.ParseSocksaddr(flagRouteSource)
+		if !source.IsIP() {
+			return metadata, E.New("invalid source address: ", flagRouteSource)
+		}
+		metadata.Source = source
+	}
+	metadata.Protocol = flagRouteProtocol
+	metadata.User = flagRouteAuthUser
+	if flagRouteProcessName != "" || flagRouteProcessPath != "" || flagRoutePackageName != "" || flagRouteUser != "" || flagRouteUserID != -1 {
+		processPath := flagRouteProcessPath
+		if processPath == "" {
+			processPath = flagRouteProcessName
+		}
+		metadata.ProcessInfo = &process.Info{
+			ProcessPath: processPath,
+			PackageName: flagRoutePackageName,
+			User:        flagRouteUser,
+			UserId:      flagRouteUserID,
+		}
+	}
+	return metadata, nil
+}
+
+func printExplanation(explanation *route.Explanation) {
+	metadata := explanation.Metadata
+	if metadata.Inbound != "" {
+		fmt.Println("inbound:", metadata.InboundType+"["+metadata.Inbound+"]")
+	}
+	fmt.Println("network:", metadata.Network)
+	fmt.Println("destination:", metadata.Destination.String())
+	if metadata.Domain != "" {
+		fmt.Println("domain:", metadata.Domain)
+	}
+	if len(metadata.DestinationAddresses) > 0 {
+		addresses := make([]string, 0, len(metadata.DestinationAddresses))
+		for _, address := range metadata.DestinationAddresses {
+			addresses = append(addresses, address.String())
+		}
+		fmt.Println("resolved:", strings.Join(addresses, " "))
+	}
+	fmt.Println()
+	if explanation.DNSServer != "" {
+		fmt.Println("dns rules:")
+		printExplainedRules(explanation.DNSRules)
+		if explanation.MatchedDNSRule != nil || explanation.DNSServer == "hosts" {
+			fmt.Println("dns server:", explanation.DNSServer)
+		} else {
+			fmt.Println("dns server:", explanation.DNSServer, "(final)")
+		}
+		fmt.Println()
+	}
+	fmt.Println("rules:")
+	printExplainedRules(explanation.Rules)
+	chain := make([]string, 0, len(explanation.OutboundChain))
+	for _, detour := range explanation.OutboundChain {
+		chain = append(chain, detour.Type()+"["+detour.Tag()+"]")
+	}
+	if explanation.MatchedRule != nil {
+		fmt.Println("outbound:", strings.Join(chain, " => "))
+	} else {
+		fmt.Println("outbound:", strings.Join(chain, " => "), "(final)")
+	}
+}
+
+func printExplainedRules(rules []route.ExplainedRule) {
+	if len(rules) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+	for _, rule := range rules {
+		var result string
+		if rule.Matched {
+			result = "matched"
+		} else {
+			result = "not matched"
+		}
+		fmt.Printf("  [%d] %s => %s: %s\n", rule.Index, rule.Rule.String(), rule.Rule.Outbound(), result)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/common/ruleset"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var flagRuleSetCompileOutput string
+
+const flagRuleSetCompileDefaultOutput = "<file_name>.srs"
+
+var commandRuleSetCompile = &cobra.Command{
+	Use:   "compile [source-path]",
+	Short: "Compile rule-set json to binary",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := compileRuleSet(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+func init() {
+	commandRuleSetCompile.Flags().StringVarP(&flagRuleSetCompileOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file")
+	commandRuleSet.AddCommand(commandRuleSetCompile)
+}
+
+func compileRuleSet(sourcePath string) error {
+	content, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return E.Cause(err, "read source")
+	}
+	var plainRuleSet option.PlainRuleSet
+	err = json.Unmarshal(content, &plainRuleSet)
+	if err != nil {
+		return E.Cause(err, "decode source")
+	}
+	var outputPath string
+	if flagRuleSetCompileOutput == flagRuleSetCompileDefaultOutput {
+		outputPath = strings.TrimSuffix(sourcePath, filepath.Ext(sourcePath)) + ".srs"
+	} else {
+		outputPath = flagRuleSetCompileOutput
+	}
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return E.Cause(err, "open output")
+	}
+	err = ruleset.Write(outputFile, plainRuleSet)
+	if err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return E.Cause(err, "write output")
+	}
+	err = outputFile.Close()
+	if err != nil {
+		os.Remove(outputPath)
+		return E.Cause(err, "close output")
+	}
+	return nil
+}
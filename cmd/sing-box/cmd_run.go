@@ -52,10 +52,10 @@ func readConfig() (option.Options, error) {
 	return options, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func readRunConfig() (option.Options, error) {
 	options, err := readConfig()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -63,6 +63,14 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := readRunConfig()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	instance, err := box.New(ctx, options)
 	if err != nil {
@@ -89,6 +97,14 @@ func run() error {
 		for {
 			osSignal := <-osSignals
 			if osSignal == syscall.SIGHUP {
+				err = reload(instance)
+				if err == nil {
+					continue
+				}
+				if err != box.ErrRestartRequired {
+					log.Error(E.Cause(err, "reload service"))
+					continue
+				}
 				err = check()
 				if err != nil {
 					log.Error(E.Cause(err, "reload service"))
@@ -104,3 +120,11 @@ func run() error {
 		}
 	}
 }
+
+func reload(instance *box.Box) error {
+	options, err := readRunConfig()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
@@ -2,11 +2,14 @@ package dialer
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/netip"
+	"syscall"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dns64"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-dns"
 	M "github.com/sagernet/sing/common/metadata"
@@ -31,7 +34,13 @@ func NewResolveDialer(router adapter.Router, dialer N.Dialer, strategy dns.Domai
 
 func (d *ResolveDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	if !destination.IsFqdn() {
-		return d.dialer.DialContext(ctx, network, destination)
+		conn, err := d.dialer.DialContext(ctx, network, destination)
+		if err != nil && isNetworkUnreachable(err) {
+			if translated, loaded := d.translate(destination.Addr); loaded {
+				return d.dialer.DialContext(ctx, network, M.SocksaddrFrom(translated, destination.Port))
+			}
+		}
+		return conn, err
 	}
 	ctx, metadata := adapter.AppendContext(ctx)
 	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
@@ -52,6 +61,13 @@ func (d *ResolveDialer) DialContext(ctx context.Context, network string, destina
 
 func (d *ResolveDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	if !destination.IsFqdn() {
+		if translated, loaded := d.translate(destination.Addr); loaded && !d.reachable(ctx, destination) {
+			conn, err := d.dialer.ListenPacket(ctx, M.SocksaddrFrom(translated, destination.Port))
+			if err != nil {
+				return nil, err
+			}
+			return NewDNS64PacketConn(conn, d.router.DNS64Prefix()), nil
+		}
 		return d.dialer.ListenPacket(ctx, destination)
 	}
 	ctx, metadata := adapter.AppendContext(ctx)
@@ -75,6 +91,29 @@ func (d *ResolveDialer) ListenPacket(ctx context.Context, destination M.Socksadd
 	return NewResolvePacketConn(ctx, d.router, d.strategy, conn), nil
 }
 
+// translate returns the IPv4 address embedded in the DNS64 prefix if DNS64 is enabled.
+func (d *ResolveDialer) translate(address netip.Addr) (netip.Addr, bool) {
+	prefix := d.router.DNS64Prefix()
+	if !prefix.IsValid() || !address.Unmap().Is4() {
+		return netip.Addr{}, false
+	}
+	return dns64.Synthesize(prefix, address), true
+}
+
+// reachable reports whether a route to the destination exists, by connecting an UDP socket to it.
+func (d *ResolveDialer) reachable(ctx context.Context, destination M.Socksaddr) bool {
+	conn, err := d.dialer.DialContext(ctx, N.NetworkUDP, destination)
+	if err != nil {
+		return !isNetworkUnreachable(err)
+	}
+	conn.Close()
+	return true
+}
+
+func isNetworkUnreachable(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH)
+}
+
 func (d *ResolveDialer) Upstream() any {
 	return d.dialer
 }
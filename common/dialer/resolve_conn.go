@@ -3,8 +3,10 @@ package dialer
 import (
 	"context"
 	"net"
+	"net/netip"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dns64"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/buf"
@@ -82,3 +84,34 @@ func (w *ResolvePacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksa
 func (w *ResolvePacketConn) Upstream() any {
 	return w.PacketConn
 }
+
+// DNS64PacketConn sends packets for IPv4 addresses to addresses synthesized in the DNS64 prefix,
+// and reports packets from them as sent by the IPv4 addresses.
+type DNS64PacketConn struct {
+	net.PacketConn
+	prefix netip.Prefix
+}
+
+func NewDNS64PacketConn(conn net.PacketConn, prefix netip.Prefix) *DNS64PacketConn {
+	return &DNS64PacketConn{conn, prefix}
+}
+
+func (c *DNS64PacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.PacketConn.ReadFrom(p)
+	if err != nil {
+		return
+	}
+	source := M.SocksaddrFromNet(addr)
+	if address, loaded := dns64.Extract(c.prefix, source.Addr); loaded {
+		addr = M.SocksaddrFrom(address, source.Port).UDPAddr()
+	}
+	return
+}
+
+func (c *DNS64PacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	destination := M.SocksaddrFromNet(addr)
+	if destination.Addr.Unmap().Is4() {
+		addr = M.SocksaddrFrom(dns64.Synthesize(c.prefix, destination.Addr), destination.Port).UDPAddr()
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
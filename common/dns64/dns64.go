@@ -0,0 +1,54 @@
+package dns64
+
+import (
+	"net/netip"
+)
+
+// DefaultPrefix is the Well-Known Prefix.
+var DefaultPrefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// IsValidPrefix reports whether the prefix is an IPv6 prefix with one of the lengths defined by RFC 6052.
+func IsValidPrefix(prefix netip.Prefix) bool {
+	if !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+		return false
+	}
+	switch prefix.Bits() {
+	case 32, 40, 48, 56, 64, 96:
+		return true
+	default:
+		return false
+	}
+}
+
+// Synthesize embeds the IPv4 address in the prefix as described in RFC 6052, skipping bits 64 to 71.
+func Synthesize(prefix netip.Prefix, address netip.Addr) netip.Addr {
+	ipv6 := prefix.Masked().Addr().As16()
+	ipv4 := address.Unmap().As4()
+	index := prefix.Bits() / 8
+	for _, b := range ipv4 {
+		if index == 8 {
+			index++
+		}
+		ipv6[index] = b
+		index++
+	}
+	return netip.AddrFrom16(ipv6)
+}
+
+// Extract returns the IPv4 address embedded in the address, if it is in the prefix.
+func Extract(prefix netip.Prefix, address netip.Addr) (netip.Addr, bool) {
+	if !address.Is6() || address.Is4In6() || !prefix.Contains(address) {
+		return netip.Addr{}, false
+	}
+	ipv6 := address.As16()
+	var ipv4 [4]byte
+	index := prefix.Bits() / 8
+	for i := range ipv4 {
+		if index == 8 {
+			index++
+		}
+		ipv4[i] = ipv6[index]
+		index++
+	}
+	return netip.AddrFrom4(ipv4), true
+}
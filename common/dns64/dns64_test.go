@@ -0,0 +1,52 @@
+package dns64_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/dns64"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRFC6052(t *testing.T) {
+	t.Parallel()
+	ipv4 := netip.MustParseAddr("192.0.2.33")
+	// RFC 6052 2.4, Table 1
+	for _, example := range []struct {
+		prefix string
+		ipv6   string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::192.0.2.33"},
+		{"64:ff9b::/96", "64:ff9b::192.0.2.33"},
+	} {
+		prefix := netip.MustParsePrefix(example.prefix)
+		ipv6 := netip.MustParseAddr(example.ipv6)
+		require.True(t, dns64.IsValidPrefix(prefix), example.prefix)
+		require.Equal(t, ipv6, dns64.Synthesize(prefix, ipv4), example.prefix)
+		require.Equal(t, ipv6, dns64.Synthesize(prefix, netip.AddrFrom16(ipv4.As16())), example.prefix)
+		extracted, loaded := dns64.Extract(prefix, ipv6)
+		require.True(t, loaded, example.prefix)
+		require.Equal(t, ipv4, extracted, example.prefix)
+	}
+}
+
+func TestExtractOutside(t *testing.T) {
+	t.Parallel()
+	for _, address := range []string{"2001:db9::c000:221", "192.0.2.33", "::ffff:192.0.2.33"} {
+		_, loaded := dns64.Extract(dns64.DefaultPrefix, netip.MustParseAddr(address))
+		require.False(t, loaded, address)
+	}
+}
+
+func TestIsValidPrefix(t *testing.T) {
+	t.Parallel()
+	for _, prefix := range []string{"2001:db8::/33", "2001:db8::/128", "10.0.0.0/8", "::ffff:0:0/96"} {
+		require.False(t, dns64.IsValidPrefix(netip.MustParsePrefix(prefix)), prefix)
+	}
+}
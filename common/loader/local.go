@@ -0,0 +1,99 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Options describes a content loaded from a file or an URL, like a rule-set or an outbound provider.
+type Options struct {
+	Logger log.ContextLogger
+	// Name is the kind and the tag of the content used in logs, like "rule-set geosite-cn".
+	Name           string
+	UpdateInterval time.Duration
+	// Load replaces the content.
+	Load func(content []byte, updatedAt time.Time) error
+	// Describe summarizes the loaded content in logs.
+	Describe func() string
+	// UpdatedAt returns the update time of the loaded content, zero if nothing is loaded.
+	UpdatedAt func() time.Time
+	// SetUpdatedAt marks the loaded content as up to date.
+	SetUpdatedAt func(updatedAt time.Time)
+}
+
+// Local loads a content from a file, and reloads it when modified.
+type Local struct {
+	Options
+	path   string
+	ticker *time.Ticker
+	close  chan struct{}
+}
+
+func NewLocal(options Options, path string) *Local {
+	return &Local{
+		Options: options,
+		path:    path,
+		close:   make(chan struct{}),
+	}
+}
+
+func (l *Local) Start() error {
+	err := l.reload(true)
+	if err != nil {
+		return err
+	}
+	l.ticker = time.NewTicker(l.UpdateInterval)
+	go l.loopUpdate()
+	return nil
+}
+
+func (l *Local) Close() error {
+	if l.ticker == nil {
+		return nil
+	}
+	l.ticker.Stop()
+	close(l.close)
+	return nil
+}
+
+func (l *Local) Update(ctx context.Context) error {
+	return l.reload(true)
+}
+
+func (l *Local) loopUpdate() {
+	for {
+		select {
+		case <-l.close:
+			return
+		case <-l.ticker.C:
+			err := l.reload(false)
+			if err != nil {
+				l.Logger.Error("reload ", l.Name, ": ", err)
+			}
+		}
+	}
+}
+
+func (l *Local) reload(force bool) error {
+	fileInfo, err := os.Stat(l.path)
+	if err != nil {
+		return E.Cause(err, "read ", l.Name)
+	}
+	if !force && !fileInfo.ModTime().After(l.UpdatedAt()) {
+		return nil
+	}
+	content, err := os.ReadFile(l.path)
+	if err != nil {
+		return E.Cause(err, "read ", l.Name)
+	}
+	err = l.Load(content, fileInfo.ModTime())
+	if err != nil {
+		return E.Cause(err, "load ", l.Name)
+	}
+	l.Logger.Info("loaded ", l.Name, ": ", l.Describe())
+	return nil
+}
@@ -0,0 +1,217 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const retryDelay = 10 * time.Second
+
+type RemoteOptions struct {
+	Options
+	Router         adapter.Router
+	URL            string
+	DownloadDetour string
+	// LoadCache and SaveCache access the content saved in the cache file.
+	LoadCache func(cacheFile adapter.CacheFile) *adapter.SavedRemoteContent
+	SaveCache func(cacheFile adapter.CacheFile, content *adapter.SavedRemoteContent) error
+}
+
+// Remote downloads a content from an URL periodically, and keeps it in the cache file if enabled.
+type Remote struct {
+	RemoteOptions
+	ctx          context.Context
+	cancel       context.CancelFunc
+	detour       adapter.Outbound
+	updateAccess sync.Mutex
+	lastEtag     string
+	ticker       *time.Ticker
+}
+
+func NewRemote(ctx context.Context, options RemoteOptions) *Remote {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Remote{
+		RemoteOptions: options,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func (r *Remote) Start() error {
+	if r.DownloadDetour != "" {
+		detour, loaded := r.Router.Outbound(r.DownloadDetour)
+		if !loaded {
+			return E.New(r.Name, ": download detour not found: ", r.DownloadDetour)
+		}
+		r.detour = detour
+	} else {
+		r.detour = r.Router.DefaultOutbound(N.NetworkTCP)
+	}
+	if cacheFile := r.Router.CacheFile(); cacheFile != nil {
+		if savedContent := r.LoadCache(cacheFile); savedContent != nil {
+			err := r.Load(savedContent.Content, savedContent.LastUpdated)
+			if err != nil {
+				r.Logger.Warn("load cached ", r.Name, ": ", err)
+			} else {
+				r.lastEtag = savedContent.LastEtag
+			}
+		}
+	}
+	if r.UpdatedAt().IsZero() {
+		err := r.Update(r.ctx)
+		if err != nil {
+			// start empty and retry in background, instead of failing the whole box
+			r.Logger.Error("initial ", r.Name, ": ", err)
+		}
+	}
+	r.ticker = time.NewTicker(r.UpdateInterval)
+	go r.loopUpdate()
+	return nil
+}
+
+func (r *Remote) Close() error {
+	r.cancel()
+	if r.ticker != nil {
+		r.ticker.Stop()
+	}
+	return nil
+}
+
+func (r *Remote) loopUpdate() {
+	if r.UpdatedAt().IsZero() {
+		if !r.retryInitialUpdate() {
+			return
+		}
+	} else if time.Since(r.UpdatedAt()) > r.UpdateInterval {
+		err := r.Update(r.ctx)
+		if err != nil {
+			r.Logger.Error("update ", r.Name, ": ", err)
+		}
+	}
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.ticker.C:
+			err := r.Update(r.ctx)
+			if err != nil {
+				r.Logger.Error("update ", r.Name, ": ", err)
+			}
+		}
+	}
+}
+
+// retryInitialUpdate retries the first download with backoff, so a box started before the network is up
+// does not run with an empty content for a whole update interval.
+func (r *Remote) retryInitialUpdate() bool {
+	delay := retryDelay
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return false
+		case <-timer.C:
+		}
+		if !r.UpdatedAt().IsZero() {
+			break
+		}
+		err := r.Update(r.ctx)
+		if err != nil {
+			r.Logger.Error("update ", r.Name, ": ", err)
+		}
+		if !r.UpdatedAt().IsZero() {
+			break
+		}
+		delay *= 2
+		if delay > r.UpdateInterval {
+			delay = r.UpdateInterval
+		}
+		timer.Reset(delay)
+	}
+	r.ticker.Reset(r.UpdateInterval)
+	return true
+}
+
+func (r *Remote) Update(ctx context.Context) error {
+	r.updateAccess.Lock()
+	defer r.updateAccess.Unlock()
+	r.Logger.Debug("updating ", r.Name, " from URL: ", r.URL)
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return r.detour.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return err
+	}
+	if r.lastEtag != "" {
+		request.Header.Set("If-None-Match", r.lastEtag)
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		now := time.Now()
+		r.SetUpdatedAt(now)
+		r.Logger.Info("update ", r.Name, ": not modified")
+		return r.saveContent(nil, now)
+	default:
+		return E.New("unexpected status: ", response.Status)
+	}
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = r.Load(content, now)
+	if err != nil {
+		return err
+	}
+	r.lastEtag = response.Header.Get("ETag")
+	r.Logger.Info("updated ", r.Name, ": ", r.Describe())
+	return r.saveContent(content, now)
+}
+
+func (r *Remote) saveContent(content []byte, updatedAt time.Time) error {
+	cacheFile := r.Router.CacheFile()
+	if cacheFile == nil {
+		return nil
+	}
+	if content == nil {
+		savedContent := r.LoadCache(cacheFile)
+		if savedContent == nil {
+			return nil
+		}
+		content = savedContent.Content
+	}
+	err := r.SaveCache(cacheFile, &adapter.SavedRemoteContent{
+		Content:     content,
+		LastUpdated: updatedAt,
+		LastEtag:    r.lastEtag,
+	})
+	if err != nil {
+		return E.Cause(err, "save ", r.Name, " to cache file")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package ruleset
+
+type ItemType = uint8
+
+const (
+	ItemTypeNetwork ItemType = iota
+	ItemTypeDomain
+	ItemTypeDomainSuffix
+	ItemTypeDomainKeyword
+	ItemTypeDomainRegex
+	ItemTypeSourceIPCIDR
+	ItemTypeIPCIDR
+	ItemTypeSourcePort
+	ItemTypeSourcePortRange
+	ItemTypePort
+	ItemTypePortRange
+	ItemTypeProcessName
+	ItemTypeProcessPath
+	ItemTypePackageName
+	ItemTypeFinal ItemType = 0xFF
+)
+
+const Version = 1
+
+var MagicBytes = [3]byte{'S', 'R', 'S'}
@@ -0,0 +1,211 @@
+package ruleset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+)
+
+// maxPreallocate bounds the capacity allocated from lengths in the file, which may be crafted,
+// so memory only grows with the data actually read.
+const maxPreallocate = 1024
+
+func IsBinary(content []byte) bool {
+	return bytes.HasPrefix(content, MagicBytes[:])
+}
+
+func Read(reader io.Reader) (option.PlainRuleSet, error) {
+	var ruleSet option.PlainRuleSet
+	var magicBytes [3]byte
+	_, err := io.ReadFull(reader, magicBytes[:])
+	if err != nil {
+		return ruleSet, err
+	}
+	if magicBytes != MagicBytes {
+		return ruleSet, E.New("invalid rule-set file")
+	}
+	version, err := rw.ReadByte(reader)
+	if err != nil {
+		return ruleSet, err
+	}
+	if version != Version {
+		return ruleSet, E.New("unsupported rule-set version: ", version)
+	}
+	zReader, err := zlib.NewReader(reader)
+	if err != nil {
+		return ruleSet, err
+	}
+	defer zReader.Close()
+	bReader := bufio.NewReader(zReader)
+	length, err := rw.ReadUVariant(bReader)
+	if err != nil {
+		return ruleSet, err
+	}
+	ruleSet.Rules = makeSlice[option.DefaultRule](length)
+	for i := uint64(0); i < length; i++ {
+		rule, err := readRule(bReader)
+		if err != nil {
+			return ruleSet, E.Cause(err, "read rules[", i, "]")
+		}
+		ruleSet.Rules = append(ruleSet.Rules, rule)
+	}
+	// read to the end to verify the checksum of the stream
+	_, err = io.Copy(io.Discard, bReader)
+	if err != nil {
+		return ruleSet, err
+	}
+	return ruleSet, nil
+}
+
+func readRule(reader *bufio.Reader) (option.DefaultRule, error) {
+	var rule option.DefaultRule
+	for {
+		itemType, err := reader.ReadByte()
+		if err != nil {
+			return rule, err
+		}
+		switch itemType {
+		case ItemTypeNetwork:
+			var values []string
+			values, err = readStringItem(reader)
+			if err == nil && len(values) > 0 {
+				rule.Network = values[0]
+			}
+		case ItemTypeDomain:
+			rule.Domain, err = readStringItem(reader)
+		case ItemTypeDomainSuffix:
+			rule.DomainSuffix, err = readStringItem(reader)
+		case ItemTypeDomainKeyword:
+			rule.DomainKeyword, err = readStringItem(reader)
+		case ItemTypeDomainRegex:
+			rule.DomainRegex, err = readStringItem(reader)
+		case ItemTypeSourceIPCIDR:
+			rule.SourceIPCIDR, err = readPrefixItem(reader)
+		case ItemTypeIPCIDR:
+			rule.IPCIDR, err = readPrefixItem(reader)
+		case ItemTypeSourcePort:
+			rule.SourcePort, err = readPortItem(reader)
+		case ItemTypeSourcePortRange:
+			rule.SourcePortRange, err = readStringItem(reader)
+		case ItemTypePort:
+			rule.Port, err = readPortItem(reader)
+		case ItemTypePortRange:
+			rule.PortRange, err = readStringItem(reader)
+		case ItemTypeProcessName:
+			rule.ProcessName, err = readStringItem(reader)
+		case ItemTypeProcessPath:
+			rule.ProcessPath, err = readStringItem(reader)
+		case ItemTypePackageName:
+			rule.PackageName, err = readStringItem(reader)
+		case ItemTypeFinal:
+			var invert byte
+			invert, err = reader.ReadByte()
+			rule.Invert = invert != 0
+			return rule, err
+		default:
+			return rule, E.New("unknown item type: ", itemType)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+}
+
+func readStringItem(reader *bufio.Reader) ([]string, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	values := makeSlice[string](length)
+	for i := uint64(0); i < length; i++ {
+		value, err := readString(reader)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func readPrefixItem(reader *bufio.Reader) ([]string, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	values := makeSlice[string](length)
+	for i := uint64(0); i < length; i++ {
+		addressLen, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		address, err := rw.ReadBytes(reader, int(addressLen))
+		if err != nil {
+			return nil, err
+		}
+		bits, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := netip.AddrFromSlice(address)
+		if !ok {
+			return nil, E.New("invalid address length: ", addressLen)
+		}
+		if int(bits) > addr.BitLen() {
+			return nil, E.New("invalid prefix bits: ", bits)
+		}
+		values = append(values, netip.PrefixFrom(addr, int(bits)).String())
+	}
+	return values, nil
+}
+
+func readPortItem(reader *bufio.Reader) ([]uint16, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	values := makeSlice[uint16](length)
+	for i := uint64(0); i < length; i++ {
+		var value uint16
+		err = binary.Read(reader, binary.BigEndian, &value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func readString(reader *bufio.Reader) (string, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return "", err
+	}
+	if length > math.MaxInt32 {
+		return "", E.New("string too long: ", length)
+	}
+	var builder strings.Builder
+	_, err = io.CopyN(&builder, reader, int64(length))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+func makeSlice[T any](length uint64) []T {
+	if length > maxPreallocate {
+		length = maxPreallocate
+	}
+	return make([]T, 0, length)
+}
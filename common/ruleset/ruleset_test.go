@@ -0,0 +1,96 @@
+package ruleset_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/ruleset"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleSetRoundTrip(t *testing.T) {
+	t.Parallel()
+	ruleSet := option.PlainRuleSet{
+		Rules: []option.DefaultRule{
+			{
+				Network:       "tcp",
+				Domain:        []string{"example.org"},
+				DomainSuffix:  []string{".example.com"},
+				DomainKeyword: []string{"example"},
+				DomainRegex:   []string{`^example\.net$`},
+				Port:          []uint16{80, 443},
+				PortRange:     []string{"1000:2000"},
+			},
+			{
+				SourceIPCIDR:    []string{"10.0.0.0/8"},
+				IPCIDR:          []string{"1.1.1.1/32", "2001:db8::/32"},
+				SourcePort:      []uint16{53},
+				SourcePortRange: []string{":1024"},
+				ProcessName:     []string{"curl"},
+				ProcessPath:     []string{"/usr/bin/curl"},
+				PackageName:     []string{"com.example"},
+				Invert:          true,
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, ruleset.Write(&buffer, ruleSet))
+	require.True(t, ruleset.IsBinary(buffer.Bytes()))
+	readRuleSet, err := ruleset.Read(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, ruleSet, readRuleSet)
+}
+
+func TestRuleSetTruncated(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	require.NoError(t, ruleset.Write(&buffer, option.PlainRuleSet{
+		Rules: []option.DefaultRule{{
+			Domain: []string{"example.org", "example.com"},
+			IPCIDR: []string{"1.1.1.1/32"},
+			Port:   []uint16{443},
+		}},
+	}))
+	content := buffer.Bytes()
+	for i := 0; i < len(content); i++ {
+		_, err := ruleset.Read(bytes.NewReader(content[:i]))
+		require.Error(t, err, "truncated to ", i)
+	}
+}
+
+func TestRuleSetOversizedLength(t *testing.T) {
+	t.Parallel()
+	for _, payload := range [][]byte{
+		// rule count
+		uvarint(1 << 62),
+		// domain count
+		append(uvarint(1), append([]byte{ruleset.ItemTypeDomain}, uvarint(1<<62)...)...),
+		// domain length
+		append(uvarint(1), append([]byte{ruleset.ItemTypeDomain, 1}, uvarint(1<<62)...)...),
+		// port count
+		append(uvarint(1), append([]byte{ruleset.ItemTypePort}, uvarint(1<<62)...)...),
+	} {
+		_, err := ruleset.Read(bytes.NewReader(binaryRuleSet(t, payload)))
+		require.Error(t, err)
+	}
+}
+
+func binaryRuleSet(t *testing.T, payload []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Write(ruleset.MagicBytes[:])
+	buffer.WriteByte(ruleset.Version)
+	zWriter := zlib.NewWriter(&buffer)
+	_, err := zWriter.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, zWriter.Close())
+	return buffer.Bytes()
+}
+
+func uvarint(value uint64) []byte {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	return buffer[:binary.PutUvarint(buffer, value)]
+}
@@ -0,0 +1,204 @@
+package ruleset
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"reflect"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+)
+
+func Write(writer io.Writer, ruleSet option.PlainRuleSet) error {
+	_, err := writer.Write(MagicBytes[:])
+	if err != nil {
+		return err
+	}
+	err = rw.WriteByte(writer, Version)
+	if err != nil {
+		return err
+	}
+	zWriter, err := zlib.NewWriterLevel(writer, zlib.BestCompression)
+	if err != nil {
+		return err
+	}
+	bWriter := bufio.NewWriter(zWriter)
+	err = rw.WriteUVariant(bWriter, uint64(len(ruleSet.Rules)))
+	if err != nil {
+		return err
+	}
+	for i, rule := range ruleSet.Rules {
+		err = writeRule(bWriter, rule)
+		if err != nil {
+			return E.Cause(err, "write rules[", i, "]")
+		}
+	}
+	err = bWriter.Flush()
+	if err != nil {
+		return err
+	}
+	return zWriter.Close()
+}
+
+func writeRule(writer *bufio.Writer, rule option.DefaultRule) error {
+	var supportedRule option.DefaultRule
+	supportedRule.Network = rule.Network
+	supportedRule.Domain = rule.Domain
+	supportedRule.DomainSuffix = rule.DomainSuffix
+	supportedRule.DomainKeyword = rule.DomainKeyword
+	supportedRule.DomainRegex = rule.DomainRegex
+	supportedRule.SourceIPCIDR = rule.SourceIPCIDR
+	supportedRule.IPCIDR = rule.IPCIDR
+	supportedRule.SourcePort = rule.SourcePort
+	supportedRule.SourcePortRange = rule.SourcePortRange
+	supportedRule.Port = rule.Port
+	supportedRule.PortRange = rule.PortRange
+	supportedRule.ProcessName = rule.ProcessName
+	supportedRule.ProcessPath = rule.ProcessPath
+	supportedRule.PackageName = rule.PackageName
+	supportedRule.Invert = rule.Invert
+	supportedRule.Outbound = rule.Outbound
+	if !reflect.DeepEqual(rule, supportedRule) {
+		return E.New("binary rule-set only supports network, domain, domain_suffix, domain_keyword, domain_regex, source_ip_cidr, ip_cidr, source_port, source_port_range, port, port_range, process_name, process_path and package_name")
+	}
+	var err error
+	if rule.Network != "" {
+		err = writeStringItem(writer, ItemTypeNetwork, []string{rule.Network})
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range []struct {
+		itemType ItemType
+		values   []string
+	}{
+		{ItemTypeDomain, rule.Domain},
+		{ItemTypeDomainSuffix, rule.DomainSuffix},
+		{ItemTypeDomainKeyword, rule.DomainKeyword},
+		{ItemTypeDomainRegex, rule.DomainRegex},
+		{ItemTypeSourcePortRange, rule.SourcePortRange},
+		{ItemTypePortRange, rule.PortRange},
+		{ItemTypeProcessName, rule.ProcessName},
+		{ItemTypeProcessPath, rule.ProcessPath},
+		{ItemTypePackageName, rule.PackageName},
+	} {
+		if len(item.values) > 0 {
+			err = writeStringItem(writer, item.itemType, item.values)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(rule.SourceIPCIDR) > 0 {
+		err = writePrefixItem(writer, ItemTypeSourceIPCIDR, rule.SourceIPCIDR)
+		if err != nil {
+			return E.Cause(err, "source_ip_cidr")
+		}
+	}
+	if len(rule.IPCIDR) > 0 {
+		err = writePrefixItem(writer, ItemTypeIPCIDR, rule.IPCIDR)
+		if err != nil {
+			return E.Cause(err, "ip_cidr")
+		}
+	}
+	if len(rule.SourcePort) > 0 {
+		err = writePortItem(writer, ItemTypeSourcePort, rule.SourcePort)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.Port) > 0 {
+		err = writePortItem(writer, ItemTypePort, rule.Port)
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.WriteByte(ItemTypeFinal)
+	if err != nil {
+		return err
+	}
+	var invert byte
+	if rule.Invert {
+		invert = 1
+	}
+	return writer.WriteByte(invert)
+}
+
+func writeStringItem(writer *bufio.Writer, itemType ItemType, values []string) error {
+	err := writer.WriteByte(itemType)
+	if err != nil {
+		return err
+	}
+	err = rw.WriteUVariant(writer, uint64(len(values)))
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = rw.WriteVString(writer, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePrefixItem(writer *bufio.Writer, itemType ItemType, values []string) error {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for i, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return E.Cause(err, "parse [", i, "]")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	err := writer.WriteByte(itemType)
+	if err != nil {
+		return err
+	}
+	err = rw.WriteUVariant(writer, uint64(len(prefixes)))
+	if err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		address := prefix.Addr().AsSlice()
+		err = writer.WriteByte(byte(len(address)))
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(address)
+		if err != nil {
+			return err
+		}
+		err = writer.WriteByte(byte(prefix.Bits()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePortItem(writer *bufio.Writer, itemType ItemType, values []uint16) error {
+	err := writer.WriteByte(itemType)
+	if err != nil {
+		return err
+	}
+	err = rw.WriteUVariant(writer, uint64(len(values)))
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = binary.Write(writer, binary.BigEndian, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -37,6 +37,16 @@ func (s *HistoryStorage) LoadURLTestHistory(tag string) *History {
 	return s.delayHistory[tag]
 }
 
+func (s *HistoryStorage) LoadAllURLTestHistory() map[string]*History {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	histories := make(map[string]*History, len(s.delayHistory))
+	for tag, history := range s.delayHistory {
+		histories[tag] = history
+	}
+	return histories
+}
+
 func (s *HistoryStorage) DeleteURLTestHistory(tag string) {
 	s.access.Lock()
 	defer s.access.Unlock()
@@ -0,0 +1,6 @@
+package constant
+
+const (
+	OutboundProviderTypeLocal  = "local"
+	OutboundProviderTypeRemote = "remote"
+)
@@ -12,15 +12,32 @@ const (
 	TypeMixed       = "mixed"
 	TypeShadowsocks = "shadowsocks"
 	TypeVMess       = "vmess"
+	TypeVLESS       = "vless"
 	TypeTrojan      = "trojan"
 	TypeNaive       = "naive"
 	TypeWireGuard   = "wireguard"
 	TypeHysteria    = "hysteria"
+	TypeTUIC        = "tuic"
+	TypeHysteria2   = "hysteria2"
 	TypeTor         = "tor"
 	TypeSSH         = "ssh"
 	TypeShadowTLS   = "shadowtls"
 )
 
 const (
-	TypeSelector = "selector"
+	TypeSelector    = "selector"
+	TypeURLTest     = "urltest"
+	TypeFallback    = "fallback"
+	TypeLoadBalance = "load_balance"
+)
+
+const (
+	LoadBalanceStrategyRoundRobin        = "round_robin"
+	LoadBalanceStrategyRandom            = "random"
+	LoadBalanceStrategyConsistentHashing = "consistent_hashing"
+)
+
+const (
+	LoadBalanceHashKeyDestination = "destination"
+	LoadBalanceHashKeySource      = "source"
 )
@@ -9,3 +9,19 @@ const (
 	LogicalTypeAnd = "and"
 	LogicalTypeOr  = "or"
 )
+
+const (
+	RuleSetTypeLocal  = "local"
+	RuleSetTypeRemote = "remote"
+)
+
+const (
+	RuleSetFormatSource = "source"
+	RuleSetFormatBinary = "binary"
+)
+
+const (
+	DNSRuleActionRetry  = "retry"
+	DNSRuleActionReject = "reject"
+	DNSRuleActionDrop   = "drop"
+)
@@ -3,10 +3,13 @@ package constant
 import "time"
 
 const (
-	TCPTimeout         = 5 * time.Second
-	ReadPayloadTimeout = 300 * time.Millisecond
-	DNSTimeout         = 10 * time.Second
-	QUICTimeout        = 30 * time.Second
-	STUNTimeout        = 15 * time.Second
-	UDPTimeout         = 5 * time.Minute
+	TCPTimeout                      = 5 * time.Second
+	ReadPayloadTimeout              = 300 * time.Millisecond
+	DNSTimeout                      = 10 * time.Second
+	QUICTimeout                     = 30 * time.Second
+	STUNTimeout                     = 15 * time.Second
+	UDPTimeout                      = 5 * time.Minute
+	DefaultURLTestInterval          = 1 * time.Minute
+	DefaultRuleSetInterval          = 24 * time.Hour
+	DefaultOutboundProviderInterval = 1 * time.Hour
 )
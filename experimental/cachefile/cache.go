@@ -0,0 +1,211 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/common/urltest"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketSelected         = []byte("selected")
+	bucketURLTest          = []byte("url_test_history")
+	bucketRuleSet          = []byte("rule_set")
+	bucketOutboundProvider = []byte("outbound_provider")
+	bucketDNSCache         = []byte("dns_cache")
+)
+
+var _ adapter.CacheFile = (*CacheFile)(nil)
+
+type CacheFile struct {
+	DB *bbolt.DB
+}
+
+func Open(path string) (*CacheFile, error) {
+	const fileMode = 0o666
+	options := bbolt.Options{Timeout: time.Second, NoSync: true}
+	db, err := bbolt.Open(path, fileMode, &options)
+	switch err {
+	case bbolt.ErrInvalid, bbolt.ErrChecksum, bbolt.ErrVersionMismatch:
+		if err = os.Remove(path); err != nil {
+			break
+		}
+		db, err = bbolt.Open(path, fileMode, &options)
+	}
+	if err != nil {
+		return nil, E.Cause(err, "open cache file")
+	}
+	return &CacheFile{db}, nil
+}
+
+func (c *CacheFile) Start() error {
+	return nil
+}
+
+func (c *CacheFile) Close() error {
+	return c.DB.Close()
+}
+
+func (c *CacheFile) LoadSelected(group string) string {
+	var selected string
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketSelected)
+		if bucket == nil {
+			return nil
+		}
+		selectedBytes := bucket.Get([]byte(group))
+		if len(selectedBytes) > 0 {
+			selected = string(selectedBytes)
+		}
+		return nil
+	})
+	return selected
+}
+
+func (c *CacheFile) StoreSelected(group, selected string) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(bucketSelected)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(group), []byte(selected))
+	})
+}
+
+func (c *CacheFile) LoadURLTestHistory(storage *urltest.HistoryStorage) error {
+	return c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketURLTest)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(tag, content []byte) error {
+			var history urltest.History
+			err := json.Unmarshal(content, &history)
+			if err != nil {
+				return E.Cause(err, "decode url test history of ", string(tag))
+			}
+			storage.StoreURLTestHistory(string(tag), &history)
+			return nil
+		})
+	})
+}
+
+func (c *CacheFile) StoreURLTestHistory(storage *urltest.HistoryStorage) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(bucketURLTest)
+		if err != nil {
+			return err
+		}
+		for tag, history := range storage.LoadAllURLTestHistory() {
+			content, err := json.Marshal(history)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put([]byte(tag), content)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *CacheFile) LoadRuleSet(tag string) *adapter.SavedRemoteContent {
+	return c.loadRemoteContent(bucketRuleSet, tag)
+}
+
+func (c *CacheFile) SaveRuleSet(tag string, set *adapter.SavedRemoteContent) error {
+	return c.saveRemoteContent(bucketRuleSet, tag, set)
+}
+
+func (c *CacheFile) LoadOutboundProvider(tag string) *adapter.SavedRemoteContent {
+	return c.loadRemoteContent(bucketOutboundProvider, tag)
+}
+
+func (c *CacheFile) SaveOutboundProvider(tag string, content *adapter.SavedRemoteContent) error {
+	return c.saveRemoteContent(bucketOutboundProvider, tag, content)
+}
+
+func (c *CacheFile) LoadDNSCache() []*adapter.SavedDNSResponse {
+	var responses []*adapter.SavedDNSResponse
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketDNSCache)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, content []byte) error {
+			var response adapter.SavedDNSResponse
+			if json.Unmarshal(content, &response) == nil {
+				responses = append(responses, &response)
+			}
+			return nil
+		})
+	})
+	return responses
+}
+
+// StoreDNSCache replaces all saved DNS responses.
+func (c *CacheFile) StoreDNSCache(responses []*adapter.SavedDNSResponse) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		err := t.DeleteBucket(bucketDNSCache)
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := t.CreateBucket(bucketDNSCache)
+		if err != nil {
+			return err
+		}
+		for i, response := range responses {
+			content, err := json.Marshal(response)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 4)
+			binary.BigEndian.PutUint32(key, uint32(i))
+			err = bucket.Put(key, content)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *CacheFile) loadRemoteContent(bucketName []byte, tag string) *adapter.SavedRemoteContent {
+	var savedContent adapter.SavedRemoteContent
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketName)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		content := bucket.Get([]byte(tag))
+		if content == nil {
+			return os.ErrNotExist
+		}
+		return json.Unmarshal(content, &savedContent)
+	})
+	if err != nil {
+		return nil
+	}
+	return &savedContent
+}
+
+func (c *CacheFile) saveRemoteContent(bucketName []byte, tag string, savedContent *adapter.SavedRemoteContent) error {
+	content, err := json.Marshal(savedContent)
+	if err != nil {
+		return err
+	}
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(tag), content)
+	})
+}
@@ -0,0 +1,129 @@
+package cachefile
+
+import (
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketFakeIP        = []byte("fakeip")
+	bucketFakeIPDomain4 = []byte("fakeip_domain4")
+	bucketFakeIPDomain6 = []byte("fakeip_domain6")
+	keyFakeIPMetadata   = []byte("metadata")
+)
+
+func (c *CacheFile) FakeIPMetadata() *adapter.FakeIPMetadata {
+	var metadata adapter.FakeIPMetadata
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketFakeIP)
+		if bucket == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		content := bucket.Get(keyFakeIPMetadata)
+		if content == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		return json.Unmarshal(content, &metadata)
+	})
+	if err != nil {
+		return nil
+	}
+	return &metadata
+}
+
+func (c *CacheFile) FakeIPSaveMetadata(metadata *adapter.FakeIPMetadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(bucketFakeIP)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(keyFakeIPMetadata, content)
+	})
+}
+
+func (c *CacheFile) FakeIPStore(address netip.Addr, domain string, metadata *adapter.FakeIPMetadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(bucketFakeIP)
+		if err != nil {
+			return err
+		}
+		domainBucket, err := t.CreateBucketIfNotExists(fakeIPDomainBucket(address.Is6()))
+		if err != nil {
+			return err
+		}
+		oldDomain := bucket.Get(address.AsSlice())
+		if oldDomain != nil {
+			err = domainBucket.Delete(oldDomain)
+			if err != nil {
+				return err
+			}
+		}
+		err = bucket.Put(address.AsSlice(), []byte(domain))
+		if err != nil {
+			return err
+		}
+		err = domainBucket.Put([]byte(domain), address.AsSlice())
+		if err != nil {
+			return err
+		}
+		return bucket.Put(keyFakeIPMetadata, content)
+	})
+}
+
+func (c *CacheFile) FakeIPLoad(address netip.Addr) (string, bool) {
+	var domain string
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(bucketFakeIP)
+		if bucket == nil {
+			return nil
+		}
+		domain = string(bucket.Get(address.AsSlice()))
+		return nil
+	})
+	return domain, domain != ""
+}
+
+func (c *CacheFile) FakeIPLoadDomain(domain string, isIPv6 bool) (netip.Addr, bool) {
+	var address netip.Addr
+	c.DB.View(func(t *bbolt.Tx) error {
+		bucket := t.Bucket(fakeIPDomainBucket(isIPv6))
+		if bucket == nil {
+			return nil
+		}
+		address, _ = netip.AddrFromSlice(bucket.Get([]byte(domain)))
+		return nil
+	})
+	return address, address.IsValid()
+}
+
+func (c *CacheFile) FakeIPReset() error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		for _, name := range [][]byte{bucketFakeIP, bucketFakeIPDomain4, bucketFakeIPDomain6} {
+			err := t.DeleteBucket(name)
+			if err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func fakeIPDomainBucket(isIPv6 bool) []byte {
+	if isIPv6 {
+		return bucketFakeIPDomain6
+	} else {
+		return bucketFakeIPDomain4
+	}
+}
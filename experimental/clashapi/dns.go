@@ -0,0 +1,210 @@
+package clashapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/observable"
+	"github.com/sagernet/websocket"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func dnsRouter(server *Server) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/query", queryDNS(server.router))
+	r.Get("/queries", getDNSQueries(server.dnsQueries))
+	return r
+}
+
+func queryDNS(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		typeName := r.URL.Query().Get("type")
+		if typeName == "" {
+			typeName = "A"
+		}
+		queryType, err := option.ParseDNSQueryType(typeName)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		fqdn, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+		if name == "" || err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("invalid name"))
+			return
+		}
+		message := &dnsmessage.Message{
+			Header: dnsmessage.Header{
+				RecursionDesired: true,
+			},
+			Questions: []dnsmessage.Question{{
+				Name:  fqdn,
+				Type:  dnsmessage.Type(queryType),
+				Class: dnsmessage.ClassINET,
+			}},
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), C.DNSTimeout)
+		defer cancel()
+		var record adapter.DNSQuery
+		response, err := router.Exchange(adapter.WithDNSQuery(ctx, &record), message)
+		if err != nil {
+			rCodeError, isRCodeError := err.(dns.RCodeError)
+			if !isRCodeError {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, newError(err.Error()))
+				return
+			}
+			response = &dnsmessage.Message{
+				Header: dnsmessage.Header{
+					Response:         true,
+					RecursionDesired: true,
+					RCode:            dnsmessage.RCode(rCodeError),
+				},
+				Questions: message.Questions,
+			}
+		}
+		result := render.M{
+			"Status":   response.RCode,
+			"Question": common.Map(response.Questions, dnsQuestionToJSON),
+			"TC":       response.Truncated,
+			"RD":       response.RecursionDesired,
+			"RA":       response.RecursionAvailable,
+			"AD":       response.AuthenticData,
+			"CD":       response.CheckingDisabled,
+			"Rule":     record.Rule,
+			"Server":   record.Transport,
+			"CacheHit": record.CacheHit,
+		}
+		if len(response.Answers) > 0 {
+			result["Answer"] = dnsResourcesToJSON(response.Answers)
+		}
+		if len(response.Authorities) > 0 {
+			result["Authority"] = dnsResourcesToJSON(response.Authorities)
+		}
+		if additionals := dnsResourcesToJSON(response.Additionals); len(additionals) > 0 {
+			result["Additional"] = additionals
+		}
+		render.JSON(w, r, result)
+	}
+}
+
+func dnsQuestionToJSON(question dnsmessage.Question) render.M {
+	return render.M{
+		"Name":   question.Name.String(),
+		"Qtype":  question.Type,
+		"Qclass": question.Class,
+	}
+}
+
+func dnsResourcesToJSON(resources []dnsmessage.Resource) []render.M {
+	var records []render.M
+	for _, resource := range resources {
+		if resource.Header.Type == dnsmessage.TypeOPT {
+			continue
+		}
+		records = append(records, render.M{
+			"name": resource.Header.Name.String(),
+			"type": resource.Header.Type,
+			"TTL":  resource.Header.TTL,
+			"data": dnsResourceData(resource.Body),
+		})
+	}
+	return records
+}
+
+// dnsResourceData formats the record data in the presentation format.
+func dnsResourceData(body dnsmessage.ResourceBody) string {
+	switch record := body.(type) {
+	case *dnsmessage.AResource:
+		return netip.AddrFrom4(record.A).String()
+	case *dnsmessage.AAAAResource:
+		return netip.AddrFrom16(record.AAAA).String()
+	case *dnsmessage.CNAMEResource:
+		return record.CNAME.String()
+	case *dnsmessage.NSResource:
+		return record.NS.String()
+	case *dnsmessage.PTRResource:
+		return record.PTR.String()
+	case *dnsmessage.MXResource:
+		return F.ToString(record.Pref, " ", record.MX.String())
+	case *dnsmessage.SRVResource:
+		return F.ToString(record.Priority, " ", record.Weight, " ", record.Port, " ", record.Target.String())
+	case *dnsmessage.SOAResource:
+		return F.ToString(record.NS.String(), " ", record.MBox.String(), " ", record.Serial, " ", record.Refresh, " ", record.Retry, " ", record.Expire, " ", record.MinTTL)
+	case *dnsmessage.TXTResource:
+		return strings.Join(common.Map(record.TXT, strconv.Quote), " ")
+	case *dnsmessage.UnknownResource:
+		return F.ToString("\\# ", len(record.Data), " ", hex.EncodeToString(record.Data))
+	default:
+		return ""
+	}
+}
+
+func getDNSQueries(queries observable.Observable[*adapter.DNSQuery]) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscription, done, err := queries.Subscribe()
+		if err != nil {
+			render.Status(r, http.StatusNoContent)
+			return
+		}
+		defer queries.UnSubscribe(subscription)
+
+		var wsConn *websocket.Conn
+		if websocket.IsWebSocketUpgrade(r) {
+			wsConn, err = upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+		}
+
+		if wsConn == nil {
+			w.Header().Set("Content-Type", "application/json")
+			render.Status(r, http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+
+		buf := &bytes.Buffer{}
+		var query *adapter.DNSQuery
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.Context().Done():
+				return
+			case query = <-subscription:
+			}
+			buf.Reset()
+			err = json.NewEncoder(buf).Encode(query)
+			if err != nil {
+				break
+			}
+			if wsConn == nil {
+				_, err = w.Write(buf.Bytes())
+				w.(http.Flusher).Flush()
+			} else {
+				err = wsConn.WriteMessage(websocket.TextMessage, buf.Bytes())
+			}
+
+			if err != nil {
+				break
+			}
+		}
+	}
+}
@@ -75,10 +75,16 @@ func proxyInfo(server *Server, detour adapter.Outbound) *badjson.JSONObject {
 		clashType = "Shadowsocks"
 	case C.TypeVMess:
 		clashType = "VMess"
+	case C.TypeVLESS:
+		clashType = "Vless"
 	case C.TypeTrojan:
 		clashType = "Trojan"
 	case C.TypeHysteria:
 		clashType = "Hysteria"
+	case C.TypeTUIC:
+		clashType = "Tuic"
+	case C.TypeHysteria2:
+		clashType = "Hysteria2"
 	case C.TypeWireGuard:
 		clashType = "WireGuard"
 	case C.TypeTor:
@@ -88,6 +94,15 @@ func proxyInfo(server *Server, detour adapter.Outbound) *badjson.JSONObject {
 	case C.TypeSelector:
 		clashType = "Selector"
 		isGroup = true
+	case C.TypeURLTest:
+		clashType = "URLTest"
+		isGroup = true
+	case C.TypeFallback:
+		clashType = "Fallback"
+		isGroup = true
+	case C.TypeLoadBalance:
+		clashType = "LoadBalance"
+		isGroup = true
 	default:
 		clashType = "Direct"
 	}
@@ -1,58 +1,84 @@
 package clashapi
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/badjson"
+	C "github.com/sagernet/sing-box/constant"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
-func ruleProviderRouter() http.Handler {
+func ruleProviderRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
-	r.Get("/", getRuleProviders)
+	r.Get("/", getRuleProviders(router))
 
 	r.Route("/{name}", func(r chi.Router) {
-		r.Use(parseProviderName, findRuleProviderByName)
+		r.Use(parseProviderName, findRuleProviderByName(router))
 		r.Get("/", getRuleProvider)
 		r.Put("/", updateRuleProvider)
 	})
 	return r
 }
 
-func getRuleProviders(w http.ResponseWriter, r *http.Request) {
-	render.JSON(w, r, render.M{
-		"providers": []string{},
-	})
+func ruleProviderInfo(ruleSet adapter.RuleSet) *badjson.JSONObject {
+	var info badjson.JSONObject
+	info.Put("name", ruleSet.Tag())
+	info.Put("type", "Rule")
+	info.Put("behavior", "Classical")
+	info.Put("ruleCount", ruleSet.RuleCount())
+	info.Put("updatedAt", ruleSet.UpdatedAt())
+	switch ruleSet.Type() {
+	case C.RuleSetTypeRemote:
+		info.Put("vehicleType", "HTTP")
+	default:
+		info.Put("vehicleType", "File")
+	}
+	return &info
+}
+
+func getRuleProviders(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var providers badjson.JSONObject
+		for _, ruleSet := range router.RuleSets() {
+			providers.Put(ruleSet.Tag(), ruleProviderInfo(ruleSet))
+		}
+		render.JSON(w, r, render.M{
+			"providers": &providers,
+		})
+	}
 }
 
 func getRuleProvider(w http.ResponseWriter, r *http.Request) {
-	// provider := r.Context().Value(CtxKeyProvider).(provider.RuleProvider)
-	// render.JSON(w, r, provider)
-	render.NoContent(w, r)
+	ruleSet := r.Context().Value(CtxKeyProvider).(adapter.RuleSet)
+	render.JSON(w, r, ruleProviderInfo(ruleSet))
 }
 
 func updateRuleProvider(w http.ResponseWriter, r *http.Request) {
-	/*provider := r.Context().Value(CtxKeyProvider).(provider.RuleProvider)
-	if err := provider.Update(); err != nil {
+	ruleSet := r.Context().Value(CtxKeyProvider).(adapter.RuleSet)
+	if err := ruleSet.Update(r.Context()); err != nil {
 		render.Status(r, http.StatusServiceUnavailable)
 		render.JSON(w, r, newError(err.Error()))
 		return
-	}*/
+	}
 	render.NoContent(w, r)
 }
 
-func findRuleProviderByName(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		/*name := r.Context().Value(CtxKeyProviderName).(string)
-		providers := tunnel.RuleProviders()
-		provider, exist := providers[name]
-		if !exist {*/
-		render.Status(r, http.StatusNotFound)
-		render.JSON(w, r, ErrNotFound)
-		//return
-		//}
-
-		// ctx := context.WithValue(r.Context(), CtxKeyProvider, provider)
-		// next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func findRuleProviderByName(router adapter.Router) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Context().Value(CtxKeyProviderName).(string)
+			ruleSet, exist := router.RuleSet(name)
+			if !exist {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, ErrNotFound)
+				return
+			}
+			ctx := context.WithValue(r.Context(), CtxKeyProvider, ruleSet)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -21,6 +21,7 @@ import (
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/observable"
 	"github.com/sagernet/websocket"
 
 	"github.com/go-chi/chi/v5"
@@ -36,6 +37,8 @@ type Server struct {
 	httpServer     *http.Server
 	trafficManager *trafficontrol.Manager
 	urlTestHistory *urltest.HistoryStorage
+	dnsSubscriber  *observable.Subscriber[*adapter.DNSQuery]
+	dnsQueries     *observable.Observer[*adapter.DNSQuery]
 	tcpListener    net.Listener
 }
 
@@ -51,6 +54,14 @@ func NewServer(router adapter.Router, logFactory log.ObservableFactory, options
 		},
 		trafficManager: trafficManager,
 		urlTestHistory: urltest.NewHistoryStorage(),
+		dnsSubscriber:  observable.NewSubscriber[*adapter.DNSQuery](128),
+	}
+	server.dnsQueries = observable.NewObserver[*adapter.DNSQuery](server.dnsSubscriber, 64)
+	if cacheFile := router.CacheFile(); cacheFile != nil {
+		err := cacheFile.LoadURLTestHistory(server.urlTestHistory)
+		if err != nil {
+			server.logger.Warn("load url test history: ", err)
+		}
 	}
 	cors := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -69,11 +80,12 @@ func NewServer(router adapter.Router, logFactory log.ObservableFactory, options
 		r.Mount("/proxies", proxyRouter(server, router))
 		r.Mount("/rules", ruleRouter(router))
 		r.Mount("/connections", connectionRouter(trafficManager))
-		r.Mount("/providers/proxies", proxyProviderRouter())
-		r.Mount("/providers/rules", ruleProviderRouter())
+		r.Mount("/providers/proxies", proxyProviderRouter(server, router))
+		r.Mount("/providers/rules", ruleProviderRouter(router))
 		r.Mount("/script", scriptRouter())
 		r.Mount("/profile", profileRouter())
-		r.Mount("/cache", cacheRouter())
+		r.Mount("/cache", cacheRouter(router))
+		r.Mount("/dns", dnsRouter(server))
 	})
 	if options.ExternalUI != "" {
 		chiRouter.Group(func(r chi.Router) {
@@ -104,13 +116,28 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Close() error {
+	if cacheFile := s.router.CacheFile(); cacheFile != nil {
+		err := cacheFile.StoreURLTestHistory(s.urlTestHistory)
+		if err != nil {
+			s.logger.Error("store url test history: ", err)
+		}
+	}
 	return common.Close(
 		common.PtrOrNil(s.httpServer),
 		s.tcpListener,
 		s.trafficManager,
+		s.dnsQueries,
 	)
 }
 
+func (s *Server) HistoryStorage() *urltest.HistoryStorage {
+	return s.urlTestHistory
+}
+
+func (s *Server) LogDNSQuery(query *adapter.DNSQuery) {
+	s.dnsQueries.Emit(query)
+}
+
 func (s *Server) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule) (net.Conn, adapter.Tracker) {
 	tracker := trafficontrol.NewTCPTracker(conn, s.trafficManager, castMetadata(metadata), s.router, matchedRule)
 	return tracker, tracker
@@ -134,6 +161,14 @@ func castMetadata(metadata adapter.InboundContext) trafficontrol.Metadata {
 	} else {
 		domain = metadata.Destination.Fqdn
 	}
+	var dnsMode string
+	if metadata.FakeIP {
+		dnsMode = "fake-ip"
+	} else if metadata.ReverseMapping {
+		dnsMode = "mapping"
+	} else {
+		dnsMode = "normal"
+	}
 	var processPath string
 	if metadata.ProcessInfo != nil {
 		if metadata.ProcessInfo.ProcessPath != "" {
@@ -159,7 +194,7 @@ func castMetadata(metadata adapter.InboundContext) trafficontrol.Metadata {
 		SrcPort:     F.ToString(metadata.Source.Port),
 		DstPort:     F.ToString(metadata.Destination.Port),
 		Host:        domain,
-		DNSMode:     "normal",
+		DNSMode:     dnsMode,
 		ProcessPath: processPath,
 	}
 }
@@ -0,0 +1,286 @@
+package inbound
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	dnsMessageMIME = "application/dns-message"
+	// dnsUDPSize is the UDP message size limit of RFC 1035 for queries without EDNS0
+	dnsUDPSize = 512
+)
+
+var _ adapter.Inbound = (*DNS)(nil)
+
+type DNS struct {
+	myInboundAdapter
+	tlsConfig  *TLSConfig
+	http       bool
+	path       string
+	httpServer *http.Server
+}
+
+func NewDNS(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSInboundOptions) (*DNS, error) {
+	inbound := &DNS{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeDNS,
+			network:       options.Network.Build(),
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		http: options.HTTP,
+		path: options.Path,
+	}
+	if inbound.path == "" {
+		inbound.path = "/dns-query"
+	}
+	if options.TLS != nil && options.TLS.Enabled || options.HTTP {
+		if options.Network == "" {
+			inbound.network = []string{N.NetworkTCP}
+		} else if common.Contains(inbound.network, N.NetworkUDP) {
+			return nil, E.New("UDP is not supported with TLS or HTTP")
+		}
+	}
+	if options.TLS != nil && options.TLS.Enabled {
+		tlsConfig, err := NewTLSConfig(ctx, logger, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		inbound.tlsConfig = tlsConfig
+	}
+	inbound.connHandler = inbound
+	inbound.packetHandler = inbound
+	return inbound, nil
+}
+
+func (d *DNS) Start() error {
+	var tlsConfig *tls.Config
+	if d.tlsConfig != nil {
+		err := d.tlsConfig.Start()
+		if err != nil {
+			return E.Cause(err, "create TLS config")
+		}
+		tlsConfig = d.tlsConfig.Config()
+	}
+	if !d.http {
+		return d.myInboundAdapter.Start()
+	}
+	tcpListener, err := d.ListenTCP()
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(d.path, d)
+	d.httpServer = &http.Server{
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+	go func() {
+		var sErr error
+		if tlsConfig != nil {
+			sErr = d.httpServer.ServeTLS(tcpListener, "", "")
+		} else {
+			sErr = d.httpServer.Serve(tcpListener)
+		}
+		if sErr != nil && !E.IsClosedOrCanceled(sErr) && sErr != http.ErrServerClosed {
+			d.logger.Error("http server serve error: ", sErr)
+		}
+	}()
+	return nil
+}
+
+func (d *DNS) Close() error {
+	return common.Close(
+		&d.myInboundAdapter,
+		common.PtrOrNil(d.httpServer),
+		common.PtrOrNil(d.tlsConfig),
+	)
+}
+
+func (d *DNS) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	if d.tlsConfig != nil {
+		conn = tls.Server(conn, d.tlsConfig.Config())
+	}
+	defer conn.Close()
+	var group sync.WaitGroup
+	// queries are answered concurrently, the connection is closed after all responses are written
+	defer group.Wait()
+	for {
+		conn.SetReadDeadline(time.Now().Add(C.DNSTimeout))
+		var queryLength uint16
+		err := binary.Read(conn, binary.BigEndian, &queryLength)
+		if err != nil {
+			if E.IsClosed(err) || E.IsTimeout(err) {
+				return nil
+			}
+			return err
+		}
+		if queryLength == 0 {
+			return dns.RCodeFormatError
+		}
+		message := make([]byte, queryLength)
+		_, err = io.ReadFull(conn, message)
+		if err != nil {
+			return err
+		}
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			response, err := d.exchange(ctx, metadata, message, 0)
+			if err != nil {
+				d.NewError(ctx, err)
+				conn.Close()
+				return
+			}
+			_buffer := buf.StackNewSize(2 + len(response))
+			defer common.KeepAlive(_buffer)
+			buffer := common.Dup(_buffer)
+			defer buffer.Release()
+			common.Must(binary.Write(buffer, binary.BigEndian, uint16(len(response))))
+			common.Must1(buffer.Write(response))
+			_, err = conn.Write(buffer.Bytes())
+			if err != nil {
+				d.NewError(ctx, err)
+			}
+		}()
+	}
+}
+
+func (d *DNS) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	message := append([]byte(nil), buffer.Bytes()...)
+	go func() {
+		ctx := log.ContextWithNewID(ctx)
+		response, err := d.exchange(ctx, metadata, message, dnsUDPSize)
+		if err != nil {
+			d.NewError(ctx, E.Cause(err, "process packet from ", metadata.Source))
+			return
+		}
+		responseBuffer := buf.NewPacket()
+		common.Must1(responseBuffer.Write(response))
+		err = conn.WritePacket(responseBuffer, metadata.Source)
+		if err != nil {
+			d.NewError(ctx, E.Cause(err, "write back to ", metadata.Source))
+		}
+	}()
+	return nil
+}
+
+// ServeHTTP serves DNS over HTTPS queries in both the GET and the POST form of RFC 8484.
+func (d *DNS) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := log.ContextWithNewID(request.Context())
+	var message []byte
+	var err error
+	switch request.Method {
+	case http.MethodGet:
+		message, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+		if err != nil || len(message) == 0 {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if request.Header.Get("Content-Type") != dnsMessageMIME {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		message, err = io.ReadAll(io.LimitReader(request.Body, dns.FixedPacketSize))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var metadata adapter.InboundContext
+	metadata.Inbound = d.tag
+	metadata.InboundType = d.protocol
+	metadata.Source = M.ParseSocksaddr(request.RemoteAddr)
+	if localAddr, loaded := request.Context().Value(http.LocalAddrContextKey).(net.Addr); loaded {
+		metadata.Destination = M.SocksaddrFromNet(localAddr)
+	}
+	response, err := d.exchange(ctx, metadata, message, 0)
+	if err != nil {
+		d.NewError(ctx, E.Cause(err, "process request from ", request.RemoteAddr))
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writer.Header().Set("Content-Type", dnsMessageMIME)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(response)))
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(response)
+}
+
+// exchange answers the packed query with the router's DNS engine, converting exchange errors to response codes.
+// If udpSize is not zero, responses larger than it, or than the EDNS0 payload size of the query, are truncated.
+func (d *DNS) exchange(ctx context.Context, metadata adapter.InboundContext, query []byte, udpSize int) ([]byte, error) {
+	var message dnsmessage.Message
+	err := message.Unpack(query)
+	if err != nil {
+		return nil, E.Cause(err, "unpack query")
+	}
+	response, err := d.router.Exchange(adapter.WithContext(ctx, &metadata), &message)
+	if err != nil {
+		rCode := dnsmessage.RCodeServerFailure
+		if rCodeError, isRCodeError := err.(dns.RCodeError); isRCodeError {
+			rCode = dnsmessage.RCode(rCodeError)
+		}
+		response = &dnsmessage.Message{
+			Header: dnsmessage.Header{
+				ID:               message.ID,
+				Response:         true,
+				RecursionDesired: message.RecursionDesired,
+				RCode:            rCode,
+			},
+			Questions: message.Questions,
+		}
+	}
+	content, err := response.Pack()
+	if err != nil || udpSize == 0 {
+		return content, err
+	}
+	for _, resource := range message.Additionals {
+		if resource.Header.Type == dnsmessage.TypeOPT && int(resource.Header.Class) > udpSize {
+			udpSize = int(resource.Header.Class)
+		}
+	}
+	if len(content) <= udpSize {
+		return content, nil
+	}
+	// RFC 1035 4.2.1: set TC and let the client retry over TCP
+	truncated := dnsmessage.Message{
+		Header:    response.Header,
+		Questions: response.Questions,
+	}
+	truncated.Header.Truncated = true
+	for _, resource := range response.Additionals {
+		if resource.Header.Type == dnsmessage.TypeOPT {
+			truncated.Additionals = append(truncated.Additionals, resource)
+		}
+	}
+	return truncated.Pack()
+}
@@ -3,7 +3,6 @@
 package inbound
 
 import (
-	"bytes"
 	"context"
 	"sync"
 
@@ -16,7 +15,9 @@ import (
 	"github.com/sagernet/sing-box/transport/hysteria"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
@@ -27,7 +28,8 @@ type Hysteria struct {
 	myInboundAdapter
 	quicConfig   *quic.Config
 	tlsConfig    *TLSConfig
-	authKey      []byte
+	authUser     map[string]int
+	users        []option.HysteriaUser
 	xplusKey     []byte
 	sendBPS      uint64
 	recvBPS      uint64
@@ -60,11 +62,30 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 	if quicConfig.MaxIncomingStreams == 0 {
 		quicConfig.MaxIncomingStreams = hysteria.DefaultMaxIncomingStreams
 	}
-	var auth []byte
-	if len(options.Auth) > 0 {
-		auth = options.Auth
-	} else {
-		auth = []byte(options.AuthString)
+	users := options.Users
+	if len(users) == 0 {
+		users = []option.HysteriaUser{{
+			Auth:       options.Auth,
+			AuthString: options.AuthString,
+		}}
+	} else if len(options.Auth) > 0 || options.AuthString != "" {
+		return nil, E.New("auth and auth_str cannot be used with users")
+	}
+	authUser := make(map[string]int)
+	for index, user := range users {
+		var authKey string
+		if len(user.Auth) > 0 {
+			authKey = string(user.Auth)
+		} else {
+			authKey = user.AuthString
+		}
+		if len(options.Users) > 0 && authKey == "" {
+			return nil, E.New("missing auth for user ", index)
+		}
+		if _, loaded := authUser[authKey]; loaded {
+			return nil, E.New("duplicate auth for user ", index)
+		}
+		authUser[authKey] = index
 	}
 	var xplus []byte
 	if options.Obfs != "" {
@@ -104,7 +125,8 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 			listenOptions: options.ListenOptions,
 		},
 		quicConfig:  quicConfig,
-		authKey:     auth,
+		authUser:    authUser,
+		users:       options.Users,
 		xplusKey:    xplus,
 		sendBPS:     up,
 		recvBPS:     down,
@@ -174,12 +196,16 @@ func (h *Hysteria) accept(ctx context.Context, conn quic.Connection) error {
 	if err != nil {
 		return err
 	}
-	if !bytes.Equal(clientHello.Auth, h.authKey) {
+	userIndex, loaded := h.authUser[string(clientHello.Auth)]
+	if !loaded {
 		err = hysteria.WriteServerHello(controlStream, hysteria.ServerHello{
 			Message: "wrong password",
 		})
 		return E.Errors(E.New("wrong password: ", string(clientHello.Auth)), err)
 	}
+	if len(h.users) > 0 {
+		ctx = auth.ContextWithUser(ctx, userIndex)
+	}
 	if clientHello.SendBPS == 0 || clientHello.RecvBPS == 0 {
 		return E.New("invalid rate from client")
 	}
@@ -259,6 +285,16 @@ func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, strea
 	metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr())
 	metadata.OriginDestination = M.SocksaddrFromNet(conn.LocalAddr())
 	metadata.Destination = M.ParseSocksaddrHostPort(request.Host, request.Port)
+	var logPrefix string
+	if userIndex, loaded := auth.UserFromContext[int](ctx); loaded {
+		user := h.users[userIndex].Name
+		if user == "" {
+			user = F.ToString(userIndex)
+		} else {
+			metadata.User = user
+		}
+		logPrefix = F.ToString("[", user, "] ")
+	}
 
 	if !request.UDP {
 		err = hysteria.WriteServerResponse(stream, hysteria.ServerResponse{
@@ -267,10 +303,10 @@ func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, strea
 		if err != nil {
 			return err
 		}
-		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+		h.logger.InfoContext(ctx, logPrefix, "inbound connection to ", metadata.Destination)
 		return h.router.RouteConnection(ctx, hysteria.NewConn(stream, metadata.Destination), metadata)
 	} else {
-		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
+		h.logger.InfoContext(ctx, logPrefix, "inbound packet connection to ", metadata.Destination)
 		var id uint32
 		h.udpAccess.Lock()
 		id = h.udpSessionId
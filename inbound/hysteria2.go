@@ -0,0 +1,189 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing-box/transport/hysteria2"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.Inbound = (*Hysteria2)(nil)
+
+type Hysteria2 struct {
+	myInboundAdapter
+	tlsConfig *TLSConfig
+	service   *hysteria2.Service[int]
+	users     []option.Hysteria2User
+}
+
+func NewHysteria2(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (*Hysteria2, error) {
+	if options.TLS == nil || !options.TLS.Enabled {
+		return nil, C.ErrTLSRequired
+	}
+	var salamanderPassword string
+	if options.Obfs != nil {
+		if options.Obfs.Password == "" {
+			return nil, E.New("missing obfs password")
+		}
+		switch options.Obfs.Type {
+		case hysteria2.ObfsTypeSalamander:
+			salamanderPassword = options.Obfs.Password
+		default:
+			return nil, E.New("unknown obfs type: ", options.Obfs.Type)
+		}
+	}
+	masqueradeHandler, err := newHysteria2Masquerade(options.Masquerade)
+	if err != nil {
+		return nil, err
+	}
+	inbound := &Hysteria2{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeHysteria2,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		users: options.Users,
+	}
+	if len(options.TLS.ALPN) == 0 {
+		options.TLS.ALPN = []string{hysteria2.DefaultALPN}
+	}
+	inbound.tlsConfig, err = NewTLSConfig(ctx, logger, common.PtrValueOrDefault(options.TLS))
+	if err != nil {
+		return nil, err
+	}
+	service := hysteria2.NewService[int](hysteria2.ServiceOptions{
+		Context:               ctx,
+		SendBPS:               uint64(options.UpMbps) * hysteria.MbpsToBps,
+		ReceiveBPS:            uint64(options.DownMbps) * hysteria.MbpsToBps,
+		IgnoreClientBandwidth: options.IgnoreClientBandwidth,
+		SalamanderPassword:    salamanderPassword,
+		QUICConfig: &quic.Config{
+			InitialStreamReceiveWindow:     hysteria2.DefaultStreamReceiveWindow,
+			MaxStreamReceiveWindow:         hysteria2.DefaultStreamReceiveWindow,
+			InitialConnectionReceiveWindow: hysteria2.DefaultConnectionReceiveWindow,
+			MaxConnectionReceiveWindow:     hysteria2.DefaultConnectionReceiveWindow,
+			MaxIncomingStreams:             hysteria2.DefaultMaxIncomingStreams,
+			MaxIdleTimeout:                 hysteria2.MaxIdleTimeout,
+			KeepAlivePeriod:                hysteria2.KeepAlivePeriod,
+			DisablePathMTUDiscovery:        !(C.IsLinux || C.IsWindows),
+			EnableDatagrams:                true,
+		},
+		Handler: adapter.NewUpstreamHandler(adapter.InboundContext{
+			Inbound:                  tag,
+			InboundType:              C.TypeHysteria2,
+			SniffEnabled:             options.SniffEnabled,
+			SniffOverrideDestination: options.SniffOverrideDestination,
+			DomainStrategy:           dns.DomainStrategy(options.DomainStrategy),
+		}, inbound.newConnection, inbound.newPacketConnection, inbound),
+		MasqueradeHandler: masqueradeHandler,
+	})
+	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.Hysteria2User) int {
+		return index
+	}), common.Map(options.Users, func(it option.Hysteria2User) string {
+		return it.Password
+	}))
+	if err != nil {
+		return nil, err
+	}
+	inbound.service = service
+	return inbound, nil
+}
+
+// newHysteria2Masquerade serves a local directory for file URLs, or proxies to HTTP URLs.
+func newHysteria2Masquerade(masquerade string) (http.Handler, error) {
+	if masquerade == "" {
+		return nil, nil
+	}
+	masqueradeURL, err := url.Parse(masquerade)
+	if err != nil {
+		return nil, E.Cause(err, "parse masquerade URL")
+	}
+	switch masqueradeURL.Scheme {
+	case "file":
+		return http.FileServer(http.Dir(masqueradeURL.Path)), nil
+	case "http", "https":
+		proxy := httputil.NewSingleHostReverseProxy(masqueradeURL)
+		director := proxy.Director
+		proxy.Director = func(request *http.Request) {
+			director(request)
+			request.Host = masqueradeURL.Host
+		}
+		return proxy, nil
+	default:
+		return nil, E.New("unknown masquerade URL scheme: ", masqueradeURL.Scheme)
+	}
+}
+
+func (h *Hysteria2) Start() error {
+	packetConn, err := h.myInboundAdapter.ListenUDP()
+	if err != nil {
+		return err
+	}
+	err = h.tlsConfig.Start()
+	if err != nil {
+		return err
+	}
+	return h.service.Start(packetConn, h.tlsConfig.Config())
+}
+
+func (h *Hysteria2) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	ctx = log.ContextWithNewID(ctx)
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := h.users[userIndex].Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *Hysteria2) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	ctx = log.ContextWithNewID(ctx)
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := h.users[userIndex].Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	return h.router.RoutePacketConnection(ctx, conn, metadata)
+}
+
+func (h *Hysteria2) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.service,
+		common.PtrOrNil(h.tlsConfig),
+	)
+}
@@ -0,0 +1,181 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing-box/transport/tuic"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.Inbound = (*TUIC)(nil)
+
+type TUIC struct {
+	myInboundAdapter
+	quicConfig        *quic.Config
+	tlsConfig         *TLSConfig
+	service           *tuic.Service[int]
+	users             []option.TUICUser
+	congestionControl string
+	zeroRTTHandshake  bool
+	listener          io.Closer
+}
+
+func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (*TUIC, error) {
+	if options.TLS == nil || !options.TLS.Enabled {
+		return nil, C.ErrTLSRequired
+	}
+	err := tuic.CheckCongestionControl(options.CongestionControl)
+	if err != nil {
+		return nil, err
+	}
+	inbound := &TUIC{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeTUIC,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		quicConfig: &quic.Config{
+			DisablePathMTUDiscovery: !(C.IsLinux || C.IsWindows),
+			MaxIncomingStreams:      hysteria.DefaultMaxIncomingStreams,
+			MaxIncomingUniStreams:   hysteria.DefaultMaxIncomingStreams,
+			EnableDatagrams:         true,
+		},
+		users:             options.Users,
+		congestionControl: options.CongestionControl,
+		zeroRTTHandshake:  options.ZeroRTTHandshake,
+	}
+	service := tuic.NewService[int](adapter.NewUpstreamHandler(adapter.InboundContext{
+		Inbound:                  tag,
+		InboundType:              C.TypeTUIC,
+		SniffEnabled:             options.SniffEnabled,
+		SniffOverrideDestination: options.SniffOverrideDestination,
+		DomainStrategy:           dns.DomainStrategy(options.DomainStrategy),
+	}, inbound.newConnection, inbound.newPacketConnection, inbound), time.Duration(options.AuthTimeout))
+	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.TUICUser) int {
+		return index
+	}), common.Map(options.Users, func(it option.TUICUser) string {
+		return it.UUID
+	}), common.Map(options.Users, func(it option.TUICUser) string {
+		return it.Password
+	}))
+	if err != nil {
+		return nil, err
+	}
+	inbound.service = service
+	if len(options.TLS.ALPN) == 0 {
+		options.TLS.ALPN = []string{tuic.DefaultALPN}
+	}
+	inbound.tlsConfig, err = NewTLSConfig(ctx, logger, common.PtrValueOrDefault(options.TLS))
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func (h *TUIC) Start() error {
+	packetConn, err := h.myInboundAdapter.ListenUDP()
+	if err != nil {
+		return err
+	}
+	err = h.tlsConfig.Start()
+	if err != nil {
+		return err
+	}
+	if h.zeroRTTHandshake {
+		var listener quic.EarlyListener
+		listener, err = quic.ListenEarly(packetConn, h.tlsConfig.Config(), h.quicConfig)
+		if err != nil {
+			return err
+		}
+		h.listener = listener
+		go h.acceptLoop(func(ctx context.Context) (quic.Connection, error) {
+			return listener.Accept(ctx)
+		})
+	} else {
+		var listener quic.Listener
+		listener, err = quic.Listen(packetConn, h.tlsConfig.Config(), h.quicConfig)
+		if err != nil {
+			return err
+		}
+		h.listener = listener
+		go h.acceptLoop(listener.Accept)
+	}
+	return nil
+}
+
+func (h *TUIC) acceptLoop(accept func(ctx context.Context) (quic.Connection, error)) {
+	for {
+		ctx := log.ContextWithNewID(h.ctx)
+		conn, err := accept(ctx)
+		if err != nil {
+			return
+		}
+		h.logger.InfoContext(ctx, "inbound connection from ", conn.RemoteAddr())
+		tuic.SetCongestionControl(conn, h.congestionControl)
+		go func() {
+			hErr := h.service.NewConnection(ctx, conn)
+			if hErr != nil {
+				NewError(h.logger, ctx, E.Cause(hErr, "process connection from ", conn.RemoteAddr()))
+			}
+		}()
+	}
+}
+
+func (h *TUIC) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := h.users[userIndex].Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *TUIC) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := h.users[userIndex].Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	return h.router.RoutePacketConnection(ctx, conn, metadata)
+}
+
+func (h *TUIC) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.listener,
+		common.PtrOrNil(h.tlsConfig),
+	)
+}
@@ -8,5 +8,30 @@ type ClashAPIOptions struct {
 
 type SelectorOutboundOptions struct {
 	Outbounds []string `json:"outbounds"`
+	Providers []string `json:"providers,omitempty"`
 	Default   string   `json:"default,omitempty"`
 }
+
+type URLTestOutboundOptions struct {
+	Outbounds []string `json:"outbounds"`
+	Providers []string `json:"providers,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Interval  Duration `json:"interval,omitempty"`
+	Tolerance uint16   `json:"tolerance,omitempty"`
+}
+
+type FallbackOutboundOptions struct {
+	Outbounds []string `json:"outbounds"`
+	Providers []string `json:"providers,omitempty"`
+	Timeout   Duration `json:"timeout,omitempty"`
+	CoolDown  Duration `json:"cool_down,omitempty"`
+}
+
+type LoadBalanceOutboundOptions struct {
+	Outbounds []string `json:"outbounds"`
+	Providers []string `json:"providers,omitempty"`
+	Strategy  string   `json:"strategy,omitempty"`
+	HashKey   string   `json:"hash_key,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Interval  Duration `json:"interval,omitempty"`
+}
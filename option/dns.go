@@ -10,12 +10,44 @@ import (
 )
 
 type DNSOptions struct {
-	Servers []DNSServerOptions `json:"servers,omitempty"`
-	Rules   []DNSRule          `json:"rules,omitempty"`
-	Final   string             `json:"final,omitempty"`
+	Servers        []DNSServerOptions          `json:"servers,omitempty"`
+	Rules          []DNSRule                   `json:"rules,omitempty"`
+	Final          string                      `json:"final,omitempty"`
+	FakeIP         *DNSFakeIPOptions           `json:"fakeip,omitempty"`
+	Hosts          map[string]Listable[string] `json:"hosts,omitempty"`
+	HostsPath      Listable[string]            `json:"hosts_path,omitempty"`
+	Cache          *DNSCacheOptions            `json:"cache,omitempty"`
+	ReverseMapping bool                        `json:"reverse_mapping,omitempty"`
+	DNS64          *DNS64Options               `json:"dns64,omitempty"`
+	QueryLog       *DNSQueryLogOptions         `json:"query_log,omitempty"`
 	DNSClientOptions
 }
 
+type DNSFakeIPOptions struct {
+	Enabled    bool          `json:"enabled,omitempty"`
+	Inet4Range *ListenPrefix `json:"inet4_range,omitempty"`
+	Inet6Range *ListenPrefix `json:"inet6_range,omitempty"`
+}
+
+type DNS64Options struct {
+	Enabled bool          `json:"enabled,omitempty"`
+	Prefix  *ListenPrefix `json:"prefix,omitempty"`
+}
+
+type DNSQueryLogOptions struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+type DNSCacheOptions struct {
+	Enabled    bool     `json:"enabled,omitempty"`
+	Size       int      `json:"size,omitempty"`
+	Persist    bool     `json:"persist,omitempty"`
+	ServeStale bool     `json:"serve_stale,omitempty"`
+	StaleTTL   Duration `json:"stale_ttl,omitempty"`
+	Prefetch   bool     `json:"prefetch,omitempty"`
+}
+
 type DNSClientOptions struct {
 	Strategy      DomainStrategy `json:"strategy,omitempty"`
 	DisableCache  bool           `json:"disable_cache,omitempty"`
@@ -23,13 +55,18 @@ type DNSClientOptions struct {
 }
 
 type DNSServerOptions struct {
-	Tag                  string         `json:"tag,omitempty"`
-	Address              string         `json:"address"`
-	AddressResolver      string         `json:"address_resolver,omitempty"`
-	AddressStrategy      DomainStrategy `json:"address_strategy,omitempty"`
-	AddressFallbackDelay Duration       `json:"address_fallback_delay,omitempty"`
-	Strategy             DomainStrategy `json:"strategy,omitempty"`
-	Detour               string         `json:"detour,omitempty"`
+	Tag                  string           `json:"tag,omitempty"`
+	Address              string           `json:"address"`
+	AddressResolver      string           `json:"address_resolver,omitempty"`
+	AddressStrategy      DomainStrategy   `json:"address_strategy,omitempty"`
+	AddressFallbackDelay Duration         `json:"address_fallback_delay,omitempty"`
+	Strategy             DomainStrategy   `json:"strategy,omitempty"`
+	Detour               string           `json:"detour,omitempty"`
+	ClientSubnet         *ListenPrefix    `json:"client_subnet,omitempty"`
+	Servers              Listable[string] `json:"servers,omitempty"`
+	Delay                Duration         `json:"delay,omitempty"`
+	Trusted              string           `json:"trusted,omitempty"`
+	TrustedWait          Duration         `json:"trusted_wait,omitempty"`
 }
 
 type _DNSRule struct {
@@ -77,31 +114,37 @@ func (r *DNSRule) UnmarshalJSON(bytes []byte) error {
 }
 
 type DefaultDNSRule struct {
-	Inbound         Listable[string] `json:"inbound,omitempty"`
-	IPVersion       int              `json:"ip_version,omitempty"`
-	Network         string           `json:"network,omitempty"`
-	AuthUser        Listable[string] `json:"auth_user,omitempty"`
-	Protocol        Listable[string] `json:"protocol,omitempty"`
-	Domain          Listable[string] `json:"domain,omitempty"`
-	DomainSuffix    Listable[string] `json:"domain_suffix,omitempty"`
-	DomainKeyword   Listable[string] `json:"domain_keyword,omitempty"`
-	DomainRegex     Listable[string] `json:"domain_regex,omitempty"`
-	Geosite         Listable[string] `json:"geosite,omitempty"`
-	SourceGeoIP     Listable[string] `json:"source_geoip,omitempty"`
-	SourceIPCIDR    Listable[string] `json:"source_ip_cidr,omitempty"`
-	SourcePort      Listable[uint16] `json:"source_port,omitempty"`
-	SourcePortRange Listable[string] `json:"source_port_range,omitempty"`
-	Port            Listable[uint16] `json:"port,omitempty"`
-	PortRange       Listable[string] `json:"port_range,omitempty"`
-	ProcessName     Listable[string] `json:"process_name,omitempty"`
-	ProcessPath     Listable[string] `json:"process_path,omitempty"`
-	PackageName     Listable[string] `json:"package_name,omitempty"`
-	User            Listable[string] `json:"user,omitempty"`
-	UserID          Listable[int32]  `json:"user_id,omitempty"`
-	Outbound        Listable[string] `json:"outbound,omitempty"`
-	Invert          bool             `json:"invert,omitempty"`
-	Server          string           `json:"server,omitempty"`
-	DisableCache    bool             `json:"disable_cache,omitempty"`
+	Inbound         Listable[string]       `json:"inbound,omitempty"`
+	IPVersion       int                    `json:"ip_version,omitempty"`
+	QueryType       Listable[DNSQueryType] `json:"query_type,omitempty"`
+	Network         string                 `json:"network,omitempty"`
+	AuthUser        Listable[string]       `json:"auth_user,omitempty"`
+	Protocol        Listable[string]       `json:"protocol,omitempty"`
+	Domain          Listable[string]       `json:"domain,omitempty"`
+	DomainSuffix    Listable[string]       `json:"domain_suffix,omitempty"`
+	DomainKeyword   Listable[string]       `json:"domain_keyword,omitempty"`
+	DomainRegex     Listable[string]       `json:"domain_regex,omitempty"`
+	Geosite         Listable[string]       `json:"geosite,omitempty"`
+	SourceGeoIP     Listable[string]       `json:"source_geoip,omitempty"`
+	SourceIPCIDR    Listable[string]       `json:"source_ip_cidr,omitempty"`
+	SourcePort      Listable[uint16]       `json:"source_port,omitempty"`
+	SourcePortRange Listable[string]       `json:"source_port_range,omitempty"`
+	Port            Listable[uint16]       `json:"port,omitempty"`
+	PortRange       Listable[string]       `json:"port_range,omitempty"`
+	ProcessName     Listable[string]       `json:"process_name,omitempty"`
+	ProcessPath     Listable[string]       `json:"process_path,omitempty"`
+	PackageName     Listable[string]       `json:"package_name,omitempty"`
+	User            Listable[string]       `json:"user,omitempty"`
+	UserID          Listable[int32]        `json:"user_id,omitempty"`
+	Outbound        Listable[string]       `json:"outbound,omitempty"`
+	RuleSet         Listable[string]       `json:"rule_set,omitempty"`
+	GeoIP           Listable[string]       `json:"geoip,omitempty"`
+	IPCIDR          Listable[string]       `json:"ip_cidr,omitempty"`
+	Invert          bool                   `json:"invert,omitempty"`
+	Server          string                 `json:"server,omitempty"`
+	DisableCache    bool                   `json:"disable_cache,omitempty"`
+	Action          string                 `json:"action,omitempty"`
+	Answer          Listable[string]       `json:"answer,omitempty"`
 }
 
 func (r DefaultDNSRule) IsValid() bool {
@@ -109,17 +152,33 @@ func (r DefaultDNSRule) IsValid() bool {
 	defaultValue.Invert = r.Invert
 	defaultValue.Server = r.Server
 	defaultValue.DisableCache = r.DisableCache
+	defaultValue.Action = r.Action
+	defaultValue.Answer = r.Answer
 	return !reflect.DeepEqual(r, defaultValue)
 }
 
+// HasResponseItems reports whether the rule matches addresses in responses.
+func (r DefaultDNSRule) HasResponseItems() bool {
+	return len(r.GeoIP) > 0 || len(r.IPCIDR) > 0
+}
+
 type LogicalDNSRule struct {
 	Mode         string           `json:"mode"`
 	Rules        []DefaultDNSRule `json:"rules,omitempty"`
 	Invert       bool             `json:"invert,omitempty"`
 	Server       string           `json:"server,omitempty"`
 	DisableCache bool             `json:"disable_cache,omitempty"`
+	Answer       Listable[string] `json:"answer,omitempty"`
 }
 
 func (r LogicalDNSRule) IsValid() bool {
 	return len(r.Rules) > 0 && common.All(r.Rules, DefaultDNSRule.IsValid)
 }
+
+type DNSInboundOptions struct {
+	ListenOptions
+	Network NetworkList        `json:"network,omitempty"`
+	TLS     *InboundTLSOptions `json:"tls,omitempty"`
+	HTTP    bool               `json:"http,omitempty"`
+	Path    string             `json:"path,omitempty"`
+}
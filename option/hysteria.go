@@ -7,6 +7,7 @@ type HysteriaInboundOptions struct {
 	Down                string             `json:"down,omitempty"`
 	DownMbps            int                `json:"down_mbps,omitempty"`
 	Obfs                string             `json:"obfs,omitempty"`
+	Users               []HysteriaUser     `json:"users,omitempty"`
 	Auth                []byte             `json:"auth,omitempty"`
 	AuthString          string             `json:"auth_str,omitempty"`
 	ReceiveWindowConn   uint64             `json:"recv_window_conn,omitempty"`
@@ -16,9 +17,17 @@ type HysteriaInboundOptions struct {
 	TLS                 *InboundTLSOptions `json:"tls,omitempty"`
 }
 
+type HysteriaUser struct {
+	Name       string `json:"name,omitempty"`
+	Auth       []byte `json:"auth,omitempty"`
+	AuthString string `json:"auth_str,omitempty"`
+}
+
 type HysteriaOutboundOptions struct {
 	DialerOptions
 	ServerOptions
+	ServerPorts         Listable[string]    `json:"server_ports,omitempty"`
+	HopInterval         Duration            `json:"hop_interval,omitempty"`
 	Up                  string              `json:"up,omitempty"`
 	UpMbps              int                 `json:"up_mbps,omitempty"`
 	Down                string              `json:"down,omitempty"`
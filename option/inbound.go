@@ -18,10 +18,14 @@ type _Inbound struct {
 	MixedOptions       HTTPMixedInboundOptions   `json:"-"`
 	ShadowsocksOptions ShadowsocksInboundOptions `json:"-"`
 	VMessOptions       VMessInboundOptions       `json:"-"`
+	VLESSOptions       VLESSInboundOptions       `json:"-"`
 	TrojanOptions      TrojanInboundOptions      `json:"-"`
 	NaiveOptions       NaiveInboundOptions       `json:"-"`
 	HysteriaOptions    HysteriaInboundOptions    `json:"-"`
+	TUICOptions        TUICInboundOptions        `json:"-"`
+	Hysteria2Options   Hysteria2InboundOptions   `json:"-"`
 	ShadowTLSOptions   ShadowTLSInboundOptions   `json:"-"`
+	DNSOptions         DNSInboundOptions         `json:"-"`
 }
 
 type Inbound _Inbound
@@ -47,14 +51,22 @@ func (h Inbound) MarshalJSON() ([]byte, error) {
 		v = h.ShadowsocksOptions
 	case C.TypeVMess:
 		v = h.VMessOptions
+	case C.TypeVLESS:
+		v = h.VLESSOptions
 	case C.TypeTrojan:
 		v = h.TrojanOptions
 	case C.TypeNaive:
 		v = h.NaiveOptions
 	case C.TypeHysteria:
 		v = h.HysteriaOptions
+	case C.TypeTUIC:
+		v = h.TUICOptions
+	case C.TypeHysteria2:
+		v = h.Hysteria2Options
 	case C.TypeShadowTLS:
 		v = h.ShadowTLSOptions
+	case C.TypeDNS:
+		v = h.DNSOptions
 	default:
 		return nil, E.New("unknown inbound type: ", h.Type)
 	}
@@ -86,14 +98,22 @@ func (h *Inbound) UnmarshalJSON(bytes []byte) error {
 		v = &h.ShadowsocksOptions
 	case C.TypeVMess:
 		v = &h.VMessOptions
+	case C.TypeVLESS:
+		v = &h.VLESSOptions
 	case C.TypeTrojan:
 		v = &h.TrojanOptions
 	case C.TypeNaive:
 		v = &h.NaiveOptions
 	case C.TypeHysteria:
 		v = &h.HysteriaOptions
+	case C.TypeTUIC:
+		v = &h.TUICOptions
+	case C.TypeHysteria2:
+		v = &h.Hysteria2Options
 	case C.TypeShadowTLS:
 		v = &h.ShadowTLSOptions
+	case C.TypeDNS:
+		v = &h.DNSOptions
 	default:
 		return E.New("unknown inbound type: ", h.Type)
 	}
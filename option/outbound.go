@@ -15,13 +15,19 @@ type _Outbound struct {
 	HTTPOptions        HTTPOutboundOptions        `json:"-"`
 	ShadowsocksOptions ShadowsocksOutboundOptions `json:"-"`
 	VMessOptions       VMessOutboundOptions       `json:"-"`
+	VLESSOptions       VLESSOutboundOptions       `json:"-"`
 	TrojanOptions      TrojanOutboundOptions      `json:"-"`
 	WireGuardOptions   WireGuardOutboundOptions   `json:"-"`
 	HysteriaOptions    HysteriaOutboundOptions    `json:"-"`
+	TUICOptions        TUICOutboundOptions        `json:"-"`
+	Hysteria2Options   Hysteria2OutboundOptions   `json:"-"`
 	TorOptions         TorOutboundOptions         `json:"-"`
 	SSHOptions         SSHOutboundOptions         `json:"-"`
 	ShadowTLSOptions   ShadowTLSOutboundOptions   `json:"-"`
 	SelectorOptions    SelectorOutboundOptions    `json:"-"`
+	URLTestOptions     URLTestOutboundOptions     `json:"-"`
+	FallbackOptions    FallbackOutboundOptions    `json:"-"`
+	LoadBalanceOptions LoadBalanceOutboundOptions `json:"-"`
 }
 
 type Outbound _Outbound
@@ -41,12 +47,18 @@ func (h Outbound) MarshalJSON() ([]byte, error) {
 		v = h.ShadowsocksOptions
 	case C.TypeVMess:
 		v = h.VMessOptions
+	case C.TypeVLESS:
+		v = h.VLESSOptions
 	case C.TypeTrojan:
 		v = h.TrojanOptions
 	case C.TypeWireGuard:
 		v = h.WireGuardOptions
 	case C.TypeHysteria:
 		v = h.HysteriaOptions
+	case C.TypeTUIC:
+		v = h.TUICOptions
+	case C.TypeHysteria2:
+		v = h.Hysteria2Options
 	case C.TypeTor:
 		v = h.TorOptions
 	case C.TypeSSH:
@@ -55,6 +67,12 @@ func (h Outbound) MarshalJSON() ([]byte, error) {
 		v = h.ShadowTLSOptions
 	case C.TypeSelector:
 		v = h.SelectorOptions
+	case C.TypeURLTest:
+		v = h.URLTestOptions
+	case C.TypeFallback:
+		v = h.FallbackOptions
+	case C.TypeLoadBalance:
+		v = h.LoadBalanceOptions
 	default:
 		return nil, E.New("unknown outbound type: ", h.Type)
 	}
@@ -80,12 +98,18 @@ func (h *Outbound) UnmarshalJSON(bytes []byte) error {
 		v = &h.ShadowsocksOptions
 	case C.TypeVMess:
 		v = &h.VMessOptions
+	case C.TypeVLESS:
+		v = &h.VLESSOptions
 	case C.TypeTrojan:
 		v = &h.TrojanOptions
 	case C.TypeWireGuard:
 		v = &h.WireGuardOptions
 	case C.TypeHysteria:
 		v = &h.HysteriaOptions
+	case C.TypeTUIC:
+		v = &h.TUICOptions
+	case C.TypeHysteria2:
+		v = &h.Hysteria2Options
 	case C.TypeTor:
 		v = &h.TorOptions
 	case C.TypeSSH:
@@ -94,6 +118,12 @@ func (h *Outbound) UnmarshalJSON(bytes []byte) error {
 		v = &h.ShadowTLSOptions
 	case C.TypeSelector:
 		v = &h.SelectorOptions
+	case C.TypeURLTest:
+		v = &h.URLTestOptions
+	case C.TypeFallback:
+		v = &h.FallbackOptions
+	case C.TypeLoadBalance:
+		v = &h.LoadBalanceOptions
 	default:
 		return E.New("unknown outbound type: ", h.Type)
 	}
@@ -0,0 +1,11 @@
+package option
+
+type OutboundProvider struct {
+	Tag            string   `json:"tag"`
+	Type           string   `json:"type"`
+	Path           string   `json:"path,omitempty"`
+	URL            string   `json:"url,omitempty"`
+	DownloadDetour string   `json:"download_detour,omitempty"`
+	UpdateInterval Duration `json:"update_interval,omitempty"`
+	HealthCheckURL string   `json:"healthcheck_url,omitempty"`
+}
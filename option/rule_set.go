@@ -0,0 +1,15 @@
+package option
+
+type RuleSet struct {
+	Tag            string   `json:"tag"`
+	Type           string   `json:"type"`
+	Format         string   `json:"format,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	URL            string   `json:"url,omitempty"`
+	DownloadDetour string   `json:"download_detour,omitempty"`
+	UpdateInterval Duration `json:"update_interval,omitempty"`
+}
+
+type PlainRuleSet struct {
+	Rules []DefaultRule `json:"rules"`
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sagernet/sing-box/common/json"
 	"github.com/sagernet/sing-dns"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	N "github.com/sagernet/sing/common/network"
 )
 
@@ -190,3 +191,67 @@ func (p *ListenPrefix) Build() netip.Prefix {
 	}
 	return netip.Prefix(*p)
 }
+
+var dnsQueryTypeNames = map[uint16]string{
+	1:   "A",
+	2:   "NS",
+	5:   "CNAME",
+	6:   "SOA",
+	12:  "PTR",
+	15:  "MX",
+	16:  "TXT",
+	28:  "AAAA",
+	33:  "SRV",
+	35:  "NAPTR",
+	43:  "DS",
+	46:  "RRSIG",
+	47:  "NSEC",
+	48:  "DNSKEY",
+	64:  "SVCB",
+	65:  "HTTPS",
+	255: "ANY",
+	257: "CAA",
+}
+
+// DNSQueryType is a query type, either by name like `AAAA` or by number.
+type DNSQueryType uint16
+
+func (t DNSQueryType) String() string {
+	if name, loaded := dnsQueryTypeNames[uint16(t)]; loaded {
+		return name
+	}
+	return F.ToString(uint16(t))
+}
+
+func (t DNSQueryType) MarshalJSON() ([]byte, error) {
+	if name, loaded := dnsQueryTypeNames[uint16(t)]; loaded {
+		return json.Marshal(name)
+	}
+	return json.Marshal(uint16(t))
+}
+
+func (t *DNSQueryType) UnmarshalJSON(bytes []byte) error {
+	var valueNumber uint16
+	err := json.Unmarshal(bytes, &valueNumber)
+	if err == nil {
+		*t = DNSQueryType(valueNumber)
+		return nil
+	}
+	var valueString string
+	err = json.Unmarshal(bytes, &valueString)
+	if err != nil {
+		return err
+	}
+	*t, err = ParseDNSQueryType(valueString)
+	return err
+}
+
+// ParseDNSQueryType parses a query type name, case-insensitively.
+func ParseDNSQueryType(value string) (DNSQueryType, error) {
+	for queryType, name := range dnsQueryTypeNames {
+		if strings.EqualFold(name, value) {
+			return DNSQueryType(queryType), nil
+		}
+	}
+	return 0, E.New("unknown DNS query type: ", value)
+}
@@ -0,0 +1,24 @@
+package option
+
+type VLESSInboundOptions struct {
+	ListenOptions
+	Users     []VLESSUser            `json:"users,omitempty"`
+	TLS       *InboundTLSOptions     `json:"tls,omitempty"`
+	Transport *V2RayTransportOptions `json:"transport,omitempty"`
+}
+
+type VLESSUser struct {
+	Name string `json:"name"`
+	UUID string `json:"uuid"`
+}
+
+type VLESSOutboundOptions struct {
+	DialerOptions
+	ServerOptions
+	UUID       string                 `json:"uuid"`
+	Network    NetworkList            `json:"network,omitempty"`
+	TLS        *OutboundTLSOptions    `json:"tls,omitempty"`
+	PacketAddr bool                   `json:"packet_addr,omitempty"`
+	Multiplex  *MultiplexOptions      `json:"multiplex,omitempty"`
+	Transport  *V2RayTransportOptions `json:"transport,omitempty"`
+}
@@ -0,0 +1,258 @@
+package outbound
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound      = (*Fallback)(nil)
+	_ adapter.OutboundGroup = (*Fallback)(nil)
+)
+
+type Fallback struct {
+	myOutboundAdapter
+	tags         []string
+	providerTags []string
+	timeout      time.Duration
+	coolDown     time.Duration
+	providers    []adapter.OutboundProvider
+	access       sync.RWMutex
+	outbounds    []adapter.Outbound
+	failedAt     map[string]time.Time
+	selected     adapter.Outbound
+}
+
+func NewFallback(router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (*Fallback, error) {
+	outbound := &Fallback{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeFallback,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		tags:         options.Outbounds,
+		providerTags: options.Providers,
+		timeout:      time.Duration(options.Timeout),
+		coolDown:     time.Duration(options.CoolDown),
+		failedAt:     make(map[string]time.Time),
+	}
+	if len(outbound.tags) == 0 && len(outbound.providerTags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	if outbound.timeout == 0 {
+		outbound.timeout = C.TCPTimeout
+	}
+	if outbound.coolDown == 0 {
+		outbound.coolDown = time.Minute
+	}
+	return outbound, nil
+}
+
+func (s *Fallback) Network() []string {
+	outbounds := s.currentOutbounds()
+	if len(outbounds) == 0 {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	var networks []string
+	for _, detour := range outbounds {
+		for _, network := range detour.Network() {
+			if !common.Contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	return networks
+}
+
+func (s *Fallback) Start() error {
+	providers, err := loadGroupProviders(s.router, s.providerTags)
+	if err != nil {
+		return err
+	}
+	s.providers = providers
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		return err
+	}
+	s.outbounds = outbounds
+	for _, provider := range s.providers {
+		provider.RegisterCallback(s.onProviderUpdated)
+	}
+	return nil
+}
+
+func (s *Fallback) onProviderUpdated(provider adapter.OutboundProvider) {
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		s.logger.Error("update outbounds from provider ", provider.Tag(), ": ", err)
+		return
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.outbounds = outbounds
+	if s.selected != nil && !common.Contains(outboundTags(outbounds), s.selected.Tag()) {
+		s.selected = nil
+	}
+}
+
+func (s *Fallback) currentOutbounds() []adapter.Outbound {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.outbounds
+}
+
+func (s *Fallback) Now() string {
+	s.access.RLock()
+	selected := s.selected
+	s.access.RUnlock()
+	if selected != nil {
+		return selected.Tag()
+	}
+	candidates := s.candidates(N.NetworkTCP)
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].Tag()
+}
+
+func (s *Fallback) All() []string {
+	return outboundTags(s.currentOutbounds())
+}
+
+func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	var errors []error
+	for _, detour := range s.candidates(N.NetworkName(network)) {
+		detour := detour
+		conn, err := dialWithTimeout(ctx, s.timeout, func() (net.Conn, error) {
+			return detour.DialContext(ctx, network, destination)
+		})
+		if err == nil {
+			s.markAvailable(detour)
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.markUnavailable(ctx, detour, err)
+		errors = append(errors, err)
+	}
+	if len(errors) == 0 {
+		return nil, E.New("missing supported outbound for network: ", network)
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (s *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	var errors []error
+	for _, detour := range s.candidates(N.NetworkUDP) {
+		detour := detour
+		conn, err := dialWithTimeout(ctx, s.timeout, func() (net.PacketConn, error) {
+			return detour.ListenPacket(ctx, destination)
+		})
+		if err == nil {
+			s.markAvailable(detour)
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.markUnavailable(ctx, detour, err)
+		errors = append(errors, err)
+	}
+	if len(errors) == 0 {
+		return nil, E.New("missing supported outbound for network: ", N.NetworkUDP)
+	}
+	return nil, E.Errors(errors...)
+}
+
+type dialResult[T io.Closer] struct {
+	conn T
+	err  error
+}
+
+// dialWithTimeout dials with the parent context, as some transports keep the dial context for the whole connection,
+// and gives up after timeout. A connection established after giving up is closed.
+func dialWithTimeout[T io.Closer](ctx context.Context, timeout time.Duration, dial func() (T, error)) (T, error) {
+	done := make(chan dialResult[T], 1)
+	go func() {
+		conn, err := dial()
+		done <- dialResult[T]{conn, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case result := <-done:
+		return result.conn, result.err
+	case <-timer.C:
+		err = E.New("dial timeout after ", timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	go func() {
+		result := <-done
+		if result.err == nil {
+			result.conn.Close()
+		}
+	}()
+	var conn T
+	return conn, err
+}
+
+func (s *Fallback) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
+
+// candidates returns the members supporting the network in order, with members still cooling down moved to the end.
+func (s *Fallback) candidates(network string) []adapter.Outbound {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	var available, unavailable []adapter.Outbound
+	for _, detour := range s.outbounds {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if failedAt, failed := s.failedAt[detour.Tag()]; failed && time.Since(failedAt) < s.coolDown {
+			unavailable = append(unavailable, detour)
+		} else {
+			available = append(available, detour)
+		}
+	}
+	return append(available, unavailable...)
+}
+
+func (s *Fallback) markAvailable(detour adapter.Outbound) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	delete(s.failedAt, detour.Tag())
+	if s.selected != detour {
+		if s.selected != nil {
+			s.logger.Info("switched to ", detour.Tag(), " from ", s.selected.Tag())
+		}
+		s.selected = detour
+	}
+}
+
+func (s *Fallback) markUnavailable(ctx context.Context, detour adapter.Outbound, err error) {
+	s.logger.DebugContext(ctx, "outbound ", detour.Tag(), " unavailable: ", err)
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.failedAt[detour.Tag()] = time.Now()
+}
@@ -0,0 +1,42 @@
+package outbound
+
+import (
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func loadGroupProviders(router adapter.Router, tags []string) ([]adapter.OutboundProvider, error) {
+	providers := make([]adapter.OutboundProvider, 0, len(tags))
+	for i, tag := range tags {
+		provider, loaded := router.OutboundProvider(tag)
+		if !loaded {
+			return nil, E.New("outbound provider ", i, " not found: ", tag)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// loadGroupOutbounds returns the outbounds listed in the group, followed by the current outbounds of its providers.
+func loadGroupOutbounds(router adapter.Router, tags []string, providers []adapter.OutboundProvider) ([]adapter.Outbound, error) {
+	outbounds := make([]adapter.Outbound, 0, len(tags))
+	for i, tag := range tags {
+		detour, loaded := router.Outbound(tag)
+		if !loaded {
+			return nil, E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	for _, provider := range providers {
+		outbounds = append(outbounds, provider.Outbounds()...)
+	}
+	return outbounds, nil
+}
+
+func outboundTags(outbounds []adapter.Outbound) []string {
+	tags := make([]string, 0, len(outbounds))
+	for _, detour := range outbounds {
+		tags = append(tags, detour.Tag())
+	}
+	return tags
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/tls"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/sagernet/quic-go"
 	"github.com/sagernet/quic-go/congestion"
@@ -30,6 +31,8 @@ type Hysteria struct {
 	ctx          context.Context
 	dialer       N.Dialer
 	serverAddr   M.Socksaddr
+	serverPorts  []uint16
+	hopInterval  time.Duration
 	tlsConfig    *tls.Config
 	quicConfig   *quic.Config
 	authKey      []byte
@@ -85,6 +88,16 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 	if options.Obfs != "" {
 		xplus = []byte(options.Obfs)
 	}
+	serverPorts, err := hysteria.ParsePortRanges(options.ServerPorts)
+	if err != nil {
+		return nil, err
+	}
+	hopInterval := time.Duration(options.HopInterval)
+	if hopInterval == 0 {
+		hopInterval = hysteria.DefaultHopInterval
+	} else if hopInterval < 0 {
+		return nil, E.New("invalid hop interval: ", hopInterval)
+	}
 	var up, down uint64
 	if len(options.Up) > 0 {
 		up = hysteria.StringToBps(options.Up)
@@ -116,15 +129,17 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 			logger:   logger,
 			tag:      tag,
 		},
-		ctx:        ctx,
-		dialer:     dialer.New(router, options.DialerOptions),
-		serverAddr: options.ServerOptions.Build(),
-		tlsConfig:  tlsConfig,
-		quicConfig: quicConfig,
-		authKey:    auth,
-		xplusKey:   xplus,
-		sendBPS:    up,
-		recvBPS:    down,
+		ctx:         ctx,
+		dialer:      dialer.New(router, options.DialerOptions),
+		serverAddr:  options.ServerOptions.Build(),
+		serverPorts: serverPorts,
+		hopInterval: hopInterval,
+		tlsConfig:   tlsConfig,
+		quicConfig:  quicConfig,
+		authKey:     auth,
+		xplusKey:    xplus,
+		sendBPS:     up,
+		recvBPS:     down,
 	}, nil
 }
 
@@ -158,17 +173,31 @@ func (h *Hysteria) offer(ctx context.Context) (quic.Connection, error) {
 }
 
 func (h *Hysteria) offerNew(ctx context.Context) (quic.Connection, error) {
-	udpConn, err := h.dialer.DialContext(h.ctx, "udp", h.serverAddr)
-	if err != nil {
-		return nil, err
-	}
 	var packetConn net.PacketConn
-	packetConn = bufio.NewUnbindPacketConn(udpConn)
+	var remoteAddr net.Addr
+	if len(h.serverPorts) > 0 {
+		hopConn, err := hysteria.NewHopPacketConn(h.ctx, h.dialer, h.serverAddr, h.serverPorts, h.hopInterval)
+		if err != nil {
+			return nil, err
+		}
+		packetConn = hopConn
+		remoteAddr = hopConn.RemoteAddr()
+	} else {
+		udpConn, err := h.dialer.DialContext(h.ctx, "udp", h.serverAddr)
+		if err != nil {
+			return nil, err
+		}
+		packetConn = bufio.NewUnbindPacketConn(udpConn)
+		remoteAddr = udpConn.RemoteAddr()
+	}
 	if h.xplusKey != nil {
 		packetConn = hysteria.NewXPlusPacketConn(packetConn, h.xplusKey)
 	}
-	packetConn = &hysteria.PacketConnWrapper{PacketConn: packetConn}
-	quicConn, err := quic.Dial(packetConn, udpConn.RemoteAddr(), h.serverAddr.AddrString(), h.tlsConfig, h.quicConfig)
+	if len(h.serverPorts) == 0 {
+		// the hop conn has no single socket for quic to tune
+		packetConn = &hysteria.PacketConnWrapper{PacketConn: packetConn}
+	}
+	quicConn, err := quic.Dial(packetConn, remoteAddr, h.serverAddr.AddrString(), h.tlsConfig, h.quicConfig)
 	if err != nil {
 		packetConn.Close()
 		return nil, err
@@ -197,6 +226,11 @@ func (h *Hysteria) offerNew(ctx context.Context) (quic.Connection, error) {
 		return nil, E.New("remote error: ", serverHello.Message)
 	}
 	quicConn.SetCongestionControl(hysteria.NewBrutalSender(congestion.ByteCount(serverHello.RecvBPS)))
+	go func() {
+		// quic does not close a packet conn it did not create
+		<-quicConn.Context().Done()
+		packetConn.Close()
+	}()
 	return quicConn, nil
 }
 
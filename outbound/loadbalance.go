@@ -0,0 +1,247 @@
+package outbound
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"go.uber.org/atomic"
+)
+
+var (
+	_ adapter.Outbound      = (*LoadBalance)(nil)
+	_ adapter.OutboundGroup = (*LoadBalance)(nil)
+)
+
+type LoadBalance struct {
+	myOutboundAdapter
+	tags         []string
+	providerTags []string
+	strategy     string
+	hashKey      string
+	link         string
+	interval     time.Duration
+	providers    []adapter.OutboundProvider
+	group        *URLTestGroup
+	index        atomic.Uint32
+	access       sync.RWMutex
+	lastUsed     adapter.Outbound
+}
+
+func NewLoadBalance(router adapter.Router, logger log.ContextLogger, tag string, options option.LoadBalanceOutboundOptions) (*LoadBalance, error) {
+	outbound := &LoadBalance{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeLoadBalance,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		tags:         options.Outbounds,
+		providerTags: options.Providers,
+		strategy:     options.Strategy,
+		hashKey:      options.HashKey,
+		link:         options.URL,
+		interval:     time.Duration(options.Interval),
+	}
+	if len(outbound.tags) == 0 && len(outbound.providerTags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	switch outbound.strategy {
+	case "":
+		outbound.strategy = C.LoadBalanceStrategyRoundRobin
+	case C.LoadBalanceStrategyRoundRobin, C.LoadBalanceStrategyRandom, C.LoadBalanceStrategyConsistentHashing:
+	default:
+		return nil, E.New("unknown load balance strategy: ", outbound.strategy)
+	}
+	switch outbound.hashKey {
+	case "":
+		outbound.hashKey = C.LoadBalanceHashKeyDestination
+	case C.LoadBalanceHashKeyDestination, C.LoadBalanceHashKeySource:
+	default:
+		return nil, E.New("unknown load balance hash key: ", outbound.hashKey)
+	}
+	if outbound.link == "" {
+		outbound.link = "http://www.gstatic.com/generate_204"
+	}
+	if outbound.interval == 0 {
+		outbound.interval = C.DefaultURLTestInterval
+	}
+	return outbound, nil
+}
+
+func (s *LoadBalance) Network() []string {
+	if s.group == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	return s.group.Network()
+}
+
+func (s *LoadBalance) Start() error {
+	providers, err := loadGroupProviders(s.router, s.providerTags)
+	if err != nil {
+		return err
+	}
+	s.providers = providers
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		return err
+	}
+	s.group = NewURLTestGroup(s.router, s.logger, outbounds, s.link, s.interval, 0)
+	for _, provider := range s.providers {
+		provider.RegisterCallback(s.onProviderUpdated)
+	}
+	return s.group.Start()
+}
+
+func (s *LoadBalance) onProviderUpdated(provider adapter.OutboundProvider) {
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		s.logger.Error("update outbounds from provider ", provider.Tag(), ": ", err)
+		return
+	}
+	s.group.UpdateOutbounds(outbounds)
+	s.access.Lock()
+	s.lastUsed = nil
+	s.access.Unlock()
+}
+
+func (s *LoadBalance) Close() error {
+	if s.group == nil {
+		return nil
+	}
+	return s.group.Close()
+}
+
+func (s *LoadBalance) Now() string {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	if s.lastUsed == nil {
+		if all := s.All(); len(all) > 0 {
+			return all[0]
+		}
+		return ""
+	}
+	return s.lastUsed.Tag()
+}
+
+func (s *LoadBalance) All() []string {
+	if s.group == nil {
+		return s.tags
+	}
+	return outboundTags(s.group.Outbounds())
+}
+
+func (s *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	detour, err := s.selectOutbound(ctx, N.NetworkName(network), destination)
+	if err != nil {
+		return nil, err
+	}
+	return detour.DialContext(ctx, network, destination)
+}
+
+func (s *LoadBalance) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	detour, err := s.selectOutbound(ctx, N.NetworkUDP, destination)
+	if err != nil {
+		return nil, err
+	}
+	return detour.ListenPacket(ctx, destination)
+}
+
+func (s *LoadBalance) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	detour, err := s.selectOutbound(adapter.WithContext(ctx, &metadata), N.NetworkTCP, metadata.Destination)
+	if err != nil {
+		return err
+	}
+	return detour.NewConnection(ctx, conn, metadata)
+}
+
+func (s *LoadBalance) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	detour, err := s.selectOutbound(adapter.WithContext(ctx, &metadata), N.NetworkUDP, metadata.Destination)
+	if err != nil {
+		return err
+	}
+	return detour.NewPacketConnection(ctx, conn, metadata)
+}
+
+func (s *LoadBalance) selectOutbound(ctx context.Context, network string, destination M.Socksaddr) (adapter.Outbound, error) {
+	if s.group == nil {
+		return nil, E.New("load_balance not started")
+	}
+	candidates := s.group.Available(network)
+	if len(candidates) == 0 {
+		return nil, E.New("missing supported outbound for network: ", network)
+	}
+	var detour adapter.Outbound
+	switch s.strategy {
+	case C.LoadBalanceStrategyRoundRobin:
+		detour = candidates[int((s.index.Inc()-1)%uint32(len(candidates)))]
+	case C.LoadBalanceStrategyRandom:
+		detour = candidates[rand.Intn(len(candidates))]
+	case C.LoadBalanceStrategyConsistentHashing:
+		detour = selectByHash(candidates, s.keyFor(ctx, destination))
+	}
+	s.access.Lock()
+	s.lastUsed = detour
+	s.access.Unlock()
+	s.logger.DebugContext(ctx, "selected ", detour.Tag())
+	return detour, nil
+}
+
+func (s *LoadBalance) keyFor(ctx context.Context, destination M.Socksaddr) string {
+	metadata := adapter.ContextFrom(ctx)
+	switch s.hashKey {
+	case C.LoadBalanceHashKeySource:
+		if metadata != nil && metadata.Source.IsValid() {
+			return metadata.Source.Addr.String()
+		}
+	default:
+		if metadata != nil {
+			if metadata.Domain != "" {
+				return metadata.Domain
+			}
+			destination = metadata.Destination
+		}
+	}
+	if destination.IsFqdn() {
+		return destination.Fqdn
+	}
+	return destination.Addr.String()
+}
+
+// selectByHash uses rendezvous hashing, so only keys mapped to an unavailable outbound are moved.
+func selectByHash(outbounds []adapter.Outbound, key string) adapter.Outbound {
+	var maxScore uint64
+	var selected adapter.Outbound
+	for _, detour := range outbounds {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(detour.Tag()))
+		score := mixHash(hash.Sum64())
+		if selected == nil || score > maxScore {
+			maxScore = score
+			selected = detour
+		}
+	}
+	return selected
+}
+
+func mixHash(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
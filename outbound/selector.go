@@ -3,6 +3,7 @@ package outbound
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
@@ -20,10 +21,16 @@ var (
 
 type Selector struct {
 	myOutboundAdapter
-	tags       []string
-	defaultTag string
-	outbounds  map[string]adapter.Outbound
-	selected   adapter.Outbound
+	tags         []string
+	providerTags []string
+	defaultTag   string
+	providers    []adapter.OutboundProvider
+	access       sync.RWMutex
+	all          []string
+	outbounds    map[string]adapter.Outbound
+	selected     adapter.Outbound
+	// preferred is the cached or default tag not loaded yet, selected once a provider loads it
+	preferred string
 }
 
 func NewSelector(router adapter.Router, logger log.ContextLogger, tag string, options option.SelectorOutboundOptions) (*Selector, error) {
@@ -34,74 +41,206 @@ func NewSelector(router adapter.Router, logger log.ContextLogger, tag string, op
 			logger:   logger,
 			tag:      tag,
 		},
-		tags:       options.Outbounds,
-		defaultTag: options.Default,
-		outbounds:  make(map[string]adapter.Outbound),
+		tags:         options.Outbounds,
+		providerTags: options.Providers,
+		defaultTag:   options.Default,
+		outbounds:    make(map[string]adapter.Outbound),
 	}
-	if len(outbound.tags) == 0 {
+	if len(outbound.tags) == 0 && len(outbound.providerTags) == 0 {
 		return nil, E.New("missing tags")
 	}
 	return outbound, nil
 }
 
 func (s *Selector) Network() []string {
-	if s.selected == nil {
+	selected := s.current()
+	if selected == nil {
 		return []string{N.NetworkTCP, N.NetworkUDP}
 	}
-	return s.selected.Network()
+	return selected.Network()
 }
 
 func (s *Selector) Start() error {
-	for i, tag := range s.tags {
-		detour, loaded := s.router.Outbound(tag)
-		if !loaded {
-			return E.New("outbound ", i, " not found: ", tag)
+	providers, err := loadGroupProviders(s.router, s.providerTags)
+	if err != nil {
+		return err
+	}
+	s.providers = providers
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		return err
+	}
+	s.setOutbounds(outbounds)
+	for _, provider := range s.providers {
+		provider.RegisterCallback(s.onProviderUpdated)
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.tag != "" {
+		if cacheFile := s.router.CacheFile(); cacheFile != nil {
+			selected := cacheFile.LoadSelected(s.tag)
+			if detour, loaded := s.outbounds[selected]; loaded {
+				s.selected = detour
+				return nil
+			} else if selected != "" && len(s.providers) > 0 {
+				s.preferred = selected
+			}
 		}
-		s.outbounds[tag] = detour
 	}
 	if s.defaultTag != "" {
 		detour, loaded := s.outbounds[s.defaultTag]
-		if !loaded {
+		if loaded {
+			s.selected = detour
+			return nil
+		}
+		if len(s.providers) == 0 {
 			return E.New("default outbound not found: ", s.defaultTag)
 		}
-		s.selected = detour
-	} else {
-		s.selected = s.outbounds[s.tags[0]]
+		// providers are started after outbounds, or the default outbound was removed from a provider
+		if s.preferred == "" {
+			s.preferred = s.defaultTag
+		}
+	}
+	if len(s.all) > 0 {
+		s.selected = s.outbounds[s.all[0]]
 	}
 	return nil
 }
 
 func (s *Selector) Now() string {
-	return s.selected.Tag()
+	selected := s.current()
+	if selected == nil {
+		return ""
+	}
+	return selected.Tag()
 }
 
 func (s *Selector) All() []string {
-	return s.tags
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.all
 }
 
 func (s *Selector) SelectOutbound(tag string) bool {
+	s.access.Lock()
 	detour, loaded := s.outbounds[tag]
+	if loaded {
+		s.selected = detour
+		s.preferred = ""
+	}
+	s.access.Unlock()
 	if !loaded {
 		return false
 	}
-	s.selected = detour
+	if s.tag != "" {
+		if cacheFile := s.router.CacheFile(); cacheFile != nil {
+			err := cacheFile.StoreSelected(s.tag, tag)
+			if err != nil {
+				s.logger.Error("store selected: ", err)
+			}
+		}
+	}
 	return true
 }
 
 func (s *Selector) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	return s.selected.DialContext(ctx, network, destination)
+	selected, err := s.mustCurrent()
+	if err != nil {
+		return nil, err
+	}
+	return selected.DialContext(ctx, network, destination)
 }
 
 func (s *Selector) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	return s.selected.ListenPacket(ctx, destination)
+	selected, err := s.mustCurrent()
+	if err != nil {
+		return nil, err
+	}
+	return selected.ListenPacket(ctx, destination)
 }
 
 func (s *Selector) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
-	return s.selected.NewConnection(ctx, conn, metadata)
+	selected, err := s.mustCurrent()
+	if err != nil {
+		return err
+	}
+	return selected.NewConnection(ctx, conn, metadata)
 }
 
 func (s *Selector) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	return s.selected.NewPacketConnection(ctx, conn, metadata)
+	selected, err := s.mustCurrent()
+	if err != nil {
+		return err
+	}
+	return selected.NewPacketConnection(ctx, conn, metadata)
+}
+
+func (s *Selector) current() adapter.Outbound {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.selected
+}
+
+func (s *Selector) mustCurrent() (adapter.Outbound, error) {
+	selected := s.current()
+	if selected == nil {
+		return nil, E.New("missing selected outbound")
+	}
+	return selected, nil
+}
+
+func (s *Selector) setOutbounds(outbounds []adapter.Outbound) {
+	outboundMap := make(map[string]adapter.Outbound)
+	all := make([]string, 0, len(outbounds))
+	for _, detour := range outbounds {
+		if _, exists := outboundMap[detour.Tag()]; exists {
+			continue
+		}
+		outboundMap[detour.Tag()] = detour
+		all = append(all, detour.Tag())
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.outbounds = outboundMap
+	s.all = all
+	if s.preferred != "" {
+		if detour, loaded := outboundMap[s.preferred]; loaded {
+			s.selected = detour
+			s.preferred = ""
+			return
+		}
+	}
+	if s.selected == nil {
+		return
+	}
+	if detour, loaded := outboundMap[s.selected.Tag()]; loaded {
+		s.selected = detour
+	} else if detour, loaded = outboundMap[s.defaultTag]; loaded {
+		s.selected = detour
+	} else if len(all) > 0 {
+		s.selected = outboundMap[all[0]]
+	} else {
+		s.selected = nil
+	}
+}
+
+func (s *Selector) onProviderUpdated(provider adapter.OutboundProvider) {
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		s.logger.Error("update outbounds from provider ", provider.Tag(), ": ", err)
+		return
+	}
+	hadSelected := s.current() != nil
+	s.setOutbounds(outbounds)
+	if !hadSelected && len(outbounds) > 0 {
+		s.access.Lock()
+		if detour, loaded := s.outbounds[s.defaultTag]; loaded {
+			s.selected = detour
+		} else {
+			s.selected = s.outbounds[s.all[0]]
+		}
+		s.access.Unlock()
+	}
 }
 
 func RealTag(detour adapter.Outbound) string {
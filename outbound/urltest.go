@@ -0,0 +1,397 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/batch"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"go.uber.org/atomic"
+)
+
+var (
+	_ adapter.Outbound      = (*URLTest)(nil)
+	_ adapter.OutboundGroup = (*URLTest)(nil)
+)
+
+type URLTest struct {
+	myOutboundAdapter
+	tags         []string
+	providerTags []string
+	link         string
+	interval     time.Duration
+	tolerance    uint16
+	providers    []adapter.OutboundProvider
+	group        *URLTestGroup
+}
+
+func NewURLTest(router adapter.Router, logger log.ContextLogger, tag string, options option.URLTestOutboundOptions) (*URLTest, error) {
+	outbound := &URLTest{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeURLTest,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		tags:         options.Outbounds,
+		providerTags: options.Providers,
+		link:         options.URL,
+		interval:     time.Duration(options.Interval),
+		tolerance:    options.Tolerance,
+	}
+	if len(outbound.tags) == 0 && len(outbound.providerTags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	if outbound.link == "" {
+		outbound.link = "http://www.gstatic.com/generate_204"
+	}
+	if outbound.interval == 0 {
+		outbound.interval = C.DefaultURLTestInterval
+	}
+	if outbound.tolerance == 0 {
+		outbound.tolerance = 50
+	}
+	return outbound, nil
+}
+
+func (s *URLTest) Network() []string {
+	if s.group == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	return s.group.Network()
+}
+
+func (s *URLTest) Start() error {
+	providers, err := loadGroupProviders(s.router, s.providerTags)
+	if err != nil {
+		return err
+	}
+	s.providers = providers
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		return err
+	}
+	s.group = NewURLTestGroup(s.router, s.logger, outbounds, s.link, s.interval, s.tolerance)
+	for _, provider := range s.providers {
+		provider.RegisterCallback(s.onProviderUpdated)
+	}
+	return s.group.Start()
+}
+
+func (s *URLTest) onProviderUpdated(provider adapter.OutboundProvider) {
+	outbounds, err := loadGroupOutbounds(s.router, s.tags, s.providers)
+	if err != nil {
+		s.logger.Error("update outbounds from provider ", provider.Tag(), ": ", err)
+		return
+	}
+	s.group.UpdateOutbounds(outbounds)
+}
+
+func (s *URLTest) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.group),
+	)
+}
+
+func (s *URLTest) Now() string {
+	if s.group == nil {
+		// not started yet, report the first outbound like the group falls back to
+		if len(s.tags) > 0 {
+			return s.tags[0]
+		}
+		return ""
+	}
+	selected := s.group.Select(N.NetworkTCP)
+	if selected == nil {
+		return ""
+	}
+	return selected.Tag()
+}
+
+func (s *URLTest) All() []string {
+	if s.group == nil {
+		return s.tags
+	}
+	return outboundTags(s.group.Outbounds())
+}
+
+func (s *URLTest) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	selected, err := s.selectOutbound(N.NetworkName(network))
+	if err != nil {
+		return nil, err
+	}
+	return selected.DialContext(ctx, network, destination)
+}
+
+func (s *URLTest) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	selected, err := s.selectOutbound(N.NetworkUDP)
+	if err != nil {
+		return nil, err
+	}
+	return selected.ListenPacket(ctx, destination)
+}
+
+func (s *URLTest) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	selected, err := s.selectOutbound(N.NetworkTCP)
+	if err != nil {
+		return err
+	}
+	return selected.NewConnection(ctx, conn, metadata)
+}
+
+func (s *URLTest) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	selected, err := s.selectOutbound(N.NetworkUDP)
+	if err != nil {
+		return err
+	}
+	return selected.NewPacketConnection(ctx, conn, metadata)
+}
+
+func (s *URLTest) selectOutbound(network string) (adapter.Outbound, error) {
+	if s.group == nil {
+		return nil, E.New("urltest not started")
+	}
+	selected := s.group.Select(network)
+	if selected == nil {
+		return nil, E.New("missing supported outbound for network: ", network)
+	}
+	return selected, nil
+}
+
+type URLTestGroup struct {
+	router          adapter.Router
+	logger          log.Logger
+	outboundsAccess sync.RWMutex
+	outbounds       []adapter.Outbound
+	link            string
+	interval        time.Duration
+	tolerance       uint16
+	history         *urltest.HistoryStorage
+	storeHistory    bool
+	checking        atomic.Bool
+	access          sync.RWMutex
+	selectedTCP     adapter.Outbound
+	selectedUDP     adapter.Outbound
+	ticker          *time.Ticker
+	close           chan struct{}
+}
+
+func NewURLTestGroup(router adapter.Router, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16) *URLTestGroup {
+	var history *urltest.HistoryStorage
+	var storeHistory bool
+	if clashServer := router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	} else {
+		history = urltest.NewHistoryStorage()
+		if cacheFile := router.CacheFile(); cacheFile != nil {
+			err := cacheFile.LoadURLTestHistory(history)
+			if err != nil {
+				logger.Warn("load url test history: ", err)
+			}
+			storeHistory = true
+		}
+	}
+	return &URLTestGroup{
+		router:       router,
+		logger:       logger,
+		outbounds:    outbounds,
+		link:         link,
+		interval:     interval,
+		tolerance:    tolerance,
+		history:      history,
+		storeHistory: storeHistory,
+		close:        make(chan struct{}),
+	}
+}
+
+func (g *URLTestGroup) Start() error {
+	g.ticker = time.NewTicker(g.interval)
+	go g.loopCheck()
+	return nil
+}
+
+func (g *URLTestGroup) Close() error {
+	if g.ticker == nil {
+		return nil
+	}
+	g.ticker.Stop()
+	close(g.close)
+	if g.storeHistory {
+		return g.router.CacheFile().StoreURLTestHistory(g.history)
+	}
+	return nil
+}
+
+// Outbounds returns the current members of the group.
+func (g *URLTestGroup) Outbounds() []adapter.Outbound {
+	g.outboundsAccess.RLock()
+	defer g.outboundsAccess.RUnlock()
+	return g.outbounds
+}
+
+// UpdateOutbounds replaces the members of the group, and re-tests them in background.
+func (g *URLTestGroup) UpdateOutbounds(outbounds []adapter.Outbound) {
+	g.outboundsAccess.Lock()
+	g.outbounds = outbounds
+	g.outboundsAccess.Unlock()
+	g.access.Lock()
+	g.selectedTCP = nil
+	g.selectedUDP = nil
+	g.access.Unlock()
+	go g.CheckOutbounds()
+}
+
+func (g *URLTestGroup) Network() []string {
+	var networks []string
+	for _, detour := range g.Outbounds() {
+		for _, network := range detour.Network() {
+			if !common.Contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	return networks
+}
+
+func (g *URLTestGroup) Select(network string) adapter.Outbound {
+	g.access.RLock()
+	var selected adapter.Outbound
+	if network == N.NetworkTCP {
+		selected = g.selectedTCP
+	} else {
+		selected = g.selectedUDP
+	}
+	g.access.RUnlock()
+	if selected != nil {
+		return selected
+	}
+	return g.selectByDelay(network, nil)
+}
+
+// Available returns the outbounds supporting the network that passed the last test, or all of them if none did.
+func (g *URLTestGroup) Available(network string) []adapter.Outbound {
+	var available, all []adapter.Outbound
+	for _, detour := range g.Outbounds() {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		all = append(all, detour)
+		if g.history.LoadURLTestHistory(RealTag(detour)) != nil {
+			available = append(available, detour)
+		}
+	}
+	if len(available) == 0 {
+		return all
+	}
+	return available
+}
+
+func (g *URLTestGroup) selectByDelay(network string, current adapter.Outbound) adapter.Outbound {
+	outbounds := g.Outbounds()
+	if len(outbounds) == 0 {
+		return nil
+	}
+	var minDelay uint16
+	var minOutbound adapter.Outbound
+	for _, detour := range outbounds {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		history := g.history.LoadURLTestHistory(RealTag(detour))
+		if history == nil {
+			continue
+		}
+		if minOutbound == nil || history.Delay < minDelay {
+			minDelay = history.Delay
+			minOutbound = detour
+		}
+	}
+	if minOutbound == nil {
+		for _, detour := range outbounds {
+			if common.Contains(detour.Network(), network) {
+				return detour
+			}
+		}
+		return outbounds[0]
+	}
+	if current != nil && current != minOutbound {
+		history := g.history.LoadURLTestHistory(RealTag(current))
+		if history != nil && int(history.Delay) <= int(minDelay)+int(g.tolerance) {
+			return current
+		}
+	}
+	return minOutbound
+}
+
+func (g *URLTestGroup) loopCheck() {
+	g.CheckOutbounds()
+	for {
+		select {
+		case <-g.close:
+			return
+		case <-g.ticker.C:
+			g.CheckOutbounds()
+		}
+	}
+}
+
+func (g *URLTestGroup) CheckOutbounds() {
+	if !g.checking.CAS(false, true) {
+		return
+	}
+	defer g.checking.Store(false)
+	b, _ := batch.New(context.Background(), batch.WithConcurrencyNum[any](10))
+	checked := make(map[string]bool)
+	for _, detour := range g.Outbounds() {
+		tag := detour.Tag()
+		realTag := RealTag(detour)
+		if checked[realTag] {
+			continue
+		}
+		checked[realTag] = true
+		p, loaded := g.router.Outbound(realTag)
+		if !loaded {
+			continue
+		}
+		b.Go(realTag, func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(ctx, g.link, p)
+			if err != nil {
+				g.logger.Debug("outbound ", tag, " unavailable: ", err)
+				g.history.DeleteURLTestHistory(realTag)
+			} else {
+				g.logger.Debug("outbound ", tag, " available: ", t, "ms")
+				g.history.StoreURLTestHistory(realTag, &urltest.History{
+					Time:  time.Now(),
+					Delay: t,
+				})
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	g.performUpdateCheck()
+}
+
+func (g *URLTestGroup) performUpdateCheck() {
+	g.access.Lock()
+	defer g.access.Unlock()
+	selectedTCP := g.selectByDelay(N.NetworkTCP, g.selectedTCP)
+	if g.selectedTCP != nil && selectedTCP != nil && selectedTCP != g.selectedTCP {
+		g.logger.Info("switched to ", selectedTCP.Tag(), " from ", g.selectedTCP.Tag())
+	}
+	g.selectedTCP = selectedTCP
+	g.selectedUDP = g.selectByDelay(N.NetworkUDP, g.selectedUDP)
+}
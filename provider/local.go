@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/loader"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ adapter.OutboundProvider = (*LocalProvider)(nil)
+
+type LocalProvider struct {
+	abstractProvider
+	loader *loader.Local
+}
+
+func NewLocalProvider(ctx context.Context, router adapter.Router, logFactory log.Factory, options option.OutboundProvider) *LocalProvider {
+	provider := &LocalProvider{
+		abstractProvider: abstractProvider{
+			ctx:            ctx,
+			router:         router,
+			logFactory:     logFactory,
+			logger:         logFactory.NewLogger(F.ToString("provider/", options.Type, "[", options.Tag, "]")),
+			tag:            options.Tag,
+			healthCheckURL: options.HealthCheckURL,
+			history:        urltest.NewHistoryStorage(),
+			outboundByTag:  make(map[string]adapter.Outbound),
+		},
+	}
+	provider.instance = provider
+	if provider.healthCheckURL == "" {
+		provider.healthCheckURL = "http://www.gstatic.com/generate_204"
+	}
+	provider.loader = loader.NewLocal(provider.loaderOptions(time.Duration(options.UpdateInterval)), options.Path)
+	return provider
+}
+
+func (p *LocalProvider) Type() string {
+	return C.OutboundProviderTypeLocal
+}
+
+func (p *LocalProvider) Start() error {
+	return p.loader.Start()
+}
+
+func (p *LocalProvider) Close() error {
+	p.loader.Close()
+	return p.abstractProvider.Close()
+}
+
+func (p *LocalProvider) Update(ctx context.Context) error {
+	return p.loader.Update(ctx)
+}
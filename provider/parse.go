@@ -0,0 +1,381 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/json"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type outboundList struct {
+	Outbounds []option.Outbound `json:"outbounds"`
+}
+
+// parseContent accepts a sing-box configuration containing outbounds, or a list of share links,
+// which may be base64 encoded as a whole.
+func (p *abstractProvider) parseContent(content []byte) ([]option.Outbound, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, E.New("empty content")
+	}
+	if content[0] == '{' {
+		var list outboundList
+		err := json.Unmarshal(content, &list)
+		if err != nil {
+			return nil, E.Cause(err, "decode outbounds")
+		}
+		return list.Outbounds, nil
+	}
+	if decoded, err := decodeBase64(strings.Join(strings.Fields(string(content)), "")); err == nil && bytes.Contains(decoded, []byte("://")) {
+		content = decoded
+	}
+	var outbounds []option.Outbound
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		outbound, err := parseLink(line)
+		if err != nil {
+			p.logger.Warn("ignoring line ", i+1, ": ", err)
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	if len(outbounds) == 0 {
+		return nil, E.New("no supported share link found")
+	}
+	return outbounds, nil
+}
+
+func parseLink(link string) (option.Outbound, error) {
+	scheme, _, found := strings.Cut(link, "://")
+	if !found {
+		return option.Outbound{}, E.New("invalid share link")
+	}
+	switch strings.ToLower(scheme) {
+	case "ss":
+		return parseShadowsocksLink(link)
+	case "vmess":
+		return parseVMessLink(link)
+	case "vless":
+		return parseVLESSLink(link)
+	case "trojan":
+		return parseTrojanLink(link)
+	default:
+		return option.Outbound{}, E.New("unsupported share link scheme: ", scheme)
+	}
+}
+
+// parseShadowsocksLink parses SIP002 links, and the legacy form with the whole user info encoded.
+func parseShadowsocksLink(link string) (option.Outbound, error) {
+	body, fragment, _ := strings.Cut(strings.TrimPrefix(link, "ss://"), "#")
+	if !strings.Contains(body, "@") {
+		decoded, err := decodeBase64(body)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode shadowsocks link")
+		}
+		body = string(decoded)
+	}
+	linkURL, err := url.Parse("ss://" + body)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	linkURL.Fragment, err = url.PathUnescape(fragment)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if linkURL.User == nil {
+		return option.Outbound{}, E.New("missing shadowsocks user info")
+	}
+	if linkURL.Query().Get("plugin") != "" {
+		return option.Outbound{}, E.New("shadowsocks plugin is not supported")
+	}
+	var method, password string
+	if userPassword, hasPassword := linkURL.User.Password(); hasPassword {
+		method = linkURL.User.Username()
+		password = userPassword
+	} else {
+		decoded, err := decodeBase64(linkURL.User.Username())
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode shadowsocks user info")
+		}
+		var found bool
+		method, password, found = strings.Cut(string(decoded), ":")
+		if !found {
+			return option.Outbound{}, E.New("invalid shadowsocks user info")
+		}
+	}
+	serverOptions, err := parseServer(linkURL.Hostname(), linkURL.Port())
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return option.Outbound{
+		Type: C.TypeShadowsocks,
+		Tag:  linkURL.Fragment,
+		ShadowsocksOptions: option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        method,
+			Password:      password,
+		},
+	}, nil
+}
+
+type vmessLink struct {
+	Name     string `json:"ps"`
+	Address  string `json:"add"`
+	Port     any    `json:"port"`
+	ID       string `json:"id"`
+	AlterID  any    `json:"aid"`
+	Security string `json:"scy"`
+	Network  string `json:"net"`
+	Type     string `json:"type"`
+	Host     string `json:"host"`
+	Path     string `json:"path"`
+	TLS      string `json:"tls"`
+	SNI      string `json:"sni"`
+	ALPN     string `json:"alpn"`
+}
+
+// parseVMessLink parses links in the v2rayN format, a base64 encoded JSON object.
+func parseVMessLink(link string) (option.Outbound, error) {
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode vmess link")
+	}
+	var vmess vmessLink
+	err = json.Unmarshal(decoded, &vmess)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode vmess link")
+	}
+	serverOptions, err := parseServer(vmess.Address, anyToString(vmess.Port))
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	options := option.VMessOutboundOptions{
+		ServerOptions: serverOptions,
+		UUID:          vmess.ID,
+		Security:      vmess.Security,
+	}
+	if options.Security == "" {
+		options.Security = "auto"
+	}
+	if alterID := anyToString(vmess.AlterID); alterID != "" {
+		options.AlterId, err = strconv.Atoi(alterID)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "parse alter id")
+		}
+	}
+	if vmess.TLS == "tls" {
+		options.TLS = &option.OutboundTLSOptions{
+			Enabled:    true,
+			ServerName: vmess.SNI,
+		}
+		if options.TLS.ServerName == "" {
+			options.TLS.ServerName = vmess.Host
+		}
+		if vmess.ALPN != "" {
+			options.TLS.ALPN = strings.Split(vmess.ALPN, ",")
+		}
+	}
+	options.Transport, err = parseTransport(vmess.Network, vmess.Host, vmess.Path, vmess.Type)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return option.Outbound{
+		Type:         C.TypeVMess,
+		Tag:          vmess.Name,
+		VMessOptions: options,
+	}, nil
+}
+
+// parseVLESSLink parses links in the format proposed by the Xray project.
+func parseVLESSLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if linkURL.User == nil {
+		return option.Outbound{}, E.New("missing vless uuid")
+	}
+	serverOptions, err := parseServer(linkURL.Hostname(), linkURL.Port())
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	if flow := query.Get("flow"); flow != "" {
+		return option.Outbound{}, E.New("unsupported vless flow: ", flow)
+	}
+	options := option.VLESSOutboundOptions{
+		ServerOptions: serverOptions,
+		UUID:          linkURL.User.Username(),
+	}
+	switch security := query.Get("security"); security {
+	case "", "none":
+	case "tls":
+		options.TLS = &option.OutboundTLSOptions{
+			Enabled:    true,
+			ServerName: query.Get("sni"),
+			Insecure:   query.Get("allowInsecure") == "1",
+		}
+		if alpn := query.Get("alpn"); alpn != "" {
+			options.TLS.ALPN = strings.Split(alpn, ",")
+		}
+	default:
+		return option.Outbound{}, E.New("unsupported vless security: ", security)
+	}
+	path := query.Get("path")
+	if serviceName := query.Get("serviceName"); serviceName != "" {
+		path = serviceName
+	}
+	options.Transport, err = parseTransport(query.Get("type"), query.Get("host"), path, query.Get("headerType"))
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return option.Outbound{
+		Type:         C.TypeVLESS,
+		Tag:          linkURL.Fragment,
+		VLESSOptions: options,
+	}, nil
+}
+
+func parseTrojanLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if linkURL.User == nil {
+		return option.Outbound{}, E.New("missing trojan password")
+	}
+	port := linkURL.Port()
+	if port == "" {
+		port = "443"
+	}
+	serverOptions, err := parseServer(linkURL.Hostname(), port)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	options := option.TrojanOutboundOptions{
+		ServerOptions: serverOptions,
+		Password:      linkURL.User.Username(),
+		TLS: &option.OutboundTLSOptions{
+			Enabled:    true,
+			ServerName: query.Get("sni"),
+			Insecure:   query.Get("allowInsecure") == "1",
+		},
+	}
+	if query.Get("security") == "none" {
+		options.TLS = nil
+	} else if alpn := query.Get("alpn"); alpn != "" {
+		options.TLS.ALPN = strings.Split(alpn, ",")
+	}
+	path := query.Get("path")
+	if serviceName := query.Get("serviceName"); serviceName != "" {
+		path = serviceName
+	}
+	options.Transport, err = parseTransport(query.Get("type"), query.Get("host"), path, "")
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return option.Outbound{
+		Type:          C.TypeTrojan,
+		Tag:           linkURL.Fragment,
+		TrojanOptions: options,
+	}, nil
+}
+
+func parseTransport(network string, host string, path string, headerType string) (*option.V2RayTransportOptions, error) {
+	switch network {
+	case "", "tcp":
+		if headerType == "http" {
+			return &option.V2RayTransportOptions{
+				Type: C.V2RayTransportTypeHTTP,
+				HTTPOptions: option.V2RayHTTPOptions{
+					Host: splitHost(host),
+					Path: path,
+				},
+			}, nil
+		}
+		return nil, nil
+	case "ws":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path: path,
+			},
+		}
+		if host != "" {
+			transport.WebsocketOptions.Headers = map[string]string{"Host": host}
+		}
+		return transport, nil
+	case "h2", "http":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: splitHost(host),
+				Path: path,
+			},
+		}, nil
+	case "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: path,
+			},
+		}, nil
+	case "quic":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeQUIC,
+		}, nil
+	default:
+		return nil, E.New("unsupported transport: ", network)
+	}
+}
+
+func parseServer(host string, port string) (option.ServerOptions, error) {
+	if host == "" {
+		return option.ServerOptions{}, E.New("missing server address")
+	}
+	serverPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return option.ServerOptions{}, E.Cause(err, "parse server port")
+	}
+	return option.ServerOptions{
+		Server:     host,
+		ServerPort: uint16(serverPort),
+	}, nil
+}
+
+func splitHost(host string) option.Listable[string] {
+	if host == "" {
+		return nil
+	}
+	return strings.Split(host, ",")
+}
+
+func anyToString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatInt(int64(v), 10)
+	default:
+		return ""
+	}
+}
+
+func decodeBase64(content string) ([]byte, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimRight(content, "=")
+	if strings.ContainsAny(content, "-_") {
+		return base64.RawURLEncoding.DecodeString(content)
+	}
+	return base64.RawStdEncoding.DecodeString(content)
+}
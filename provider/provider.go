@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/common/loader"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/batch"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+func New(ctx context.Context, router adapter.Router, logFactory log.Factory, options option.OutboundProvider) (adapter.OutboundProvider, error) {
+	if options.Tag == "" {
+		return nil, E.New("missing tag")
+	}
+	switch options.Type {
+	case C.OutboundProviderTypeLocal:
+		if options.Path == "" {
+			return nil, E.New("missing path")
+		}
+		return NewLocalProvider(ctx, router, logFactory, options), nil
+	case C.OutboundProviderTypeRemote:
+		if options.URL == "" {
+			return nil, E.New("missing url")
+		}
+		return NewRemoteProvider(ctx, router, logFactory, options), nil
+	default:
+		return nil, E.New("unknown outbound provider type: ", options.Type)
+	}
+}
+
+type abstractProvider struct {
+	ctx            context.Context
+	router         adapter.Router
+	logFactory     log.Factory
+	logger         log.ContextLogger
+	tag            string
+	healthCheckURL string
+	history        *urltest.HistoryStorage
+	access         sync.RWMutex
+	outbounds      []adapter.Outbound
+	outboundByTag  map[string]adapter.Outbound
+	optionsByTag   map[string][]byte
+	updatedAt      time.Time
+	callbackAccess sync.Mutex
+	callbacks      []adapter.OutboundProviderUpdateCallback
+	instance       adapter.OutboundProvider
+}
+
+func (p *abstractProvider) Tag() string {
+	return p.tag
+}
+
+func (p *abstractProvider) Outbounds() []adapter.Outbound {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.outbounds
+}
+
+func (p *abstractProvider) Outbound(tag string) (adapter.Outbound, bool) {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	detour, loaded := p.outboundByTag[tag]
+	return detour, loaded
+}
+
+func (p *abstractProvider) UpdatedAt() time.Time {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.updatedAt
+}
+
+func (p *abstractProvider) RegisterCallback(callback adapter.OutboundProviderUpdateCallback) {
+	p.callbackAccess.Lock()
+	defer p.callbackAccess.Unlock()
+	p.callbacks = append(p.callbacks, callback)
+}
+
+func (p *abstractProvider) HealthCheck(ctx context.Context) (map[string]uint16, error) {
+	history := p.history
+	if clashServer := p.router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	}
+	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
+	var resultAccess sync.Mutex
+	result := make(map[string]uint16)
+	for _, detour := range p.Outbounds() {
+		tag := detour.Tag()
+		detour := detour
+		b.Go(tag, func() (any, error) {
+			testCtx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(testCtx, p.healthCheckURL, detour)
+			if err != nil {
+				p.logger.Debug("outbound ", tag, " unavailable: ", err)
+				history.DeleteURLTestHistory(tag)
+			} else {
+				p.logger.Debug("outbound ", tag, " available: ", t, "ms")
+				history.StoreURLTestHistory(tag, &urltest.History{
+					Time:  time.Now(),
+					Delay: t,
+				})
+				resultAccess.Lock()
+				result[tag] = t
+				resultAccess.Unlock()
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	return result, nil
+}
+
+func (p *abstractProvider) Close() error {
+	p.access.Lock()
+	outbounds := p.outbounds
+	p.outbounds = nil
+	p.outboundByTag = make(map[string]adapter.Outbound)
+	p.optionsByTag = nil
+	p.access.Unlock()
+	return closeOutbounds(outbounds)
+}
+
+func (p *abstractProvider) setUpdatedAt(updatedAt time.Time) {
+	p.access.Lock()
+	defer p.access.Unlock()
+	p.updatedAt = updatedAt
+}
+
+func (p *abstractProvider) loaderOptions(updateInterval time.Duration) loader.Options {
+	if updateInterval == 0 {
+		updateInterval = C.DefaultOutboundProviderInterval
+	}
+	return loader.Options{
+		Logger:         p.logger,
+		Name:           "outbound provider " + p.tag,
+		UpdateInterval: updateInterval,
+		Load:           p.loadContent,
+		Describe: func() string {
+			return F.ToString(len(p.Outbounds()), " outbounds")
+		},
+		UpdatedAt:    p.UpdatedAt,
+		SetUpdatedAt: p.setUpdatedAt,
+	}
+}
+
+func (p *abstractProvider) loadContent(content []byte, updatedAt time.Time) error {
+	outboundOptions, err := p.parseContent(content)
+	if err != nil {
+		return err
+	}
+	outbounds, optionsByTag, err := p.buildOutbounds(outboundOptions)
+	if err != nil {
+		return err
+	}
+	outboundByTag := make(map[string]adapter.Outbound)
+	for _, detour := range outbounds {
+		outboundByTag[detour.Tag()] = detour
+	}
+	p.access.Lock()
+	oldOutbounds := p.outbounds
+	p.outbounds = outbounds
+	p.outboundByTag = outboundByTag
+	p.optionsByTag = optionsByTag
+	p.updatedAt = updatedAt
+	p.access.Unlock()
+	p.callbackAccess.Lock()
+	callbacks := p.callbacks
+	p.callbackAccess.Unlock()
+	for _, callback := range callbacks {
+		callback(p.instance)
+	}
+	// unchanged outbounds are kept with their connections, only removed or replaced ones are closed
+	removedOutbounds := make([]adapter.Outbound, 0, len(oldOutbounds))
+	for _, detour := range oldOutbounds {
+		if outboundByTag[detour.Tag()] != detour {
+			removedOutbounds = append(removedOutbounds, detour)
+		}
+	}
+	return closeOutbounds(removedOutbounds)
+}
+
+func (p *abstractProvider) buildOutbounds(outboundOptions []option.Outbound) ([]adapter.Outbound, map[string][]byte, error) {
+	outbounds := make([]adapter.Outbound, 0, len(outboundOptions))
+	optionsByTag := make(map[string][]byte)
+	for i, options := range outboundOptions {
+		switch options.Type {
+		case C.TypeSelector, C.TypeURLTest, C.TypeFallback, C.TypeLoadBalance:
+			p.logger.Warn("ignoring outbound[", i, "]: group outbound is not allowed in provider")
+			continue
+		}
+		if options.Tag == "" {
+			options.Tag = F.ToString(p.tag, "/", i)
+		}
+		if _, loaded := optionsByTag[options.Tag]; loaded {
+			p.logger.Warn("ignoring outbound[", i, "]: duplicate tag: ", options.Tag)
+			continue
+		}
+		if _, loaded := p.router.Outbound(options.Tag); loaded {
+			if _, isOwn := p.Outbound(options.Tag); !isOwn {
+				p.logger.Warn("ignoring outbound[", i, "]: tag already used: ", options.Tag)
+				continue
+			}
+		}
+		content, err := json.Marshal(options)
+		if err != nil {
+			p.logger.Warn("ignoring outbound[", i, "]: ", err)
+			continue
+		}
+		p.access.RLock()
+		detour, loaded := p.outboundByTag[options.Tag]
+		loaded = loaded && bytes.Equal(p.optionsByTag[options.Tag], content)
+		p.access.RUnlock()
+		if loaded {
+			optionsByTag[options.Tag] = content
+			outbounds = append(outbounds, detour)
+			continue
+		}
+		detour, err = outbound.New(
+			p.ctx,
+			p.router,
+			p.logFactory.NewLogger(F.ToString("outbound/", options.Type, "[", options.Tag, "]")),
+			options,
+		)
+		if err != nil {
+			p.logger.Warn("ignoring outbound[", i, "]: ", err)
+			continue
+		}
+		if starter, isStarter := detour.(common.Starter); isStarter {
+			err = starter.Start()
+			if err != nil {
+				common.Close(detour)
+				p.logger.Warn("ignoring outbound[", i, "]: start: ", err)
+				continue
+			}
+		}
+		optionsByTag[options.Tag] = content
+		outbounds = append(outbounds, detour)
+	}
+	if len(outbounds) == 0 && len(outboundOptions) > 0 {
+		return nil, nil, E.New("no usable outbound found")
+	}
+	return outbounds, optionsByTag, nil
+}
+
+func closeOutbounds(outbounds []adapter.Outbound) error {
+	var errors []error
+	for _, detour := range outbounds {
+		err := common.Close(detour)
+		if err != nil {
+			errors = append(errors, E.Cause(err, "close outbound/", detour.Type(), "[", detour.Tag(), "]"))
+		}
+	}
+	return E.Errors(errors...)
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/loader"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ adapter.OutboundProvider = (*RemoteProvider)(nil)
+
+type RemoteProvider struct {
+	abstractProvider
+	loader *loader.Remote
+}
+
+func NewRemoteProvider(ctx context.Context, router adapter.Router, logFactory log.Factory, options option.OutboundProvider) *RemoteProvider {
+	provider := &RemoteProvider{
+		abstractProvider: abstractProvider{
+			ctx:            ctx,
+			router:         router,
+			logFactory:     logFactory,
+			logger:         logFactory.NewLogger(F.ToString("provider/", options.Type, "[", options.Tag, "]")),
+			tag:            options.Tag,
+			healthCheckURL: options.HealthCheckURL,
+			history:        urltest.NewHistoryStorage(),
+			outboundByTag:  make(map[string]adapter.Outbound),
+		},
+	}
+	provider.instance = provider
+	if provider.healthCheckURL == "" {
+		provider.healthCheckURL = "http://www.gstatic.com/generate_204"
+	}
+	provider.loader = loader.NewRemote(ctx, loader.RemoteOptions{
+		Options:        provider.loaderOptions(time.Duration(options.UpdateInterval)),
+		Router:         router,
+		URL:            options.URL,
+		DownloadDetour: options.DownloadDetour,
+		LoadCache: func(cacheFile adapter.CacheFile) *adapter.SavedRemoteContent {
+			return cacheFile.LoadOutboundProvider(options.Tag)
+		},
+		SaveCache: func(cacheFile adapter.CacheFile, content *adapter.SavedRemoteContent) error {
+			return cacheFile.SaveOutboundProvider(options.Tag, content)
+		},
+	})
+	return provider
+}
+
+func (p *RemoteProvider) Type() string {
+	return C.OutboundProviderTypeRemote
+}
+
+func (p *RemoteProvider) Start() error {
+	return p.loader.Start()
+}
+
+func (p *RemoteProvider) Close() error {
+	p.loader.Close()
+	return p.abstractProvider.Close()
+}
+
+func (p *RemoteProvider) Update(ctx context.Context) error {
+	return p.loader.Update(ctx)
+}
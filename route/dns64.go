@@ -0,0 +1,101 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dns64"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func (r *Router) DNS64Prefix() netip.Prefix {
+	return r.dns64Prefix
+}
+
+// exchangeDNS64 synthesizes AAAA records from A records if the response has no AAAA records, as described in RFC 6147.
+func (r *Router) exchangeDNS64(ctx context.Context, message *dnsmessage.Message, response *dnsmessage.Message) *dnsmessage.Message {
+	if response.RCode != dnsmessage.RCodeSuccess {
+		return response
+	}
+	for _, answer := range response.Answers {
+		if answer.Header.Type == dnsmessage.TypeAAAA {
+			return response
+		}
+	}
+	question := message.Questions[0]
+	query := *message
+	query.Questions = []dnsmessage.Question{{Name: question.Name, Type: dnsmessage.TypeA, Class: question.Class}}
+	metadata := *adapter.ContextFrom(ctx)
+	responseA, err := r.Exchange(adapter.WithContext(ctx, &metadata), &query)
+	if err != nil || responseA.RCode != dnsmessage.RCodeSuccess {
+		return response
+	}
+	var answers []dnsmessage.Resource
+	for _, answer := range responseA.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.CNAMEResource:
+			answers = append(answers, answer)
+		case *dnsmessage.AResource:
+			address := netip.AddrFrom4(body.A)
+			if !r.isDNS64Address(address) {
+				continue
+			}
+			header := answer.Header
+			header.Type = dnsmessage.TypeAAAA
+			answers = append(answers, dnsmessage.Resource{
+				Header: header,
+				Body:   &dnsmessage.AAAAResource{AAAA: dns64.Synthesize(r.dns64Prefix, address).As16()},
+			})
+		}
+	}
+	if len(answers) == 0 {
+		return response
+	}
+	synthesized := *response
+	synthesized.Answers = answers
+	return &synthesized
+}
+
+// lookupDNS64 adds addresses synthesized from IPv4 addresses if no IPv6 address is found, unless only IPv4 is wanted.
+func (r *Router) lookupDNS64(ctx context.Context, domain string, strategy dns.DomainStrategy, addresses []netip.Addr, err error) ([]netip.Addr, error) {
+	if strategy == dns.DomainStrategyUseIPv4 || common.Any(addresses, netip.Addr.Is6) {
+		return addresses, err
+	}
+	addresses4 := addresses
+	if strategy == dns.DomainStrategyUseIPv6 {
+		var err4 error
+		addresses4, err4 = r.resolve(ctx, domain, dns.DomainStrategyUseIPv4)
+		if err4 != nil {
+			return addresses, err
+		}
+	}
+	var synthesized []netip.Addr
+	for _, address := range addresses4 {
+		if r.isDNS64Address(address) {
+			synthesized = append(synthesized, dns64.Synthesize(r.dns64Prefix, address))
+		}
+	}
+	if len(synthesized) == 0 {
+		return addresses, err
+	}
+	r.dnsLogger.DebugContext(ctx, "dns64 synthesized ", len(synthesized), " addresses for ", domain)
+	switch strategy {
+	case dns.DomainStrategyUseIPv6:
+		return synthesized, nil
+	case dns.DomainStrategyPreferIPv6:
+		return append(synthesized, addresses4...), nil
+	default:
+		return append(addresses4, synthesized...), nil
+	}
+}
+
+func (r *Router) isDNS64Address(address netip.Addr) bool {
+	if !address.Is4() || address.IsUnspecified() || address.IsLoopback() {
+		return false
+	}
+	return r.fakeIPStore == nil || !r.fakeIPStore.Contains(address)
+}
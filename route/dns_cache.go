@@ -0,0 +1,337 @@
+package route
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/cache"
+
+	"go.uber.org/atomic"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultDNSCacheSize  = 4096
+	defaultDNSStaleTTL   = 3 * 24 * time.Hour
+	dnsStaleAnswerTTL    = 30
+	dnsPrefetchHits      = 2
+	dnsCacheSaveInterval = 10 * time.Minute
+	// dnsNegativeTTL is used for negative responses without a SOA record to take the TTL from
+	dnsNegativeTTL = 30
+)
+
+type dnsCacheKey struct {
+	transport string
+	question  dnsmessage.Question
+}
+
+type dnsCacheEntry struct {
+	response *dnsmessage.Message
+	storedAt time.Time
+	expireAt time.Time
+	hits     atomic.Int32
+}
+
+// dnsCache caches responses of raw transports per server and question.
+//
+// Expired responses are kept for stale_ttl if serve_stale is enabled: they are answered with a TTL of 30
+// seconds as recommended by RFC 8767, while the response is refreshed in the background.
+// With prefetch, responses hit repeatedly are refreshed before they expire.
+type dnsCache struct {
+	ctx        context.Context
+	logger     log.ContextLogger
+	options    option.DNSCacheOptions
+	persist    bool
+	serveStale bool
+	staleTTL   time.Duration
+	prefetch   bool
+	entries    *cache.LruCache[dnsCacheKey, *dnsCacheEntry]
+	access     sync.Mutex
+	refreshing map[dnsCacheKey]bool
+	cacheFile  adapter.CacheFile
+	done       chan struct{}
+}
+
+func newDNSCache(ctx context.Context, logger log.ContextLogger, options option.DNSCacheOptions) *dnsCache {
+	size := options.Size
+	if size == 0 {
+		size = defaultDNSCacheSize
+	}
+	staleTTL := time.Duration(options.StaleTTL)
+	if staleTTL == 0 {
+		staleTTL = defaultDNSStaleTTL
+	}
+	return &dnsCache{
+		ctx:        ctx,
+		logger:     logger,
+		options:    options,
+		persist:    options.Persist,
+		serveStale: options.ServeStale,
+		staleTTL:   staleTTL,
+		prefetch:   options.Prefetch,
+		entries:    cache.New(cache.WithSize[dnsCacheKey, *dnsCacheEntry](size)),
+		refreshing: make(map[dnsCacheKey]bool),
+		done:       make(chan struct{}),
+	}
+}
+
+func (c *dnsCache) Start(cacheFile adapter.CacheFile) {
+	if !c.persist {
+		return
+	}
+	if cacheFile == nil {
+		c.logger.Warn("persist cache: cache_file is not enabled")
+		return
+	}
+	c.cacheFile = cacheFile
+	c.load()
+	go c.loopSave()
+}
+
+func (c *dnsCache) Close() error {
+	close(c.done)
+	if c.cacheFile == nil {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *dnsCache) load() {
+	var loaded int
+	now := time.Now()
+	for _, saved := range c.cacheFile.LoadDNSCache() {
+		if !c.usable(saved.ExpireAt, now) {
+			continue
+		}
+		var response dnsmessage.Message
+		err := response.Unpack(saved.Response)
+		if err != nil || len(response.Questions) != 1 {
+			continue
+		}
+		entry := &dnsCacheEntry{
+			response: &response,
+			storedAt: saved.StoredAt,
+			expireAt: saved.ExpireAt,
+		}
+		c.entries.Store(dnsCacheKey{saved.Transport, response.Questions[0]}, entry)
+		loaded++
+	}
+	if loaded > 0 {
+		c.logger.Info("loaded ", loaded, " cached responses")
+	}
+}
+
+func (c *dnsCache) loopSave() {
+	ticker := time.NewTicker(dnsCacheSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := c.save()
+			if err != nil {
+				c.logger.Error("save cache: ", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *dnsCache) save() error {
+	var responses []*adapter.SavedDNSResponse
+	now := time.Now()
+	c.entries.Range(func(key dnsCacheKey, entry *dnsCacheEntry) {
+		if !c.usable(entry.expireAt, now) {
+			return
+		}
+		content, err := entry.response.Pack()
+		if err != nil {
+			return
+		}
+		responses = append(responses, &adapter.SavedDNSResponse{
+			Transport: key.transport,
+			Response:  content,
+			StoredAt:  entry.storedAt,
+			ExpireAt:  entry.expireAt,
+		})
+	})
+	return c.cacheFile.StoreDNSCache(responses)
+}
+
+// purge drops the responses of the servers with the tags.
+func (c *dnsCache) purge(transports map[string]bool) {
+	var keys []dnsCacheKey
+	c.entries.Range(func(key dnsCacheKey, entry *dnsCacheEntry) {
+		if transports[key.transport] {
+			keys = append(keys, key)
+		}
+	})
+	for _, key := range keys {
+		c.entries.Delete(key)
+	}
+}
+
+func (c *dnsCache) usable(expireAt time.Time, now time.Time) bool {
+	if c.serveStale {
+		return now.Before(expireAt.Add(c.staleTTL))
+	}
+	return now.Before(expireAt)
+}
+
+func (c *dnsCache) exchange(ctx context.Context, transport *cachedTransport, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	key := dnsCacheKey{transport.tag, message.Questions[0]}
+	now := time.Now()
+	if entry, loaded := c.entries.Load(key); loaded {
+		if now.Before(entry.expireAt) {
+			if c.prefetch && entry.hits.Inc() >= dnsPrefetchHits && entry.expireAt.Sub(now)*10 < entry.expireAt.Sub(entry.storedAt) {
+				c.refresh(ctx, transport, key, message, "prefetch")
+			}
+			dnsQueryFromContext(ctx).hitCache()
+			return entry.answer(message.ID, now, false), nil
+		} else if c.usable(entry.expireAt, now) {
+			c.refresh(ctx, transport, key, message, "serve stale")
+			dnsQueryFromContext(ctx).hitCache()
+			return entry.answer(message.ID, now, true), nil
+		}
+		c.entries.Delete(key)
+	}
+	response, err := transport.Transport.Exchange(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, response)
+	return response, nil
+}
+
+// refresh exchanges the query in the background, at most once at a time for each key.
+func (c *dnsCache) refresh(ctx context.Context, transport *cachedTransport, key dnsCacheKey, message *dnsmessage.Message, reason string) {
+	c.access.Lock()
+	if c.refreshing[key] {
+		c.access.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.access.Unlock()
+	c.logger.DebugContext(ctx, reason, " ", key.question.Name.String(), " from ", key.transport)
+	query := *message
+	go func() {
+		defer func() {
+			c.access.Lock()
+			delete(c.refreshing, key)
+			c.access.Unlock()
+		}()
+		refreshCtx, cancel := context.WithTimeout(c.ctx, C.DNSTimeout)
+		defer cancel()
+		response, err := transport.Transport.Exchange(refreshCtx, &query)
+		if err != nil {
+			c.logger.DebugContext(ctx, "refresh ", key.question.Name.String(), " from ", key.transport, ": ", err)
+			return
+		}
+		c.store(key, response)
+	}()
+}
+
+func (c *dnsCache) store(key dnsCacheKey, response *dnsmessage.Message) {
+	if response.RCode != dnsmessage.RCodeSuccess && response.RCode != dnsmessage.RCodeNameError || response.Truncated {
+		return
+	}
+	ttl, cacheable := responseTTL(response)
+	if !cacheable {
+		return
+	}
+	now := time.Now()
+	cached := *response
+	c.entries.Store(key, &dnsCacheEntry{
+		response: &cached,
+		storedAt: now,
+		expireAt: now.Add(time.Duration(ttl) * time.Second),
+	})
+}
+
+// responseTTL returns the minimal TTL of records in the response, or the SOA minimum for negative answers.
+// Responses without records are negative answers, which are cached briefly.
+func responseTTL(response *dnsmessage.Message) (uint32, bool) {
+	var ttl uint32
+	var found bool
+	for _, records := range [][]dnsmessage.Resource{response.Answers, response.Authorities, response.Additionals} {
+		for _, record := range records {
+			if record.Header.Type == dnsmessage.TypeOPT {
+				continue
+			}
+			recordTTL := record.Header.TTL
+			if soa, isSOA := record.Body.(*dnsmessage.SOAResource); isSOA && soa.MinTTL < recordTTL {
+				recordTTL = soa.MinTTL
+			}
+			if !found || recordTTL < ttl {
+				ttl = recordTTL
+				found = true
+			}
+		}
+	}
+	if !found {
+		return dnsNegativeTTL, true
+	}
+	return ttl, ttl > 0
+}
+
+// answer copies the cached response with TTLs reduced by the time spent in cache.
+func (e *dnsCacheEntry) answer(id uint16, now time.Time, stale bool) *dnsmessage.Message {
+	elapsed := uint32(now.Sub(e.storedAt) / time.Second)
+	response := *e.response
+	response.ID = id
+	response.Answers = ageRecords(e.response.Answers, elapsed, stale)
+	response.Authorities = ageRecords(e.response.Authorities, elapsed, stale)
+	response.Additionals = ageRecords(e.response.Additionals, elapsed, stale)
+	return &response
+}
+
+func ageRecords(records []dnsmessage.Resource, elapsed uint32, stale bool) []dnsmessage.Resource {
+	if len(records) == 0 {
+		return records
+	}
+	aged := make([]dnsmessage.Resource, len(records))
+	copy(aged, records)
+	for i := range aged {
+		if aged[i].Header.Type == dnsmessage.TypeOPT {
+			continue
+		}
+		if stale || aged[i].Header.TTL <= elapsed {
+			aged[i].Header.TTL = dnsStaleAnswerTTL
+		} else {
+			aged[i].Header.TTL -= elapsed
+		}
+	}
+	return aged
+}
+
+var _ dns.Transport = (*cachedTransport)(nil)
+
+type cachedTransport struct {
+	dns.Transport
+	tag   string
+	cache *dnsCache
+}
+
+type dnsCacheContextKey struct{}
+
+// withDNSCache disables the cache of the DNS client for queries to a cached transport, which has its own cache.
+// Queries with the cache disabled by rules skip both.
+func withDNSCache(ctx context.Context, transport dns.Transport) context.Context {
+	if _, isCached := transport.(*cachedTransport); !isCached || dns.DisableCacheFromContext(ctx) {
+		return ctx
+	}
+	return context.WithValue(dns.ContextWithDisableCache(ctx, true), dnsCacheContextKey{}, true)
+}
+
+func (t *cachedTransport) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	if len(message.Questions) != 1 || dns.DisableCacheFromContext(ctx) && ctx.Value(dnsCacheContextKey{}) == nil {
+		return t.Transport.Exchange(ctx, message)
+	}
+	return t.cache.exchange(ctx, t, message)
+}
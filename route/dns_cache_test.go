@@ -0,0 +1,57 @@
+package route
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSCacheReload(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	dnsOptions := func(address string) option.DNSOptions {
+		return option.DNSOptions{
+			Servers: []option.DNSServerOptions{
+				{Tag: "remote", Address: address},
+				{Tag: "local", Address: "local"},
+			},
+			Cache: &option.DNSCacheOptions{Enabled: true},
+		}
+	}
+	question := dnsmessage.Question{
+		Name:  dnsmessage.MustNewName("example.com."),
+		Type:  dnsmessage.TypeA,
+		Class: dnsmessage.ClassINET,
+	}
+	response := &dnsmessage.Message{
+		Header:    dnsmessage.Header{Response: true},
+		Questions: []dnsmessage.Question{question},
+		Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+		}},
+	}
+	remoteKey := dnsCacheKey{"remote", question}
+	localKey := dnsCacheKey{"local", question}
+	previous, err := NewRouter(context.Background(), logger, logger, option.RouteOptions{}, dnsOptions("1.1.1.1"), nil, nil)
+	require.NoError(t, err)
+	previous.dnsCache.store(remoteKey, response)
+	previous.dnsCache.store(localKey, response)
+
+	router, err := NewRouter(context.Background(), logger, logger, option.RouteOptions{}, dnsOptions("1.1.1.1"), nil, previous)
+	require.NoError(t, err)
+	require.Same(t, previous.dnsCache, router.dnsCache)
+	require.True(t, router.dnsCache.entries.Exist(remoteKey))
+
+	// answers of the old upstream are dropped when a server changes under the same tag
+	router, err = NewRouter(context.Background(), logger, logger, option.RouteOptions{}, dnsOptions("8.8.8.8"), nil, router)
+	require.NoError(t, err)
+	require.Same(t, previous.dnsCache, router.dnsCache)
+	require.False(t, router.dnsCache.entries.Exist(remoteKey))
+	require.True(t, router.dnsCache.entries.Exist(localKey))
+}
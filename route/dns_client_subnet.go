@@ -0,0 +1,83 @@
+package route
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/sagernet/sing-dns"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const dnsOptionClientSubnet = 8
+
+var _ dns.Transport = (*clientSubnetTransport)(nil)
+
+// clientSubnetTransport adds an EDNS Client Subnet option (RFC 7871) to queries that do not have one.
+type clientSubnetTransport struct {
+	dns.Transport
+	option dnsmessage.Option
+}
+
+func newClientSubnetTransport(transport dns.Transport, prefix netip.Prefix) *clientSubnetTransport {
+	prefix = prefix.Masked()
+	var family uint16
+	var address []byte
+	if prefix.Addr().Is4() {
+		family = 1
+		address4 := prefix.Addr().As4()
+		address = address4[:]
+	} else {
+		family = 2
+		address6 := prefix.Addr().As16()
+		address = address6[:]
+	}
+	data := make([]byte, 4, 4+(prefix.Bits()+7)/8)
+	binary.BigEndian.PutUint16(data, family)
+	data[2] = uint8(prefix.Bits())
+	data = append(data, address[:(prefix.Bits()+7)/8]...)
+	return &clientSubnetTransport{
+		Transport: transport,
+		option: dnsmessage.Option{
+			Code: dnsOptionClientSubnet,
+			Data: data,
+		},
+	}
+}
+
+func (t *clientSubnetTransport) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	query := *message
+	query.Additionals = make([]dnsmessage.Resource, 0, len(message.Additionals)+1)
+	var hasOPT bool
+	for _, record := range message.Additionals {
+		if opt, isOPT := record.Body.(*dnsmessage.OPTResource); isOPT {
+			hasOPT = true
+			var hasClientSubnet bool
+			for _, option := range opt.Options {
+				if option.Code == dnsOptionClientSubnet {
+					hasClientSubnet = true
+					break
+				}
+			}
+			if !hasClientSubnet {
+				record.Body = &dnsmessage.OPTResource{
+					Options: append(append([]dnsmessage.Option(nil), opt.Options...), t.option),
+				}
+			}
+		}
+		query.Additionals = append(query.Additionals, record)
+	}
+	if !hasOPT {
+		var header dnsmessage.ResourceHeader
+		err := header.SetEDNS0(dns.FixedPacketSize, dnsmessage.RCodeSuccess, false)
+		if err != nil {
+			return nil, err
+		}
+		query.Additionals = append(query.Additionals, dnsmessage.Resource{
+			Header: header,
+			Body:   &dnsmessage.OPTResource{Options: []dnsmessage.Option{t.option}},
+		})
+	}
+	return t.Transport.Exchange(ctx, &query)
+}
@@ -0,0 +1,225 @@
+package route
+
+import (
+	"bufio"
+	"context"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type aliasContextKey struct{}
+
+// withAlias marks the context as resolving an alias, so that aliases are not followed again.
+func withAlias(ctx context.Context, alias string) (context.Context, error) {
+	if ctx.Value(aliasContextKey{}) != nil {
+		return nil, E.New("alias ", alias, " is resolved to another alias")
+	}
+	metadata := adapter.ContextFrom(ctx)
+	var aliasMetadata adapter.InboundContext
+	if metadata != nil {
+		aliasMetadata = *metadata
+	}
+	return context.WithValue(adapter.WithContext(ctx, &aliasMetadata), aliasContextKey{}, alias), nil
+}
+
+// staticRecords is a local answer for a name, either addresses or an alias.
+type staticRecords struct {
+	addresses []netip.Addr
+	cname     string
+}
+
+func newStaticRecords(values []string) (*staticRecords, error) {
+	var records staticRecords
+	for _, value := range values {
+		address, err := netip.ParseAddr(value)
+		if err == nil {
+			records.addresses = append(records.addresses, address.Unmap())
+			continue
+		}
+		if records.cname != "" {
+			return nil, E.New("multiple aliases: ", records.cname, ", ", value)
+		}
+		_, err = dnsmessage.NewName(strings.TrimSuffix(value, ".") + ".")
+		if err != nil {
+			return nil, E.Cause(err, "parse alias ", value)
+		}
+		records.cname = strings.ToLower(strings.TrimSuffix(value, "."))
+	}
+	if records.cname != "" && len(records.addresses) > 0 {
+		return nil, E.New("alias ", records.cname, " can not be mixed with addresses")
+	}
+	return &records, nil
+}
+
+func (r *staticRecords) String() string {
+	if r.cname != "" {
+		return r.cname
+	}
+	return strings.Join(F.MapToString(r.addresses), " ")
+}
+
+type dnsHosts struct {
+	records map[string]*staticRecords
+}
+
+func newDNSHosts(hosts map[string]option.Listable[string], paths []string) (*dnsHosts, error) {
+	records := make(map[string]*staticRecords)
+	for _, path := range paths {
+		err := readHostsFile(path, records)
+		if err != nil {
+			return nil, E.Cause(err, "read hosts file ", path)
+		}
+	}
+	for domain, values := range hosts {
+		entry, err := newStaticRecords(values)
+		if err != nil {
+			return nil, E.Cause(err, "parse hosts ", domain)
+		}
+		records[strings.ToLower(strings.TrimSuffix(domain, "."))] = entry
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &dnsHosts{records}, nil
+}
+
+func readHostsFile(path string, records map[string]*staticRecords) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if commentIndex := strings.IndexByte(line, '#'); commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		addressString := fields[0]
+		if zoneIndex := strings.IndexByte(addressString, '%'); zoneIndex >= 0 {
+			addressString = addressString[:zoneIndex]
+		}
+		address, err := netip.ParseAddr(addressString)
+		if err != nil {
+			continue
+		}
+		for _, domain := range fields[1:] {
+			domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+			entry := records[domain]
+			if entry == nil {
+				entry = &staticRecords{}
+				records[domain] = entry
+			}
+			entry.addresses = append(entry.addresses, address.Unmap())
+		}
+	}
+	return scanner.Err()
+}
+
+func (h *dnsHosts) lookup(domain string) *staticRecords {
+	if h == nil {
+		return nil
+	}
+	return h.records[strings.ToLower(strings.TrimSuffix(domain, "."))]
+}
+
+// exchangeStatic answers the query with static records, resolving an alias for address queries.
+func (r *Router) exchangeStatic(ctx context.Context, message *dnsmessage.Message, records *staticRecords) (*dnsmessage.Message, error) {
+	question := message.Questions[0]
+	response := &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 message.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   message.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: message.Questions,
+	}
+	header := dnsmessage.ResourceHeader{
+		Name:  question.Name,
+		Type:  question.Type,
+		Class: dnsmessage.ClassINET,
+		TTL:   dns.DefaultTTL,
+	}
+	if records.cname != "" {
+		cname := dnsmessage.MustNewName(records.cname + ".")
+		header.Type = dnsmessage.TypeCNAME
+		response.Answers = append(response.Answers, dnsmessage.Resource{
+			Header: header,
+			Body:   &dnsmessage.CNAMEResource{CNAME: cname},
+		})
+		if question.Type != dnsmessage.TypeA && question.Type != dnsmessage.TypeAAAA {
+			return response, nil
+		}
+		aliasMessage := *message
+		aliasMessage.Questions = []dnsmessage.Question{{Name: cname, Type: question.Type, Class: question.Class}}
+		aliasCtx, err := withAlias(ctx, records.cname)
+		if err != nil {
+			return nil, err
+		}
+		aliasResponse, err := r.Exchange(aliasCtx, &aliasMessage)
+		if err != nil {
+			return nil, err
+		}
+		response.RCode = aliasResponse.RCode
+		response.Answers = append(response.Answers, aliasResponse.Answers...)
+		return response, nil
+	}
+	for _, address := range records.addresses {
+		if question.Type == dnsmessage.TypeA && address.Is4() {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: header,
+				Body:   &dnsmessage.AResource{A: address.As4()},
+			})
+		} else if question.Type == dnsmessage.TypeAAAA && address.Is6() {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: header,
+				Body:   &dnsmessage.AAAAResource{AAAA: address.As16()},
+			})
+		}
+	}
+	return response, nil
+}
+
+// lookupStatic returns addresses from static records, resolving an alias.
+func (r *Router) lookupStatic(ctx context.Context, records *staticRecords, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	if records.cname != "" {
+		aliasCtx, err := withAlias(ctx, records.cname)
+		if err != nil {
+			return nil, err
+		}
+		return r.Lookup(aliasCtx, records.cname, strategy)
+	}
+	var addresses4, addresses6 []netip.Addr
+	for _, address := range records.addresses {
+		if address.Is4() {
+			addresses4 = append(addresses4, address)
+		} else {
+			addresses6 = append(addresses6, address)
+		}
+	}
+	switch strategy {
+	case dns.DomainStrategyUseIPv4:
+		return addresses4, nil
+	case dns.DomainStrategyUseIPv6:
+		return addresses6, nil
+	case dns.DomainStrategyPreferIPv6:
+		return append(addresses6, addresses4...), nil
+	default:
+		return append(addresses4, addresses6...), nil
+	}
+}
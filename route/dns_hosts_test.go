@@ -0,0 +1,127 @@
+package route_test
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing-dns"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func newHostsRouter(t *testing.T, hosts map[string]option.Listable[string], hostsPath option.Listable[string]) *route.Router {
+	logger := log.NewNOPFactory().Logger()
+	router, err := route.NewRouter(context.Background(), logger, logger, option.RouteOptions{}, option.DNSOptions{
+		Servers:   []option.DNSServerOptions{{Tag: "local", Address: "local"}},
+		Hosts:     hosts,
+		HostsPath: hostsPath,
+	}, nil, nil)
+	require.NoError(t, err)
+	return router
+}
+
+func TestDNSHostsPath(t *testing.T) {
+	t.Parallel()
+	hostsPath := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(hostsPath, []byte(`# comment
+127.0.0.1	localhost
+::1	localhost ip6-localhost # trailing comment
+fe80::1%lo0	link.local
+10.0.0.1	Example.COM. www.example.com
+invalid	ignored.example.com
+10.0.0.2
+`), 0o644))
+	router := newHostsRouter(t, map[string]option.Listable[string]{
+		"www.example.com": {"10.0.0.3"},
+	}, []string{hostsPath})
+	ctx := context.Background()
+
+	addresses, err := router.Lookup(ctx, "localhost", dns.DomainStrategyAsIS)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")}, addresses)
+
+	addresses, err = router.Lookup(ctx, "ip6-localhost", dns.DomainStrategyUseIPv6)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("::1")}, addresses)
+
+	addresses, err = router.Lookup(ctx, "link.local", dns.DomainStrategyAsIS)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("fe80::1")}, addresses)
+
+	// names are case-insensitive and may be fully qualified
+	addresses, err = router.Lookup(ctx, "example.com.", dns.DomainStrategyAsIS)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addresses)
+
+	// hosts options override hosts files
+	addresses, err = router.Lookup(ctx, "www.example.com", dns.DomainStrategyAsIS)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.3")}, addresses)
+}
+
+func TestDNSHostsPathMissing(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	_, err := route.NewRouter(context.Background(), logger, logger, option.RouteOptions{}, option.DNSOptions{
+		Servers:   []option.DNSServerOptions{{Tag: "local", Address: "local"}},
+		HostsPath: []string{filepath.Join(t.TempDir(), "missing")},
+	}, nil, nil)
+	require.Error(t, err)
+}
+
+func TestDNSHostsInvalid(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	for _, values := range []option.Listable[string]{
+		{"alias.example.com", "10.0.0.1"},
+		{"alias.example.com", "other.example.com"},
+	} {
+		_, err := route.NewRouter(context.Background(), logger, logger, option.RouteOptions{}, option.DNSOptions{
+			Servers: []option.DNSServerOptions{{Tag: "local", Address: "local"}},
+			Hosts:   map[string]option.Listable[string]{"example.com": values},
+		}, nil, nil)
+		require.Error(t, err)
+	}
+}
+
+func TestDNSHostsAlias(t *testing.T) {
+	t.Parallel()
+	router := newHostsRouter(t, map[string]option.Listable[string]{
+		"alias.example.com":  {"target.example.com."},
+		"target.example.com": {"10.0.0.1", "fd00::1"},
+		"loop1.example.com":  {"loop2.example.com"},
+		"loop2.example.com":  {"loop1.example.com"},
+	}, nil)
+	ctx := context.Background()
+
+	addresses, err := router.Lookup(ctx, "alias.example.com", dns.DomainStrategyAsIS)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")}, addresses)
+
+	response, err := router.Exchange(ctx, &dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("alias.example.com."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint16(1), response.ID)
+	require.Len(t, response.Answers, 2)
+	require.Equal(t, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("target.example.com.")}, response.Answers[0].Body)
+	require.Equal(t, "alias.example.com.", response.Answers[0].Header.Name.String())
+	require.Equal(t, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}, response.Answers[1].Body)
+	require.Equal(t, "target.example.com.", response.Answers[1].Header.Name.String())
+
+	// aliases are followed only once
+	_, err = router.Lookup(ctx, "loop1.example.com", dns.DomainStrategyAsIS)
+	require.Error(t, err)
+}
@@ -0,0 +1,237 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-dns"
+	F "github.com/sagernet/sing/common/format"
+
+	"go.uber.org/atomic"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type dnsQueryContextKey struct{}
+
+// dnsQueryState collects how a query is answered, for the query log.
+type dnsQueryState struct {
+	startedAt time.Time
+	rule      string
+	transport string
+	cacheHit  atomic.Bool
+	reached   atomic.Bool
+	record    *adapter.DNSQuery
+}
+
+// startDNSQuery adds a query state to the context.
+// The state is only returned for queries to be logged: not if nobody reads the log or the record, or if the query is a
+// part of another one, such as alias or DNS64 queries.
+func (r *Router) startDNSQuery(ctx context.Context) (context.Context, *dnsQueryState) {
+	nested := ctx.Value(dnsQueryContextKey{}) != nil
+	record := adapter.DNSQueryFromContext(ctx)
+	if !nested && r.dnsQueryLog == nil && r.clashServer == nil && record == nil {
+		return ctx, nil
+	}
+	state := &dnsQueryState{startedAt: time.Now()}
+	ctx = context.WithValue(ctx, dnsQueryContextKey{}, state)
+	if nested {
+		return ctx, nil
+	}
+	state.record = record
+	return ctx, state
+}
+
+func dnsQueryFromContext(ctx context.Context) *dnsQueryState {
+	state, _ := ctx.Value(dnsQueryContextKey{}).(*dnsQueryState)
+	return state
+}
+
+func (s *dnsQueryState) match(rule string, transport string) {
+	if s == nil {
+		return
+	}
+	s.rule = rule
+	s.transport = transport
+}
+
+func (s *dnsQueryState) hitCache() {
+	if s == nil {
+		return
+	}
+	s.cacheHit.Store(true)
+}
+
+// observe prepares the state for sending the query to the transport through the DNS client.
+// The cache of the client answers without reaching the transport, see observed.
+func (s *dnsQueryState) observe(transport dns.Transport) dns.Transport {
+	if s == nil {
+		return transport
+	}
+	s.cacheHit.Store(false)
+	s.reached.Store(false)
+	return &observedTransport{transport, s}
+}
+
+// observed marks a successful query which did not reach the transport as answered from cache.
+func (s *dnsQueryState) observed(err error) {
+	if s == nil || err != nil || s.reached.Load() {
+		return
+	}
+	s.cacheHit.Store(true)
+}
+
+type observedTransport struct {
+	dns.Transport
+	state *dnsQueryState
+}
+
+func (t *observedTransport) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	t.state.reached.Store(true)
+	return t.Transport.Exchange(ctx, message)
+}
+
+func (t *observedTransport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	t.state.reached.Store(true)
+	return t.Transport.Lookup(ctx, domain, strategy)
+}
+
+func (r *Router) logDNSQuery(state *dnsQueryState, metadata *adapter.InboundContext, queryType string, rCode dnsmessage.RCode, answers []string, err error) {
+	query := &adapter.DNSQuery{
+		Time:      state.startedAt,
+		Domain:    metadata.Domain,
+		QueryType: queryType,
+		Inbound:   metadata.Inbound,
+		Rule:      state.rule,
+		Transport: state.transport,
+		RCode:     formatDNSRCode(rCode),
+		Answers:   answers,
+		Latency:   time.Since(state.startedAt).Milliseconds(),
+		CacheHit:  state.cacheHit.Load(),
+	}
+	if metadata.Source.IsValid() {
+		query.Client = metadata.Source.String()
+	}
+	if err != nil {
+		if rCodeError, isRCodeError := err.(dns.RCodeError); isRCodeError {
+			query.RCode = formatDNSRCode(dnsmessage.RCode(rCodeError))
+		} else {
+			query.RCode = formatDNSRCode(dnsmessage.RCodeServerFailure)
+			query.Error = err.Error()
+		}
+	}
+	if state.record != nil {
+		*state.record = *query
+	}
+	if r.dnsQueryLog != nil {
+		err = r.dnsQueryLog.write(query)
+		if err != nil {
+			r.dnsLogger.Error("write query log: ", err)
+		}
+	}
+	if r.clashServer != nil {
+		r.clashServer.LogDNSQuery(query)
+	}
+}
+
+func formatDNSAnswers(answers []dnsmessage.Resource) []string {
+	var records []string
+	for _, answer := range answers {
+		content, loaded := formatDNSAnswer(answer)
+		if !loaded {
+			continue
+		}
+		records = append(records, formatDNSType(answer.Header.Type)+" "+content)
+	}
+	return records
+}
+
+func formatDNSAddresses(addresses []netip.Addr) []string {
+	records := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address.Is4() {
+			records = append(records, "A "+address.String())
+		} else {
+			records = append(records, "AAAA "+address.String())
+		}
+	}
+	return records
+}
+
+func formatDNSStrategy(strategy dns.DomainStrategy) string {
+	switch strategy {
+	case dns.DomainStrategyUseIPv4:
+		return "A"
+	case dns.DomainStrategyUseIPv6:
+		return "AAAA"
+	case dns.DomainStrategyPreferIPv6:
+		return "AAAA A"
+	default:
+		return "A AAAA"
+	}
+}
+
+func formatDNSRCode(rCode dnsmessage.RCode) string {
+	switch rCode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	default:
+		return F.ToString("RCODE", uint16(rCode))
+	}
+}
+
+// dnsQueryLog appends queries to a file as JSON lines.
+type dnsQueryLog struct {
+	path    string
+	access  sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newDNSQueryLog(path string) *dnsQueryLog {
+	return &dnsQueryLog{path: path}
+}
+
+func (l *dnsQueryLog) Start() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	l.file = file
+	l.encoder = json.NewEncoder(file)
+	return nil
+}
+
+func (l *dnsQueryLog) Close() error {
+	l.access.Lock()
+	defer l.access.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *dnsQueryLog) write(query *adapter.DNSQuery) error {
+	l.access.Lock()
+	defer l.access.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.encoder.Encode(query)
+}
@@ -0,0 +1,176 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const defaultDNSTrustedWait = 200 * time.Millisecond
+
+var _ dns.Transport = (*raceTransport)(nil)
+
+// raceTransport sends queries to several servers and answers with the first successful response.
+type raceTransport struct {
+	logger      log.ContextLogger
+	tags        []string
+	transports  []dns.Transport
+	delay       time.Duration
+	trusted     int
+	trustedWait time.Duration
+}
+
+func newRaceTransport(logger log.ContextLogger, tags []string, transports []dns.Transport, delay time.Duration, trusted string, trustedWait time.Duration) (*raceTransport, error) {
+	transport := &raceTransport{
+		logger:      logger,
+		trusted:     -1,
+		delay:       delay,
+		trustedWait: trustedWait,
+	}
+	for i, tag := range tags {
+		if tag == trusted {
+			// the trusted server is always queried first
+			transport.trusted = 0
+			transport.tags = append([]string{tag}, transport.tags...)
+			transport.transports = append([]dns.Transport{transports[i]}, transport.transports...)
+		} else {
+			transport.tags = append(transport.tags, tag)
+			transport.transports = append(transport.transports, transports[i])
+		}
+	}
+	if trusted != "" && transport.trusted < 0 {
+		return nil, E.New("trusted server ", trusted, " is not in servers")
+	}
+	if transport.trustedWait == 0 {
+		transport.trustedWait = defaultDNSTrustedWait
+	}
+	return transport, nil
+}
+
+func (t *raceTransport) Start() error {
+	return nil
+}
+
+func (t *raceTransport) Close() error {
+	return nil
+}
+
+func (t *raceTransport) Raw() bool {
+	return true
+}
+
+type raceResult struct {
+	index    int
+	response *dnsmessage.Message
+	err      error
+}
+
+// Exchange starts the servers in order, each one delay after the previous one or as soon as the previous one fails.
+//
+// A response from other servers is held until the trusted server fails or trusted_wait passes.
+func (t *raceTransport) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan raceResult, len(t.transports))
+	var started int
+	var delayTimer *time.Timer
+	if t.delay > 0 {
+		delayTimer = time.NewTimer(t.delay)
+		defer delayTimer.Stop()
+	}
+	startNext := func() {
+		if started == len(t.transports) {
+			return
+		}
+		index := started
+		started++
+		go func() {
+			query := *message
+			response, err := t.transports[index].Exchange(ctx, &query)
+			if err == nil && response.RCode != dnsmessage.RCodeSuccess && response.RCode != dnsmessage.RCodeNameError {
+				err = dns.RCodeError(response.RCode)
+			}
+			results <- raceResult{index, response, err}
+		}()
+		if delayTimer != nil {
+			if !delayTimer.Stop() {
+				select {
+				case <-delayTimer.C:
+				default:
+				}
+			}
+			delayTimer.Reset(t.delay)
+		}
+	}
+	startNext()
+	if t.delay == 0 {
+		for started < len(t.transports) {
+			startNext()
+		}
+	}
+	var trustedWait <-chan time.Time
+	trustedDone := t.trusted < 0
+	if !trustedDone {
+		trustedTimer := time.NewTimer(t.trustedWait)
+		defer trustedTimer.Stop()
+		trustedWait = trustedTimer.C
+	}
+	var held *raceResult
+	var errors []error
+	for finished := 0; finished < len(t.transports); {
+		var delayC <-chan time.Time
+		if delayTimer != nil && started < len(t.transports) {
+			delayC = delayTimer.C
+		}
+		select {
+		case result := <-results:
+			finished++
+			if result.err != nil {
+				errors = append(errors, E.Cause(result.err, t.tags[result.index]))
+				if result.index == t.trusted {
+					trustedDone = true
+					if held != nil {
+						return t.answer(ctx, *held), nil
+					}
+				}
+				startNext()
+				continue
+			}
+			if trustedDone || result.index == t.trusted {
+				return t.answer(ctx, result), nil
+			}
+			if held == nil {
+				held = &result
+			}
+		case <-delayC:
+			startNext()
+		case <-trustedWait:
+			trustedDone = true
+			if held != nil {
+				return t.answer(ctx, *held), nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if held != nil {
+		return t.answer(ctx, *held), nil
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (t *raceTransport) answer(ctx context.Context, result raceResult) *dnsmessage.Message {
+	t.logger.DebugContext(ctx, "race answered by ", t.tags[result.index])
+	return result.response
+}
+
+func (t *raceTransport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return nil, os.ErrInvalid
+}
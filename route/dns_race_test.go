@@ -0,0 +1,160 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-dns"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testRaceServer struct {
+	delay   time.Duration
+	rCode   dnsmessage.RCode
+	address [4]byte
+	queried atomic.Bool
+}
+
+func (s *testRaceServer) Start() error {
+	return nil
+}
+
+func (s *testRaceServer) Close() error {
+	return nil
+}
+
+func (s *testRaceServer) Raw() bool {
+	return true
+}
+
+func (s *testRaceServer) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	s.queried.Store(true)
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	response := &dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: message.ID, Response: true, RCode: s.rCode},
+		Questions: message.Questions,
+	}
+	if s.rCode == dnsmessage.RCodeSuccess {
+		response.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: message.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: s.address},
+		}}
+	}
+	return response, nil
+}
+
+func (s *testRaceServer) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return nil, os.ErrInvalid
+}
+
+func raceExchange(t *testing.T, servers []*testRaceServer, delay time.Duration, trusted string, trustedWait time.Duration) (*dnsmessage.Message, error) {
+	tags := []string{"a", "b", "c"}[:len(servers)]
+	transports := make([]dns.Transport, 0, len(servers))
+	for _, server := range servers {
+		transports = append(transports, server)
+	}
+	transport, err := newRaceTransport(log.NewNOPFactory().Logger(), tags, transports, delay, trusted, trustedWait)
+	require.NoError(t, err)
+	return transport.Exchange(context.Background(), &dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("example.com."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	})
+}
+
+func requireRaceAnswer(t *testing.T, response *dnsmessage.Message, err error, address [4]byte) {
+	require.NoError(t, err)
+	require.Len(t, response.Answers, 1)
+	require.Equal(t, address, response.Answers[0].Body.(*dnsmessage.AResource).A)
+}
+
+func TestDNSRaceFirst(t *testing.T) {
+	t.Parallel()
+	response, err := raceExchange(t, []*testRaceServer{
+		{delay: 200 * time.Millisecond, address: [4]byte{10, 0, 0, 1}},
+		{address: [4]byte{10, 0, 0, 2}},
+	}, 0, "", 0)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 2})
+}
+
+func TestDNSRaceDelay(t *testing.T) {
+	t.Parallel()
+	slow := &testRaceServer{address: [4]byte{10, 0, 0, 2}}
+	response, err := raceExchange(t, []*testRaceServer{
+		{address: [4]byte{10, 0, 0, 1}},
+		slow,
+	}, time.Second, "", 0)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 1})
+	require.False(t, slow.queried.Load())
+
+	// a failed server starts the next one without waiting for the delay
+	start := time.Now()
+	response, err = raceExchange(t, []*testRaceServer{
+		{rCode: dnsmessage.RCodeServerFailure},
+		{address: [4]byte{10, 0, 0, 2}},
+	}, time.Second, "", 0)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 2})
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestDNSRaceTrustedWait(t *testing.T) {
+	t.Parallel()
+	// the trusted server answers within trusted_wait
+	response, err := raceExchange(t, []*testRaceServer{
+		{address: [4]byte{10, 0, 0, 1}},
+		{delay: 100 * time.Millisecond, address: [4]byte{10, 0, 0, 2}},
+	}, 0, "b", time.Second)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 2})
+
+	// the held response is used once trusted_wait passes
+	start := time.Now()
+	response, err = raceExchange(t, []*testRaceServer{
+		{address: [4]byte{10, 0, 0, 1}},
+		{delay: 2 * time.Second, address: [4]byte{10, 0, 0, 2}},
+	}, 0, "b", 100*time.Millisecond)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 1})
+	require.Less(t, time.Since(start), time.Second)
+
+	// or once the trusted server fails
+	start = time.Now()
+	response, err = raceExchange(t, []*testRaceServer{
+		{address: [4]byte{10, 0, 0, 1}},
+		{delay: 100 * time.Millisecond, rCode: dnsmessage.RCodeRefused},
+	}, 0, "b", 2*time.Second)
+	requireRaceAnswer(t, response, err, [4]byte{10, 0, 0, 1})
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestDNSRaceFailed(t *testing.T) {
+	t.Parallel()
+	_, err := raceExchange(t, []*testRaceServer{
+		{rCode: dnsmessage.RCodeServerFailure},
+		{rCode: dnsmessage.RCodeRefused},
+	}, 0, "a", 0)
+	require.Error(t, err)
+
+	// NXDOMAIN is an answer
+	response, err := raceExchange(t, []*testRaceServer{
+		{rCode: dnsmessage.RCodeNameError},
+		{delay: time.Second, address: [4]byte{10, 0, 0, 2}},
+	}, 0, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, dnsmessage.RCodeNameError, response.RCode)
+
+	_, err = newRaceTransport(log.NewNOPFactory().Logger(), []string{"a"}, []dns.Transport{&testRaceServer{}}, 0, "b", 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package route
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/cache"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const dnsReverseMappingSize = 8192
+
+// dnsReverseMapping remembers the domain name each address was resolved from, until the record expires.
+type dnsReverseMapping struct {
+	cache *cache.LruCache[netip.Addr, string]
+}
+
+func newDNSReverseMapping() *dnsReverseMapping {
+	return &dnsReverseMapping{
+		cache: cache.New(
+			cache.WithSize[netip.Addr, string](dnsReverseMappingSize),
+			cache.WithAge[netip.Addr, string](dns.DefaultTTL),
+		),
+	}
+}
+
+func (m *dnsReverseMapping) save(domain string, address netip.Addr, ttl uint32) {
+	if m == nil {
+		return
+	}
+	m.cache.StoreWithExpire(address.Unmap(), domain, time.Now().Add(time.Duration(ttl)*time.Second))
+}
+
+func (m *dnsReverseMapping) saveAnswers(domain string, answers []dnsmessage.Resource) {
+	if m == nil {
+		return
+	}
+	for _, answer := range answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			m.save(domain, netip.AddrFrom4(body.A), answer.Header.TTL)
+		case *dnsmessage.AAAAResource:
+			m.save(domain, netip.AddrFrom16(body.AAAA), answer.Header.TTL)
+		}
+	}
+}
+
+func (m *dnsReverseMapping) query(address netip.Addr) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	return m.cache.Load(address.Unmap())
+}
@@ -0,0 +1,79 @@
+package route
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSReverseMapping(t *testing.T) {
+	t.Parallel()
+	mapping := newDNSReverseMapping()
+	mapping.saveAnswers("example.com", []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeAAAA, TTL: 60},
+			Body:   &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr("fd00::1").As16()},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeCNAME, TTL: 60},
+			Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("example.org.")},
+		},
+	})
+	domain, loaded := mapping.query(netip.MustParseAddr("10.0.0.1"))
+	require.True(t, loaded)
+	require.Equal(t, "example.com", domain)
+	domain, loaded = mapping.query(netip.MustParseAddr("fd00::1"))
+	require.True(t, loaded)
+	require.Equal(t, "example.com", domain)
+
+	// IPv4-mapped addresses are the same as IPv4 addresses
+	domain, loaded = mapping.query(netip.MustParseAddr("::ffff:10.0.0.1"))
+	require.True(t, loaded)
+	require.Equal(t, "example.com", domain)
+
+	// the latest domain wins
+	mapping.save("example.net", netip.MustParseAddr("10.0.0.1"), 60)
+	domain, _ = mapping.query(netip.MustParseAddr("10.0.0.1"))
+	require.Equal(t, "example.net", domain)
+
+	var disabled *dnsReverseMapping
+	disabled.save("example.com", netip.MustParseAddr("10.0.0.1"), 60)
+	_, loaded = disabled.query(netip.MustParseAddr("10.0.0.1"))
+	require.False(t, loaded)
+}
+
+func TestDNSReverseMappingExpire(t *testing.T) {
+	t.Parallel()
+	mapping := newDNSReverseMapping()
+	mapping.save("example.com", netip.MustParseAddr("10.0.0.1"), 0)
+	_, loaded := mapping.query(netip.MustParseAddr("10.0.0.1"))
+	require.False(t, loaded)
+}
+
+func TestDNSReverseMappingEvict(t *testing.T) {
+	t.Parallel()
+	mapping := newDNSReverseMapping()
+	first := netip.MustParseAddr("10.0.0.0")
+	second := first.Next()
+	address := first
+	for i := 0; i < dnsReverseMappingSize; i++ {
+		mapping.save("example.com", address, 60)
+		address = address.Next()
+	}
+	// a queried address is used recently and kept
+	_, loaded := mapping.query(first)
+	require.True(t, loaded)
+	mapping.save("example.com", address, 60)
+	_, loaded = mapping.query(first)
+	require.True(t, loaded)
+	_, loaded = mapping.query(second)
+	require.False(t, loaded)
+	_, loaded = mapping.query(address)
+	require.True(t, loaded)
+}
@@ -10,11 +10,14 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/dns64"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
 	"github.com/sagernet/sing-box/common/mux"
@@ -24,6 +27,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/fakeip"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing-tun"
 	"github.com/sagernet/sing/common"
@@ -36,6 +40,8 @@ import (
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/rw"
 	"github.com/sagernet/sing/common/uot"
+
+	"go.uber.org/atomic"
 )
 
 var warnDefaultInterfaceOnUnsupportedPlatform = warning.New(
@@ -65,10 +71,15 @@ type Router struct {
 	ctx                                context.Context
 	logger                             log.ContextLogger
 	dnsLogger                          log.ContextLogger
+	inboundAccess                      sync.RWMutex
 	inboundByTag                       map[string]adapter.Inbound
 	outbounds                          []adapter.Outbound
 	outboundByTag                      map[string]adapter.Outbound
+	outboundProviders                  []adapter.OutboundProvider
+	outboundProviderByTag              map[string]adapter.OutboundProvider
 	rules                              []adapter.Rule
+	ruleSets                           []adapter.RuleSet
+	ruleSetMap                         map[string]adapter.RuleSet
 	defaultDetour                      string
 	defaultOutboundForConnection       adapter.Outbound
 	defaultOutboundForPacketConnection adapter.Outbound
@@ -82,10 +93,20 @@ type Router struct {
 	dnsClient                          *dns.Client
 	defaultDomainStrategy              dns.DomainStrategy
 	dnsRules                           []adapter.DNSRule
+	dnsRuleAnswers                     []*staticRecords
+	dnsHosts                           *dnsHosts
+	dnsCache                           *dnsCache
+	dnsReverseMapping                  *dnsReverseMapping
+	dnsQueryLog                        *dnsQueryLog
+	dns64Prefix                        netip.Prefix
 	defaultTransport                   dns.Transport
+	defaultLookupTransport             dns.Transport
 	transports                         []dns.Transport
 	transportMap                       map[string]dns.Transport
+	transportNames                     map[dns.Transport]string
+	transportOptions                   map[string]option.DNSServerOptions
 	transportDomainStrategy            map[dns.Transport]dns.DomainStrategy
+	fakeIPStore                        adapter.FakeIPStore
 	interfaceBindManager               control.BindManager
 	autoDetectInterface                bool
 	defaultInterface                   string
@@ -93,11 +114,18 @@ type Router struct {
 	networkMonitor                     tun.NetworkUpdateMonitor
 	interfaceMonitor                   tun.DefaultInterfaceMonitor
 	packageManager                     tun.PackageManager
-	trafficController                  adapter.TrafficController
+	clashServer                        adapter.ClashServer
+	cacheFile                          adapter.CacheFile
 	processSearcher                    process.Searcher
+	activeConnections                  atomic.Int64
+	// shared holds the components taken over from the previous generation or handed over to the next one,
+	// which are not started or closed by this router.
+	shared map[any]bool
 }
 
-func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.ContextLogger, options option.RouteOptions, dnsOptions option.DNSOptions, inbounds []option.Inbound) (*Router, error) {
+// NewRouter creates a router. On reload, previous is the running router: its fakeip store, DNS cache,
+// reverse mapping and query log are reused if their options are unchanged, see TakeOver.
+func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.ContextLogger, options option.RouteOptions, dnsOptions option.DNSOptions, inbounds []option.Inbound, previous *Router) (*Router, error) {
 	if options.DefaultInterface != "" {
 		warnDefaultInterfaceOnUnsupportedPlatform.Check()
 	}
@@ -112,8 +140,11 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 		ctx:                   ctx,
 		logger:                logger,
 		dnsLogger:             dnsLogger,
+		shared:                make(map[any]bool),
 		outboundByTag:         make(map[string]adapter.Outbound),
 		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
+		ruleSets:              make([]adapter.RuleSet, 0, len(options.RuleSet)),
+		ruleSetMap:            make(map[string]adapter.RuleSet),
 		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
 		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule),
 		needGeositeDatabase:   hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
@@ -128,6 +159,17 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 		defaultInterface:      options.DefaultInterface,
 		defaultMark:           options.DefaultMark,
 	}
+	for i, ruleSetOptions := range options.RuleSet {
+		if _, exists := router.ruleSetMap[ruleSetOptions.Tag]; exists {
+			return nil, E.New("duplicate rule-set tag: ", ruleSetOptions.Tag)
+		}
+		ruleSet, err := NewRuleSet(ctx, router, logger, ruleSetOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse rule-set[", i, "]")
+		}
+		router.ruleSets = append(router.ruleSets, ruleSet)
+		router.ruleSetMap[ruleSetOptions.Tag] = ruleSet
+	}
 	for i, ruleOptions := range options.Rules {
 		routeRule, err := NewRule(router, logger, ruleOptions)
 		if err != nil {
@@ -141,6 +183,62 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 			return nil, E.Cause(err, "parse dns rule[", i, "]")
 		}
 		router.dnsRules = append(router.dnsRules, dnsRule)
+		var answer []string
+		if dnsRuleOptions.Type == C.RuleTypeLogical {
+			answer = dnsRuleOptions.LogicalOptions.Answer
+		} else {
+			answer = dnsRuleOptions.DefaultOptions.Answer
+		}
+		var records *staticRecords
+		if len(answer) > 0 {
+			records, err = newStaticRecords(answer)
+			if err != nil {
+				return nil, E.Cause(err, "parse dns rule[", i, "]: answer")
+			}
+		}
+		router.dnsRuleAnswers = append(router.dnsRuleAnswers, records)
+	}
+	dnsHosts, err := newDNSHosts(dnsOptions.Hosts, dnsOptions.HostsPath)
+	if err != nil {
+		return nil, E.Cause(err, "parse dns hosts")
+	}
+	router.dnsHosts = dnsHosts
+	if dnsOptions.ReverseMapping {
+		if previous != nil && previous.dnsReverseMapping != nil {
+			router.dnsReverseMapping = previous.dnsReverseMapping
+		} else {
+			router.dnsReverseMapping = newDNSReverseMapping()
+		}
+	}
+	if queryLogOptions := dnsOptions.QueryLog; queryLogOptions != nil && queryLogOptions.Enabled {
+		if queryLogOptions.Path == "" {
+			return nil, E.New("missing query log path")
+		}
+		if previous != nil && previous.dnsQueryLog != nil && previous.dnsQueryLog.path == queryLogOptions.Path {
+			router.dnsQueryLog = previous.dnsQueryLog
+			router.shared[router.dnsQueryLog] = true
+		} else {
+			router.dnsQueryLog = newDNSQueryLog(queryLogOptions.Path)
+		}
+	}
+	if dns64Options := dnsOptions.DNS64; dns64Options != nil && dns64Options.Enabled {
+		router.dns64Prefix = dns64Options.Prefix.Build()
+		if !router.dns64Prefix.IsValid() {
+			router.dns64Prefix = dns64.DefaultPrefix
+		} else if !dns64.IsValidPrefix(router.dns64Prefix) {
+			return nil, E.New("dns64: invalid prefix: ", router.dns64Prefix)
+		}
+	}
+	if cacheOptions := dnsOptions.Cache; cacheOptions != nil && cacheOptions.Enabled {
+		if dnsOptions.DisableCache {
+			return nil, E.New("dns cache conflicts with disable_cache")
+		}
+		if previous != nil && previous.dnsCache != nil && previous.dnsCache.options == *cacheOptions {
+			router.dnsCache = previous.dnsCache
+			router.shared[router.dnsCache] = true
+		} else {
+			router.dnsCache = newDNSCache(ctx, dnsLogger, *cacheOptions)
+		}
 	}
 	transports := make([]dns.Transport, len(dnsOptions.Servers))
 	dummyTransportMap := make(map[string]dns.Transport)
@@ -148,6 +246,29 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 	transportTags := make([]string, len(dnsOptions.Servers))
 	transportTagMap := make(map[string]bool)
 	transportDomainStrategy := make(map[dns.Transport]dns.DomainStrategy)
+	transportNames := make(map[dns.Transport]string)
+	transportOptions := make(map[string]option.DNSServerOptions)
+	var fakeIPStore *fakeip.Store
+	if fakeIPOptions := dnsOptions.FakeIP; fakeIPOptions != nil && fakeIPOptions.Enabled {
+		inet4Range := fakeIPOptions.Inet4Range.Build().Masked()
+		inet6Range := fakeIPOptions.Inet6Range.Build().Masked()
+		if !inet4Range.IsValid() && !inet6Range.IsValid() {
+			return nil, E.New("fakeip: missing inet4_range and inet6_range")
+		}
+		if inet4Range.IsValid() && (!inet4Range.Addr().Is4() || inet4Range.Bits() > 30) {
+			return nil, E.New("fakeip: invalid inet4_range: ", inet4Range)
+		}
+		if inet6Range.IsValid() && (!inet6Range.Addr().Is6() || inet6Range.Bits() > 126) {
+			return nil, E.New("fakeip: invalid inet6_range: ", inet6Range)
+		}
+		if previousStore, isStore := previousFakeIPStore(previous); isStore && previousStore.Inet4Range() == inet4Range && previousStore.Inet6Range() == inet6Range {
+			fakeIPStore = previousStore
+			router.shared[fakeIPStore] = true
+		} else {
+			fakeIPStore = fakeip.NewStore(router, dnsLogger, inet4Range, inet6Range)
+		}
+		router.fakeIPStore = fakeIPStore
+	}
 	for i, server := range dnsOptions.Servers {
 		var tag string
 		if server.Tag != "" {
@@ -157,6 +278,20 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 		}
 		transportTags[i] = tag
 		transportTagMap[tag] = true
+		transportOptions[tag] = server
+	}
+	router.transportOptions = transportOptions
+	if router.shared[router.dnsCache] {
+		// cached responses are keyed by server tag, drop those of servers changed under the same tag
+		changedTags := make(map[string]bool)
+		for tag, serverOptions := range previous.transportOptions {
+			if newOptions, loaded := transportOptions[tag]; !loaded || !reflect.DeepEqual(serverOptions, newOptions) {
+				changedTags[tag] = true
+			}
+		}
+		if len(changedTags) > 0 {
+			router.dnsCache.purge(changedTags)
+		}
 	}
 	for {
 		lastLen := len(dummyTransportMap)
@@ -171,8 +306,29 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 			} else {
 				detour = dialer.NewDetour(router, server.Detour)
 			}
+			var raceTransports []dns.Transport
 			switch server.Address {
-			case "local", "rcode":
+			case "local", "rcode", "fakeip":
+			case "race":
+				if len(server.Servers) == 0 {
+					return nil, E.New("parse dns server[", tag, "]: missing servers")
+				}
+				for _, memberTag := range server.Servers {
+					if !transportTagMap[memberTag] {
+						return nil, E.New("parse dns server[", tag, "]: server not found: ", memberTag)
+					}
+					member, exists := dummyTransportMap[memberTag]
+					if !exists {
+						break
+					}
+					if _, isFakeIP := member.(adapter.FakeIPTransport); isFakeIP || !member.Raw() {
+						return nil, E.New("parse dns server[", tag, "]: server ", memberTag, " can not be raced")
+					}
+					raceTransports = append(raceTransports, member)
+				}
+				if len(raceTransports) < len(server.Servers) {
+					continue
+				}
 			default:
 				serverURL, err := url.Parse(server.Address)
 				if err != nil {
@@ -196,12 +352,39 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 					return nil, E.New("parse dns server[", tag, "]: missing address_resolver")
 				}
 			}
-			transport, err := dns.NewTransport(ctx, detour, server.Address)
-			if err != nil {
-				return nil, E.Cause(err, "parse dns server[", tag, "]")
+			var transport dns.Transport
+			var err error
+			if server.Address == "fakeip" {
+				if fakeIPStore == nil {
+					return nil, E.New("parse dns server[", tag, "]: fakeip not enabled")
+				}
+				transport = fakeip.NewTransport(fakeIPStore)
+			} else if server.Address == "race" {
+				transport, err = newRaceTransport(dnsLogger, server.Servers, raceTransports, time.Duration(server.Delay), server.Trusted, time.Duration(server.TrustedWait))
+				if err != nil {
+					return nil, E.Cause(err, "parse dns server[", tag, "]")
+				}
+				if router.dnsCache != nil {
+					transport = &cachedTransport{transport, tag, router.dnsCache}
+				}
+			} else {
+				transport, err = dns.NewTransport(ctx, detour, server.Address)
+				if err != nil {
+					return nil, E.Cause(err, "parse dns server[", tag, "]")
+				}
+				if clientSubnet := server.ClientSubnet.Build(); clientSubnet.IsValid() {
+					if !transport.Raw() {
+						return nil, E.New("parse dns server[", tag, "]: client_subnet is not supported by ", server.Address)
+					}
+					transport = newClientSubnetTransport(transport, clientSubnet)
+				}
+				if router.dnsCache != nil && transport.Raw() {
+					transport = &cachedTransport{transport, tag, router.dnsCache}
+				}
 			}
 			transports[i] = transport
 			dummyTransportMap[tag] = transport
+			transportNames[transport] = tag
 			if server.Tag != "" {
 				transportMap[server.Tag] = transport
 			}
@@ -238,8 +421,19 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 		defaultTransport = transports[0]
 	}
 	router.defaultTransport = defaultTransport
+	router.defaultLookupTransport = defaultTransport
+	if _, isFakeIP := defaultTransport.(adapter.FakeIPTransport); isFakeIP {
+		router.defaultLookupTransport = common.Find(transports, func(transport dns.Transport) bool {
+			_, isFakeIP := transport.(adapter.FakeIPTransport)
+			return !isFakeIP
+		})
+		if router.defaultLookupTransport == nil {
+			router.defaultLookupTransport = dns.NewLocalTransport()
+		}
+	}
 	router.transports = transports
 	router.transportMap = transportMap
+	router.transportNames = transportNames
 	router.transportDomainStrategy = transportDomainStrategy
 
 	needInterfaceMonitor := options.AutoDetectInterface ||
@@ -270,9 +464,9 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 	}
 
 	needFindProcess := hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess
-	needPackageManager := C.IsAndroid && (needFindProcess || common.Any(inbounds, func(inbound option.Inbound) bool {
+	needPackageManager := C.IsAndroid && common.Any(inbounds, func(inbound option.Inbound) bool {
 		return len(inbound.TunOptions.IncludePackage) > 0 || len(inbound.TunOptions.ExcludePackage) > 0
-	}))
+	})
 	if needPackageManager {
 		packageManager, err := tun.NewPackageManager(router)
 		if err != nil {
@@ -281,22 +475,52 @@ func NewRouter(ctx context.Context, logger log.ContextLogger, dnsLogger log.Cont
 		router.packageManager = packageManager
 	}
 	if needFindProcess {
-		searcher, err := process.NewSearcher(process.Config{
-			Logger:         logger,
-			PackageManager: router.packageManager,
-		})
+		err := router.prepareProcessSearcher()
 		if err != nil {
-			if err != os.ErrInvalid {
-				logger.Warn(E.Cause(err, "create process searcher"))
-			}
-		} else {
-			router.processSearcher = searcher
+			return nil, err
 		}
 	}
 	return router, nil
 }
 
-func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, defaultOutbound func() adapter.Outbound) error {
+func (r *Router) prepareProcessSearcher() error {
+	if C.IsAndroid && r.packageManager == nil {
+		packageManager, err := tun.NewPackageManager(r)
+		if err != nil {
+			return E.Cause(err, "create package manager")
+		}
+		r.packageManager = packageManager
+	}
+	searcher, err := process.NewSearcher(process.Config{
+		Logger:         r.logger,
+		PackageManager: r.packageManager,
+	})
+	if err != nil {
+		if err != os.ErrInvalid {
+			r.logger.Warn(E.Cause(err, "create process searcher"))
+		}
+	} else {
+		r.processSearcher = searcher
+	}
+	return nil
+}
+
+func (r *Router) inbound(tag string) adapter.Inbound {
+	r.inboundAccess.RLock()
+	defer r.inboundAccess.RUnlock()
+	return r.inboundByTag[tag]
+}
+
+// ReplaceInbound replaces the inbound with the same tag, used to restore inbounds after a failed reload.
+func (r *Router) ReplaceInbound(inbound adapter.Inbound) {
+	r.inboundAccess.Lock()
+	defer r.inboundAccess.Unlock()
+	if _, loaded := r.inboundByTag[inbound.Tag()]; loaded {
+		r.inboundByTag[inbound.Tag()] = inbound
+	}
+}
+
+func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) error {
 	inboundByTag := make(map[string]adapter.Inbound)
 	for _, inbound := range inbounds {
 		inboundByTag[inbound.Tag()] = inbound
@@ -305,6 +529,13 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 	for _, detour := range outbounds {
 		outboundByTag[detour.Tag()] = detour
 	}
+	outboundProviderByTag := make(map[string]adapter.OutboundProvider)
+	for _, provider := range outboundProviders {
+		if _, exists := outboundProviderByTag[provider.Tag()]; exists {
+			return E.New("duplicate outbound provider tag: ", provider.Tag())
+		}
+		outboundProviderByTag[provider.Tag()] = provider
+	}
 	var defaultOutboundForConnection adapter.Outbound
 	var defaultOutboundForPacketConnection adapter.Outbound
 	if r.defaultDetour != "" {
@@ -370,6 +601,8 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 	r.defaultOutboundForConnection = defaultOutboundForConnection
 	r.defaultOutboundForPacketConnection = defaultOutboundForPacketConnection
 	r.outboundByTag = outboundByTag
+	r.outboundProviders = outboundProviders
+	r.outboundProviderByTag = outboundProviderByTag
 	for i, rule := range r.rules {
 		if _, loaded := outboundByTag[rule.Outbound()]; !loaded {
 			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
@@ -379,10 +612,66 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 }
 
 func (r *Router) Outbounds() []adapter.Outbound {
-	return r.outbounds
+	if len(r.outboundProviders) == 0 {
+		return r.outbounds
+	}
+	outbounds := append([]adapter.Outbound{}, r.outbounds...)
+	for _, provider := range r.outboundProviders {
+		outbounds = append(outbounds, provider.Outbounds()...)
+	}
+	return outbounds
 }
 
 func (r *Router) Start() error {
+	err := r.startRules(r.ruleSets)
+	if err != nil {
+		return err
+	}
+	// process items may appear only inside rule-sets, which are known after loading
+	if r.processSearcher == nil && common.Any(r.ruleSets, adapter.RuleSet.ContainsProcessRule) {
+		err = r.prepareProcessSearcher()
+		if err != nil {
+			return err
+		}
+	}
+	if r.fakeIPStore != nil && !r.shared[r.fakeIPStore] {
+		err = r.fakeIPStore.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if r.dnsCache != nil && !r.shared[r.dnsCache] {
+		r.dnsCache.Start(r.cacheFile)
+	}
+	if r.dnsQueryLog != nil && !r.shared[r.dnsQueryLog] {
+		err = r.dnsQueryLog.Start()
+		if err != nil {
+			return E.Cause(err, "open query log")
+		}
+	}
+	if r.interfaceMonitor != nil {
+		err = r.interfaceMonitor.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if r.networkMonitor != nil {
+		err = r.networkMonitor.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if r.packageManager != nil {
+		err = r.packageManager.Start()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startRules prepares the geo databases, starts the rule-sets and the route and DNS rules.
+func (r *Router) startRules(ruleSets []adapter.RuleSet) error {
 	if r.needGeoIPDatabase {
 		err := r.prepareGeoIPDatabase()
 		if err != nil {
@@ -395,6 +684,12 @@ func (r *Router) Start() error {
 			return err
 		}
 	}
+	for _, ruleSet := range ruleSets {
+		err := ruleSet.Start()
+		if err != nil {
+			return err
+		}
+	}
 	for _, rule := range r.rules {
 		err := rule.Start()
 		if err != nil {
@@ -427,24 +722,6 @@ func (r *Router) Start() error {
 		r.geositeCache = nil
 		r.geositeReader = nil
 	}
-	if r.interfaceMonitor != nil {
-		err := r.interfaceMonitor.Start()
-		if err != nil {
-			return err
-		}
-	}
-	if r.networkMonitor != nil {
-		err := r.networkMonitor.Start()
-		if err != nil {
-			return err
-		}
-	}
-	if r.packageManager != nil {
-		err := r.packageManager.Start()
-		if err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
@@ -461,12 +738,41 @@ func (r *Router) Close() error {
 			return err
 		}
 	}
-	return common.Close(
-		common.PtrOrNil(r.geoIPReader),
-		r.interfaceMonitor,
-		r.networkMonitor,
-		r.packageManager,
-	)
+	for _, ruleSet := range r.ruleSets {
+		err := ruleSet.Close()
+		if err != nil {
+			return err
+		}
+	}
+	closers := []any{common.PtrOrNil(r.geoIPReader)}
+	for _, component := range []any{r.fakeIPStore, common.PtrOrNil(r.dnsCache), common.PtrOrNil(r.dnsQueryLog)} {
+		if component != nil && !r.shared[component] {
+			closers = append(closers, component)
+		}
+	}
+	closers = append(closers, r.interfaceMonitor, r.networkMonitor, r.packageManager)
+	return common.Close(closers...)
+}
+
+// TakeOver makes this router the owner of the components reused from previous,
+// which then leaves them open when closed.
+func (r *Router) TakeOver(previous *Router) {
+	for component := range r.shared {
+		previous.shared[component] = true
+	}
+	r.shared = make(map[any]bool)
+}
+
+func previousFakeIPStore(previous *Router) (*fakeip.Store, bool) {
+	if previous == nil {
+		return nil, false
+	}
+	store, isStore := previous.fakeIPStore.(*fakeip.Store)
+	return store, isStore
+}
+
+func (r *Router) FakeIPStore() adapter.FakeIPStore {
+	return r.fakeIPStore
 }
 
 func (r *Router) GeoIPReader() *geoip.Reader {
@@ -490,9 +796,36 @@ func (r *Router) LoadGeosite(code string) (adapter.Rule, error) {
 	return rule, nil
 }
 
+func (r *Router) RuleSet(tag string) (adapter.RuleSet, bool) {
+	ruleSet, loaded := r.ruleSetMap[tag]
+	return ruleSet, loaded
+}
+
+func (r *Router) RuleSets() []adapter.RuleSet {
+	return r.ruleSets
+}
+
 func (r *Router) Outbound(tag string) (adapter.Outbound, bool) {
 	outbound, loaded := r.outboundByTag[tag]
-	return outbound, loaded
+	if loaded {
+		return outbound, true
+	}
+	for _, provider := range r.outboundProviders {
+		outbound, loaded = provider.Outbound(tag)
+		if loaded {
+			return outbound, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Router) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
+	provider, loaded := r.outboundProviderByTag[tag]
+	return provider, loaded
+}
+
+func (r *Router) OutboundProviders() []adapter.OutboundProvider {
+	return r.outboundProviders
 }
 
 func (r *Router) DefaultOutbound(network string) adapter.Outbound {
@@ -504,11 +837,13 @@ func (r *Router) DefaultOutbound(network string) adapter.Outbound {
 }
 
 func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	r.activeConnections.Inc()
+	defer r.activeConnections.Dec()
 	if metadata.InboundDetour != "" {
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := r.inbound(metadata.InboundDetour)
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -538,6 +873,18 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		metadata.Destination = M.Socksaddr{}
 		return r.RoutePacketConnection(ctx, uot.NewClientConn(conn), metadata)
 	}
+	if r.fakeIPStore != nil && r.fakeIPStore.Contains(metadata.Destination.Addr) {
+		domain, loaded := r.fakeIPStore.Lookup(metadata.Destination.Addr)
+		if !loaded {
+			return E.New("missing fakeip context")
+		}
+		metadata.Destination = M.Socksaddr{
+			Fqdn: domain,
+			Port: metadata.Destination.Port,
+		}
+		metadata.FakeIP = true
+		r.logger.DebugContext(ctx, "found fakeip domain: ", domain)
+	}
 	if metadata.SniffEnabled {
 		buffer := buf.NewPacket()
 		buffer.FullReset()
@@ -563,6 +910,13 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 			buffer.Release()
 		}
 	}
+	if metadata.Domain == "" && metadata.Destination.IsIP() {
+		if domain, loaded := r.dnsReverseMapping.query(metadata.Destination.Addr); loaded {
+			metadata.Domain = domain
+			metadata.ReverseMapping = true
+			r.logger.DebugContext(ctx, "found reverse mapping domain: ", domain)
+		}
+	}
 	if metadata.Destination.IsFqdn() && metadata.DomainStrategy != dns.DomainStrategyAsIS {
 		addresses, err := r.Lookup(adapter.WithContext(ctx, &metadata), metadata.Destination.Fqdn, metadata.DomainStrategy)
 		if err != nil {
@@ -576,8 +930,8 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		conn.Close()
 		return E.New("missing supported outbound, closing connection")
 	}
-	if r.trafficController != nil {
-		trackerConn, tracker := r.trafficController.RoutedConnection(ctx, conn, metadata, matchedRule)
+	if r.clashServer != nil {
+		trackerConn, tracker := r.clashServer.RoutedConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
 		conn = trackerConn
 	}
@@ -585,11 +939,13 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 }
 
 func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	r.activeConnections.Inc()
+	defer r.activeConnections.Dec()
 	if metadata.InboundDetour != "" {
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := r.inbound(metadata.InboundDetour)
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -610,6 +966,20 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		return nil
 	}
 	metadata.Network = N.NetworkUDP
+	if r.fakeIPStore != nil && r.fakeIPStore.Contains(metadata.Destination.Addr) {
+		domain, loaded := r.fakeIPStore.Lookup(metadata.Destination.Addr)
+		if !loaded {
+			return E.New("missing fakeip context")
+		}
+		originDestination := metadata.Destination
+		metadata.Destination = M.Socksaddr{
+			Fqdn: domain,
+			Port: metadata.Destination.Port,
+		}
+		metadata.FakeIP = true
+		conn = fakeip.NewNATPacketConn(conn, originDestination, metadata.Destination)
+		r.logger.DebugContext(ctx, "found fakeip domain: ", domain)
+	}
 	if metadata.SniffEnabled {
 		buffer := buf.NewPacket()
 		buffer.FullReset()
@@ -636,6 +1006,13 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		}
 		conn = bufio.NewCachedPacketConn(conn, buffer, destination)
 	}
+	if metadata.Domain == "" && metadata.Destination.IsIP() {
+		if domain, loaded := r.dnsReverseMapping.query(metadata.Destination.Addr); loaded {
+			metadata.Domain = domain
+			metadata.ReverseMapping = true
+			r.logger.DebugContext(ctx, "found reverse mapping domain: ", domain)
+		}
+	}
 	if metadata.Destination.IsFqdn() && metadata.Destination.Fqdn != uot.UOTMagicAddress && metadata.DomainStrategy != dns.DomainStrategyAsIS {
 		addresses, err := r.Lookup(adapter.WithContext(ctx, &metadata), metadata.Destination.Fqdn, metadata.DomainStrategy)
 		if err != nil {
@@ -649,8 +1026,8 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		conn.Close()
 		return E.New("missing supported outbound, closing packet connection")
 	}
-	if r.trafficController != nil {
-		trackerConn, tracker := r.trafficController.RoutedPacketConnection(ctx, conn, metadata, matchedRule)
+	if r.clashServer != nil {
+		trackerConn, tracker := r.clashServer.RoutedPacketConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
 		conn = trackerConn
 	}
@@ -689,6 +1066,10 @@ func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, de
 			metadata.ProcessInfo = processInfo
 		}
 	}
+	return r.matchRules(ctx, metadata, defaultOutbound)
+}
+
+func (r *Router) matchRules(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (adapter.Rule, adapter.Outbound) {
 	for i, rule := range r.rules {
 		if rule.Match(metadata) {
 			detour := rule.Outbound()
@@ -718,6 +1099,11 @@ func (r *Router) DefaultMark() int {
 	return r.defaultMark
 }
 
+// ActiveConnections returns the number of connections routed by this router that are still open.
+func (r *Router) ActiveConnections() int64 {
+	return r.activeConnections.Load()
+}
+
 func (r *Router) Rules() []adapter.Rule {
 	return r.rules
 }
@@ -734,8 +1120,20 @@ func (r *Router) PackageManager() tun.PackageManager {
 	return r.packageManager
 }
 
-func (r *Router) SetTrafficController(controller adapter.TrafficController) {
-	r.trafficController = controller
+func (r *Router) ClashServer() adapter.ClashServer {
+	return r.clashServer
+}
+
+func (r *Router) SetClashServer(controller adapter.ClashServer) {
+	r.clashServer = controller
+}
+
+func (r *Router) CacheFile() adapter.CacheFile {
+	return r.cacheFile
+}
+
+func (r *Router) SetCacheFile(cacheFile adapter.CacheFile) {
+	r.cacheFile = cacheFile
 }
 
 func hasRule(rules []option.Rule, cond func(rule option.DefaultRule) bool) bool {
@@ -779,7 +1177,7 @@ func isGeoIPRule(rule option.DefaultRule) bool {
 }
 
 func isGeoIPDNSRule(rule option.DefaultDNSRule) bool {
-	return len(rule.SourceGeoIP) > 0 && common.Any(rule.SourceGeoIP, notPrivateNode)
+	return len(rule.SourceGeoIP) > 0 && common.Any(rule.SourceGeoIP, notPrivateNode) || len(rule.GeoIP) > 0 && common.Any(rule.GeoIP, notPrivateNode)
 }
 
 func isGeositeRule(rule option.DefaultRule) bool {
@@ -15,32 +15,58 @@ import (
 	"golang.org/x/net/dns/dnsmessage"
 )
 
-func (r *Router) matchDNS(ctx context.Context) (context.Context, dns.Transport, dns.DomainStrategy) {
+// matchDNS returns the transport of the first rule matched from ruleIndex, and the index of that rule, or -1 for the default transport.
+// The transport is nil if the rule answers with static records.
+func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int) (context.Context, dns.Transport, dns.DomainStrategy, int) {
 	metadata := adapter.ContextFrom(ctx)
 	if metadata == nil {
 		panic("no context")
 	}
-	for i, rule := range r.dnsRules {
+	for i := ruleIndex; i < len(r.dnsRules); i++ {
+		rule := r.dnsRules[i]
 		if rule.Match(metadata) {
-			if rule.DisableCache() {
+			if rule.DisableCache() || rule.ResponseAction() != "" {
 				ctx = dns.ContextWithDisableCache(ctx, true)
 			}
+			if answer := r.dnsRuleAnswers[i]; answer != nil {
+				r.dnsLogger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => answer ", answer)
+				dnsQueryFromContext(ctx).match(rule.String(), "")
+				return ctx, nil, r.defaultDomainStrategy, i
+			}
 			detour := rule.Outbound()
 			r.dnsLogger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", detour)
 			if transport, loaded := r.transportMap[detour]; loaded {
+				if _, isFakeIP := transport.(adapter.FakeIPTransport); isFakeIP {
+					if !allowFakeIP {
+						continue
+					}
+					ctx = dns.ContextWithDisableCache(ctx, true)
+				}
+				ctx = withDNSCache(ctx, transport)
+				dnsQueryFromContext(ctx).match(rule.String(), detour)
 				if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
-					return ctx, transport, domainStrategy
+					return ctx, transport, domainStrategy, i
 				} else {
-					return ctx, transport, r.defaultDomainStrategy
+					return ctx, transport, r.defaultDomainStrategy, i
 				}
 			}
 			r.dnsLogger.ErrorContext(ctx, "transport not found: ", detour)
 		}
 	}
-	if domainStrategy, dsLoaded := r.transportDomainStrategy[r.defaultTransport]; dsLoaded {
-		return ctx, r.defaultTransport, domainStrategy
+	defaultTransport := r.defaultTransport
+	if _, isFakeIP := defaultTransport.(adapter.FakeIPTransport); isFakeIP {
+		if allowFakeIP {
+			ctx = dns.ContextWithDisableCache(ctx, true)
+		} else {
+			defaultTransport = r.defaultLookupTransport
+		}
+	}
+	ctx = withDNSCache(ctx, defaultTransport)
+	dnsQueryFromContext(ctx).match("", r.transportNames[defaultTransport])
+	if domainStrategy, dsLoaded := r.transportDomainStrategy[defaultTransport]; dsLoaded {
+		return ctx, defaultTransport, domainStrategy, -1
 	} else {
-		return ctx, r.defaultTransport, r.defaultDomainStrategy
+		return ctx, defaultTransport, r.defaultDomainStrategy, -1
 	}
 }
 
@@ -49,7 +75,9 @@ func (r *Router) Exchange(ctx context.Context, message *dnsmessage.Message) (*dn
 		r.dnsLogger.DebugContext(ctx, "exchange ", formatDNSQuestion(message.Questions[0]))
 	}
 	ctx, metadata := adapter.AppendContext(ctx)
+	ctx, query := r.startDNSQuery(ctx)
 	if len(message.Questions) > 0 {
+		metadata.QueryType = uint16(message.Questions[0].Type)
 		switch message.Questions[0].Type {
 		case dnsmessage.TypeA:
 			metadata.IPVersion = 4
@@ -58,38 +86,254 @@ func (r *Router) Exchange(ctx context.Context, message *dnsmessage.Message) (*dn
 		}
 		metadata.Domain = string(message.Questions[0].Name.Data[:message.Questions[0].Name.Length-1])
 	}
-	ctx, transport, strategy := r.matchDNS(ctx)
-	ctx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
-	defer cancel()
-	response, err := r.dnsClient.Exchange(ctx, transport, message, strategy)
+	var (
+		response *dnsmessage.Message
+		err      error
+	)
+	if records := r.dnsHosts.lookup(metadata.Domain); len(message.Questions) > 0 && records != nil {
+		r.dnsLogger.DebugContext(ctx, "hosts => ", records)
+		dnsQueryFromContext(ctx).match("hosts", "")
+		response, err = r.exchangeStatic(ctx, message, records)
+	} else {
+		response, err = r.exchange(ctx, message)
+	}
+	if err == nil && r.dns64Prefix.IsValid() && len(message.Questions) > 0 && message.Questions[0].Type == dnsmessage.TypeAAAA {
+		response = r.exchangeDNS64(ctx, message, response)
+	}
 	if err != nil && len(message.Questions) > 0 {
 		r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for ", message.Questions[0].Name.String()))
 	}
 	if len(message.Questions) > 0 && response != nil {
 		LogDNSAnswers(r.dnsLogger, ctx, message.Questions[0].Name.String(), response.Answers)
+		r.dnsReverseMapping.saveAnswers(metadata.Domain, response.Answers)
+	}
+	if query != nil && len(message.Questions) > 0 {
+		if response != nil {
+			r.logDNSQuery(query, metadata, formatDNSType(message.Questions[0].Type), response.RCode, formatDNSAnswers(response.Answers), err)
+		} else {
+			r.logDNSQuery(query, metadata, formatDNSType(message.Questions[0].Type), dnsmessage.RCodeServerFailure, nil, err)
+		}
 	}
 	return response, err
 }
 
+func (r *Router) exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	var (
+		response *dnsmessage.Message
+		err      error
+	)
+	for ruleIndex := 0; ; ruleIndex++ {
+		var (
+			exchangeCtx context.Context
+			transport   dns.Transport
+			strategy    dns.DomainStrategy
+		)
+		exchangeCtx, transport, strategy, ruleIndex = r.matchDNS(ctx, true, ruleIndex)
+		if transport == nil && len(message.Questions) > 0 {
+			response, err = r.exchangeStatic(ctx, message, r.dnsRuleAnswers[ruleIndex])
+			break
+		}
+		exchangeCtx, cancel := context.WithTimeout(exchangeCtx, C.DNSTimeout)
+		if len(message.Questions) == 1 && !filteredByStrategy(message.Questions[0], strategy) {
+			query := dnsQueryFromContext(ctx)
+			response, err = r.dnsClient.Exchange(exchangeCtx, query.observe(transport), message, strategy)
+			query.observed(err)
+		} else {
+			response, err = r.dnsClient.Exchange(exchangeCtx, transport, message, strategy)
+		}
+		cancel()
+		if ruleIndex < 0 {
+			break
+		}
+		rule := r.dnsRules[ruleIndex]
+		action := rule.ResponseAction()
+		if action == "" {
+			break
+		}
+		if err != nil {
+			if action == C.DNSRuleActionRetry {
+				r.dnsLogger.DebugContext(ctx, "retry after match[", ruleIndex, "]: ", err)
+				continue
+			}
+			break
+		}
+		var retry bool
+		response, retry, err = r.checkResponse(ctx, ruleIndex, rule, response)
+		if !retry {
+			break
+		}
+	}
+	return response, err
+}
+
+// filteredByStrategy reports whether the DNS client answers the question with an empty response for the strategy,
+// without the cache or the transport.
+func filteredByStrategy(question dnsmessage.Question, strategy dns.DomainStrategy) bool {
+	return question.Type == dnsmessage.TypeA && strategy == dns.DomainStrategyUseIPv6 || question.Type == dnsmessage.TypeAAAA && strategy == dns.DomainStrategyUseIPv4
+}
+
+// checkResponse applies the response action of the rule to addresses in the response not matched by the rule.
+func (r *Router) checkResponse(ctx context.Context, ruleIndex int, rule adapter.DNSRule, response *dnsmessage.Message) (*dnsmessage.Message, bool, error) {
+	var (
+		answers   []dnsmessage.Resource
+		unmatched []netip.Addr
+	)
+	for _, answer := range response.Answers {
+		var address netip.Addr
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			address = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			address = netip.AddrFrom16(body.AAAA)
+		default:
+			answers = append(answers, answer)
+			continue
+		}
+		if rule.MatchAddress(address) {
+			answers = append(answers, answer)
+		} else {
+			unmatched = append(unmatched, address)
+		}
+	}
+	if len(unmatched) == 0 {
+		return response, false, nil
+	}
+	switch r.logResponseAction(ctx, ruleIndex, rule, unmatched) {
+	case C.DNSRuleActionReject:
+		return nil, false, dns.RCodeRefused
+	case C.DNSRuleActionDrop:
+		filtered := *response
+		filtered.Answers = answers
+		return &filtered, false, nil
+	default:
+		return nil, true, nil
+	}
+}
+
+func (r *Router) logResponseAction(ctx context.Context, ruleIndex int, rule adapter.DNSRule, unmatched []netip.Addr) string {
+	action := rule.ResponseAction()
+	unmatchedString := strings.Join(F.MapToString(unmatched), " ")
+	switch action {
+	case C.DNSRuleActionReject:
+		r.dnsLogger.DebugContext(ctx, "match[", ruleIndex, "] rejected response: ", unmatchedString)
+	case C.DNSRuleActionDrop:
+		r.dnsLogger.DebugContext(ctx, "match[", ruleIndex, "] dropped addresses: ", unmatchedString)
+	default:
+		r.dnsLogger.DebugContext(ctx, "match[", ruleIndex, "] retry for addresses: ", unmatchedString)
+	}
+	return action
+}
+
 func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
 	r.dnsLogger.DebugContext(ctx, "lookup domain ", domain)
 	ctx, metadata := adapter.AppendContext(ctx)
+	ctx, query := r.startDNSQuery(ctx)
 	metadata.Domain = domain
-	ctx, transport, transportStrategy := r.matchDNS(ctx)
-	if strategy == dns.DomainStrategyAsIS {
-		strategy = transportStrategy
+	queryStrategy := strategy
+	if queryStrategy == dns.DomainStrategyAsIS {
+		queryStrategy = r.defaultDomainStrategy
+	}
+	// lookups query both A and AAAA unless limited to one by the strategy
+	switch queryStrategy {
+	case dns.DomainStrategyUseIPv4:
+		metadata.QueryType = uint16(dnsmessage.TypeA)
+	case dns.DomainStrategyUseIPv6:
+		metadata.QueryType = uint16(dnsmessage.TypeAAAA)
+	}
+	addrs, err := r.resolve(ctx, domain, strategy)
+	if r.dns64Prefix.IsValid() {
+		addrs, err = r.lookupDNS64(ctx, domain, strategy, addrs, err)
 	}
-	ctx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
-	defer cancel()
-	addrs, err := r.dnsClient.Lookup(ctx, transport, domain, strategy)
 	if len(addrs) > 0 {
 		r.dnsLogger.InfoContext(ctx, "lookup succeed for ", domain, ": ", strings.Join(F.MapToString(addrs), " "))
+		for _, address := range addrs {
+			r.dnsReverseMapping.save(domain, address, dns.DefaultTTL)
+		}
 	} else {
 		r.dnsLogger.ErrorContext(ctx, E.Cause(err, "lookup failed for ", domain))
 		if err == nil {
 			err = dns.RCodeNameError
 		}
 	}
+	if query != nil {
+		r.logDNSQuery(query, metadata, formatDNSStrategy(strategy), dnsmessage.RCodeSuccess, formatDNSAddresses(addrs), err)
+	}
+	return addrs, err
+}
+
+// resolve looks up the domain from hosts, or from servers selected by rules.
+func (r *Router) resolve(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	if records := r.dnsHosts.lookup(domain); records != nil {
+		r.dnsLogger.DebugContext(ctx, "hosts => ", records)
+		dnsQueryFromContext(ctx).match("hosts", "")
+		if strategy == dns.DomainStrategyAsIS {
+			strategy = r.defaultDomainStrategy
+		}
+		return r.lookupStatic(ctx, records, strategy)
+	}
+	return r.lookup(ctx, domain, strategy)
+}
+
+func (r *Router) lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	var (
+		addrs []netip.Addr
+		err   error
+	)
+	for ruleIndex := 0; ; ruleIndex++ {
+		var (
+			lookupCtx         context.Context
+			transport         dns.Transport
+			transportStrategy dns.DomainStrategy
+		)
+		lookupCtx, transport, transportStrategy, ruleIndex = r.matchDNS(ctx, false, ruleIndex)
+		lookupStrategy := strategy
+		if lookupStrategy == dns.DomainStrategyAsIS {
+			lookupStrategy = transportStrategy
+		}
+		if transport == nil {
+			return r.lookupStatic(ctx, r.dnsRuleAnswers[ruleIndex], lookupStrategy)
+		}
+		lookupCtx, cancel := context.WithTimeout(lookupCtx, C.DNSTimeout)
+		query := dnsQueryFromContext(ctx)
+		addrs, err = r.dnsClient.Lookup(lookupCtx, query.observe(transport), domain, lookupStrategy)
+		query.observed(err)
+		cancel()
+		if ruleIndex < 0 {
+			break
+		}
+		rule := r.dnsRules[ruleIndex]
+		action := rule.ResponseAction()
+		if action == "" {
+			break
+		}
+		if err != nil {
+			if action == C.DNSRuleActionRetry {
+				r.dnsLogger.DebugContext(ctx, "retry after match[", ruleIndex, "]: ", err)
+				continue
+			}
+			break
+		}
+		var matched, unmatched []netip.Addr
+		for _, addr := range addrs {
+			if rule.MatchAddress(addr) {
+				matched = append(matched, addr)
+			} else {
+				unmatched = append(unmatched, addr)
+			}
+		}
+		if len(unmatched) == 0 {
+			break
+		}
+		switch r.logResponseAction(ctx, ruleIndex, rule, unmatched) {
+		case C.DNSRuleActionReject:
+			addrs, err = nil, dns.RCodeRefused
+		case C.DNSRuleActionDrop:
+			addrs = matched
+		default:
+			continue
+		}
+		break
+	}
 	return addrs, err
 }
 
@@ -99,29 +343,8 @@ func (r *Router) LookupDefault(ctx context.Context, domain string) ([]netip.Addr
 
 func LogDNSAnswers(logger log.ContextLogger, ctx context.Context, domain string, answers []dnsmessage.Resource) {
 	for _, rawAnswer := range answers {
-		var content string
-		switch answer := rawAnswer.Body.(type) {
-		case *dnsmessage.AResource:
-			content = netip.AddrFrom4(answer.A).String()
-		case *dnsmessage.NSResource:
-			content = answer.NS.String()
-		case *dnsmessage.CNAMEResource:
-			content = answer.CNAME.String()
-		case *dnsmessage.SOAResource:
-			content = answer.MBox.String()
-		case *dnsmessage.PTRResource:
-			content = answer.PTR.String()
-		case *dnsmessage.MXResource:
-			content = answer.MX.String()
-		case *dnsmessage.TXTResource:
-			content = strings.Join(answer.TXT, " ")
-		case *dnsmessage.AAAAResource:
-			content = netip.AddrFrom16(answer.AAAA).String()
-		case *dnsmessage.SRVResource:
-			content = answer.Target.String()
-		case *dnsmessage.UnknownResource:
-			content = answer.Type.String()
-		default:
+		content, loaded := formatDNSAnswer(rawAnswer)
+		if !loaded {
 			continue
 		}
 		rType := formatDNSType(rawAnswer.Header.Type)
@@ -133,6 +356,33 @@ func LogDNSAnswers(logger log.ContextLogger, ctx context.Context, domain string,
 	}
 }
 
+func formatDNSAnswer(rawAnswer dnsmessage.Resource) (string, bool) {
+	switch answer := rawAnswer.Body.(type) {
+	case *dnsmessage.AResource:
+		return netip.AddrFrom4(answer.A).String(), true
+	case *dnsmessage.NSResource:
+		return answer.NS.String(), true
+	case *dnsmessage.CNAMEResource:
+		return answer.CNAME.String(), true
+	case *dnsmessage.SOAResource:
+		return answer.MBox.String(), true
+	case *dnsmessage.PTRResource:
+		return answer.PTR.String(), true
+	case *dnsmessage.MXResource:
+		return answer.MX.String(), true
+	case *dnsmessage.TXTResource:
+		return strings.Join(answer.TXT, " "), true
+	case *dnsmessage.AAAAResource:
+		return netip.AddrFrom16(answer.AAAA).String(), true
+	case *dnsmessage.SRVResource:
+		return answer.Target.String(), true
+	case *dnsmessage.UnknownResource:
+		return answer.Type.String(), true
+	default:
+		return "", false
+	}
+}
+
 func formatDNSQuestion(question dnsmessage.Question) string {
 	var qType string
 	qType = question.Type.String()
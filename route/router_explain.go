@@ -0,0 +1,134 @@
+package route
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type Explanation struct {
+	Metadata       adapter.InboundContext
+	Rules          []ExplainedRule
+	MatchedRule    adapter.Rule
+	Outbound       adapter.Outbound
+	OutboundChain  []adapter.Outbound
+	DNSRules       []ExplainedRule
+	MatchedDNSRule adapter.Rule
+	DNSServer      string
+}
+
+type ExplainedRule struct {
+	Index   int
+	Rule    adapter.Rule
+	Matched bool
+}
+
+// PrepareExplain loads what Explain needs without starting network services: the geo databases, local rule-sets
+// and the rules. Remote rule-sets are left empty, and groups report no selected outbound.
+func (r *Router) PrepareExplain() error {
+	localRuleSets := common.Filter(r.ruleSets, func(it adapter.RuleSet) bool {
+		return it.Type() == C.RuleSetTypeLocal
+	})
+	err := r.startRules(localRuleSets)
+	if err != nil {
+		return err
+	}
+	if r.fakeIPStore != nil {
+		return r.fakeIPStore.Start()
+	}
+	return nil
+}
+
+// Explain evaluates route and DNS rules against the metadata like RouteConnection would, without routing anything.
+// Process information is taken from the metadata instead of being searched.
+func (r *Router) Explain(ctx context.Context, metadata adapter.InboundContext, resolve bool) (*Explanation, error) {
+	var explanation Explanation
+	if r.fakeIPStore != nil && r.fakeIPStore.Contains(metadata.Destination.Addr) {
+		if domain, loaded := r.fakeIPStore.Lookup(metadata.Destination.Addr); loaded {
+			metadata.Destination = M.Socksaddr{
+				Fqdn: domain,
+				Port: metadata.Destination.Port,
+			}
+			metadata.FakeIP = true
+		}
+	}
+	domain := metadata.Domain
+	if metadata.Destination.IsFqdn() {
+		domain = metadata.Destination.Fqdn
+	}
+	if domain != "" {
+		dnsMetadata := metadata
+		dnsMetadata.Domain = domain
+		dnsCtx := adapter.WithContext(ctx, &dnsMetadata)
+		if r.dnsHosts.lookup(domain) != nil {
+			explanation.DNSServer = "hosts"
+		} else {
+			_, transport, _, ruleIndex := r.matchDNS(dnsCtx, true, 0)
+			if ruleIndex >= 0 {
+				explanation.MatchedDNSRule = r.dnsRules[ruleIndex]
+			}
+			explanation.DNSRules = explainRules(r.dnsRules, ruleIndex, dnsMetadata)
+			if transport == nil {
+				explanation.DNSServer = "answer"
+			} else {
+				explanation.DNSServer = r.transportName(transport)
+			}
+		}
+		if resolve && metadata.Destination.IsFqdn() {
+			addresses, err := r.Lookup(dnsCtx, domain, metadata.DomainStrategy)
+			if err != nil {
+				return nil, err
+			}
+			metadata.DestinationAddresses = addresses
+		}
+	}
+	defaultOutbound := r.defaultOutboundForConnection
+	if metadata.Network != N.NetworkTCP {
+		defaultOutbound = r.defaultOutboundForPacketConnection
+	}
+	explanation.MatchedRule, explanation.Outbound = r.matchRules(ctx, &metadata, defaultOutbound)
+	ruleIndex := -1
+	for i, rule := range r.rules {
+		if rule == explanation.MatchedRule {
+			ruleIndex = i
+			break
+		}
+	}
+	explanation.Rules = explainRules(r.rules, ruleIndex, metadata)
+	detour := explanation.Outbound
+	for detour != nil {
+		explanation.OutboundChain = append(explanation.OutboundChain, detour)
+		group, isGroup := detour.(adapter.OutboundGroup)
+		if !isGroup || len(explanation.OutboundChain) > len(r.outbounds) {
+			break
+		}
+		detour, _ = r.Outbound(group.Now())
+	}
+	explanation.Metadata = metadata
+	return &explanation, nil
+}
+
+// explainRules lists the rules evaluated before the matched one, or all rules if none matched.
+// A rule may match without being used, if its outbound or DNS server is missing or skipped.
+func explainRules[T adapter.Rule](rules []T, matchedIndex int, metadata adapter.InboundContext) []ExplainedRule {
+	if matchedIndex >= 0 {
+		rules = rules[:matchedIndex+1]
+	}
+	explained := make([]ExplainedRule, 0, len(rules))
+	for i, rule := range rules {
+		explained = append(explained, ExplainedRule{i, rule, i == matchedIndex || rule.Match(&metadata)})
+	}
+	return explained
+}
+
+func (r *Router) transportName(transport dns.Transport) string {
+	if name, loaded := r.transportNames[transport]; loaded {
+		return name
+	}
+	return "local"
+}
@@ -0,0 +1,160 @@
+package route
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/geoip"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common/control"
+	N "github.com/sagernet/sing/common/network"
+
+	"go.uber.org/atomic"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ adapter.Router = (*ReloadableRouter)(nil)
+
+// ReloadableRouter forwards to the current router, so that inbounds kept across reloads use the new one.
+// The underlying routers are started and closed by their owner.
+type ReloadableRouter struct {
+	router atomic.Pointer[Router]
+}
+
+func NewReloadableRouter(router *Router) *ReloadableRouter {
+	reloadable := &ReloadableRouter{}
+	reloadable.router.Store(router)
+	return reloadable
+}
+
+func (r *ReloadableRouter) Current() *Router {
+	return r.router.Load()
+}
+
+// Swap replaces the current router and returns the previous one.
+func (r *ReloadableRouter) Swap(router *Router) *Router {
+	return r.router.Swap(router)
+}
+
+func (r *ReloadableRouter) Start() error {
+	return nil
+}
+
+func (r *ReloadableRouter) Close() error {
+	return nil
+}
+
+func (r *ReloadableRouter) Outbounds() []adapter.Outbound {
+	return r.Current().Outbounds()
+}
+
+func (r *ReloadableRouter) Outbound(tag string) (adapter.Outbound, bool) {
+	return r.Current().Outbound(tag)
+}
+
+func (r *ReloadableRouter) DefaultOutbound(network string) adapter.Outbound {
+	return r.Current().DefaultOutbound(network)
+}
+
+func (r *ReloadableRouter) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
+	return r.Current().OutboundProvider(tag)
+}
+
+func (r *ReloadableRouter) OutboundProviders() []adapter.OutboundProvider {
+	return r.Current().OutboundProviders()
+}
+
+func (r *ReloadableRouter) RouteConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return r.Current().RouteConnection(ctx, conn, metadata)
+}
+
+func (r *ReloadableRouter) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return r.Current().RoutePacketConnection(ctx, conn, metadata)
+}
+
+func (r *ReloadableRouter) FakeIPStore() adapter.FakeIPStore {
+	return r.Current().FakeIPStore()
+}
+
+func (r *ReloadableRouter) GeoIPReader() *geoip.Reader {
+	return r.Current().GeoIPReader()
+}
+
+func (r *ReloadableRouter) LoadGeosite(code string) (adapter.Rule, error) {
+	return r.Current().LoadGeosite(code)
+}
+
+func (r *ReloadableRouter) RuleSet(tag string) (adapter.RuleSet, bool) {
+	return r.Current().RuleSet(tag)
+}
+
+func (r *ReloadableRouter) RuleSets() []adapter.RuleSet {
+	return r.Current().RuleSets()
+}
+
+func (r *ReloadableRouter) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	return r.Current().Exchange(ctx, message)
+}
+
+func (r *ReloadableRouter) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return r.Current().Lookup(ctx, domain, strategy)
+}
+
+func (r *ReloadableRouter) LookupDefault(ctx context.Context, domain string) ([]netip.Addr, error) {
+	return r.Current().LookupDefault(ctx, domain)
+}
+
+func (r *ReloadableRouter) DNS64Prefix() netip.Prefix {
+	return r.Current().DNS64Prefix()
+}
+
+func (r *ReloadableRouter) InterfaceBindManager() control.BindManager {
+	return r.Current().InterfaceBindManager()
+}
+
+func (r *ReloadableRouter) DefaultInterface() string {
+	return r.Current().DefaultInterface()
+}
+
+func (r *ReloadableRouter) AutoDetectInterface() bool {
+	return r.Current().AutoDetectInterface()
+}
+
+func (r *ReloadableRouter) DefaultMark() int {
+	return r.Current().DefaultMark()
+}
+
+func (r *ReloadableRouter) NetworkMonitor() tun.NetworkUpdateMonitor {
+	return r.Current().NetworkMonitor()
+}
+
+func (r *ReloadableRouter) InterfaceMonitor() tun.DefaultInterfaceMonitor {
+	return r.Current().InterfaceMonitor()
+}
+
+func (r *ReloadableRouter) PackageManager() tun.PackageManager {
+	return r.Current().PackageManager()
+}
+
+func (r *ReloadableRouter) Rules() []adapter.Rule {
+	return r.Current().Rules()
+}
+
+func (r *ReloadableRouter) ClashServer() adapter.ClashServer {
+	return r.Current().ClashServer()
+}
+
+func (r *ReloadableRouter) SetClashServer(server adapter.ClashServer) {
+	r.Current().SetClashServer(server)
+}
+
+func (r *ReloadableRouter) CacheFile() adapter.CacheFile {
+	return r.Current().CacheFile()
+}
+
+func (r *ReloadableRouter) SetCacheFile(cacheFile adapter.CacheFile) {
+	r.Current().SetCacheFile(cacheFile)
+}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"net/netip"
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -19,17 +20,32 @@ func NewDNSRule(router adapter.Router, logger log.ContextLogger, options option.
 		if !options.DefaultOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.DefaultOptions.Server == "" {
+		if options.DefaultOptions.Server == "" && len(options.DefaultOptions.Answer) == 0 {
 			return nil, E.New("missing server field")
 		}
+		if options.DefaultOptions.Server != "" && len(options.DefaultOptions.Answer) > 0 {
+			return nil, E.New("server and answer can not be used together")
+		}
+		if options.DefaultOptions.Action != "" && !options.DefaultOptions.HasResponseItems() {
+			return nil, E.New("action requires geoip or ip_cidr")
+		}
+		if len(options.DefaultOptions.Answer) > 0 && options.DefaultOptions.HasResponseItems() {
+			return nil, E.New("answer can not be used with geoip or ip_cidr")
+		}
 		return NewDefaultDNSRule(router, logger, options.DefaultOptions)
 	case C.RuleTypeLogical:
 		if !options.LogicalOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.LogicalOptions.Server == "" {
+		if options.LogicalOptions.Server == "" && len(options.LogicalOptions.Answer) == 0 {
 			return nil, E.New("missing server field")
 		}
+		if options.LogicalOptions.Server != "" && len(options.LogicalOptions.Answer) > 0 {
+			return nil, E.New("server and answer can not be used together")
+		}
+		if common.Any(options.LogicalOptions.Rules, option.DefaultDNSRule.HasResponseItems) {
+			return nil, E.New("geoip and ip_cidr are not supported in logical rules")
+		}
 		return NewLogicalDNSRule(router, logger, options.LogicalOptions)
 	default:
 		return nil, E.New("unknown rule type: ", options.Type)
@@ -44,10 +60,12 @@ type DefaultDNSRule struct {
 	sourcePortItems         []RuleItem
 	destinationAddressItems []RuleItem
 	destinationPortItems    []RuleItem
+	responseItems           []RuleItem
 	allItems                []RuleItem
 	invert                  bool
 	outbound                string
 	disableCache            bool
+	action                  string
 }
 
 func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options option.DefaultDNSRule) (*DefaultDNSRule, error) {
@@ -71,6 +89,11 @@ func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options
 			return nil, E.New("invalid ip version: ", options.IPVersion)
 		}
 	}
+	if len(options.QueryType) > 0 {
+		item := NewQueryTypeItem(options.QueryType)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if options.Network != "" {
 		switch options.Network {
 		case N.NetworkTCP, N.NetworkUDP:
@@ -183,6 +206,37 @@ func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.RuleSet) > 0 {
+		item, err := NewRuleSetItem(router, options.RuleSet)
+		if err != nil {
+			return nil, E.Cause(err, "rule_set")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.GeoIP) > 0 {
+		item := NewGeoIPItem(router, logger, false, options.GeoIP)
+		rule.responseItems = append(rule.responseItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.IPCIDR) > 0 {
+		item, err := NewIPCIDRItem(false, options.IPCIDR)
+		if err != nil {
+			return nil, E.Cause(err, "ipcidr")
+		}
+		rule.responseItems = append(rule.responseItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(rule.responseItems) > 0 {
+		switch options.Action {
+		case "", C.DNSRuleActionRetry:
+			rule.action = C.DNSRuleActionRetry
+		case C.DNSRuleActionReject, C.DNSRuleActionDrop:
+			rule.action = options.Action
+		default:
+			return nil, E.New("unknown action: ", options.Action)
+		}
+	}
 	return rule, nil
 }
 
@@ -222,10 +276,18 @@ func (r *DefaultDNSRule) UpdateGeosite() error {
 	return nil
 }
 
+// Match matches the query. For rules with response items, invert only applies to MatchAddress.
 func (r *DefaultDNSRule) Match(metadata *adapter.InboundContext) bool {
+	if len(r.responseItems) > 0 {
+		return r.matchQuery(metadata)
+	}
+	return r.matchQuery(metadata) != r.invert
+}
+
+func (r *DefaultDNSRule) matchQuery(metadata *adapter.InboundContext) bool {
 	for _, item := range r.items {
 		if !item.Match(metadata) {
-			return r.invert
+			return false
 		}
 	}
 
@@ -238,7 +300,7 @@ func (r *DefaultDNSRule) Match(metadata *adapter.InboundContext) bool {
 			}
 		}
 		if !sourceAddressMatch {
-			return r.invert
+			return false
 		}
 	}
 
@@ -251,7 +313,7 @@ func (r *DefaultDNSRule) Match(metadata *adapter.InboundContext) bool {
 			}
 		}
 		if !sourcePortMatch {
-			return r.invert
+			return false
 		}
 	}
 
@@ -264,7 +326,7 @@ func (r *DefaultDNSRule) Match(metadata *adapter.InboundContext) bool {
 			}
 		}
 		if !destinationAddressMatch {
-			return r.invert
+			return false
 		}
 	}
 
@@ -277,11 +339,11 @@ func (r *DefaultDNSRule) Match(metadata *adapter.InboundContext) bool {
 			}
 		}
 		if !destinationPortMatch {
-			return r.invert
+			return false
 		}
 	}
 
-	return !r.invert
+	return true
 }
 
 func (r *DefaultDNSRule) Outbound() string {
@@ -292,6 +354,23 @@ func (r *DefaultDNSRule) DisableCache() bool {
 	return r.disableCache
 }
 
+func (r *DefaultDNSRule) ResponseAction() string {
+	return r.action
+}
+
+// MatchAddress matches an address in the response against geoip and ip_cidr items.
+func (r *DefaultDNSRule) MatchAddress(address netip.Addr) bool {
+	metadata := adapter.InboundContext{
+		DestinationAddresses: []netip.Addr{address},
+	}
+	for _, item := range r.responseItems {
+		if item.Match(&metadata) {
+			return !r.invert
+		}
+	}
+	return r.invert
+}
+
 func (r *DefaultDNSRule) String() string {
 	return strings.Join(F.MapToString(r.allItems), " ")
 }
@@ -385,6 +464,14 @@ func (r *LogicalDNSRule) DisableCache() bool {
 	return r.disableCache
 }
 
+func (r *LogicalDNSRule) ResponseAction() string {
+	return ""
+}
+
+func (r *LogicalDNSRule) MatchAddress(address netip.Addr) bool {
+	return false
+}
+
 func (r *LogicalDNSRule) String() string {
 	var op string
 	switch r.mode {
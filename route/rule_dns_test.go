@@ -0,0 +1,131 @@
+package route_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing-dns"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func newDNSRule(t *testing.T, options option.DefaultDNSRule) adapter.DNSRule {
+	rule, err := route.NewDNSRule(nil, log.NewNOPFactory().Logger(), option.DNSRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: options,
+	})
+	require.NoError(t, err)
+	return rule
+}
+
+func TestDNSRuleResponseAction(t *testing.T) {
+	t.Parallel()
+	for _, action := range []string{"", C.DNSRuleActionRetry, C.DNSRuleActionReject, C.DNSRuleActionDrop} {
+		rule := newDNSRule(t, option.DefaultDNSRule{
+			Domain: []string{"example.com"},
+			IPCIDR: []string{"10.0.0.0/8"},
+			Server: "remote",
+			Action: action,
+		})
+		if action == "" {
+			require.Equal(t, C.DNSRuleActionRetry, rule.ResponseAction())
+		} else {
+			require.Equal(t, action, rule.ResponseAction())
+		}
+	}
+	require.Empty(t, newDNSRule(t, option.DefaultDNSRule{
+		Domain: []string{"example.com"},
+		Server: "remote",
+	}).ResponseAction())
+}
+
+func TestDNSRuleResponseInvalid(t *testing.T) {
+	t.Parallel()
+	for _, options := range []option.DefaultDNSRule{
+		{Domain: []string{"example.com"}, Server: "remote", Action: C.DNSRuleActionDrop},
+		{Domain: []string{"example.com"}, IPCIDR: []string{"10.0.0.0/8"}, Server: "remote", Action: "unknown"},
+		{Domain: []string{"example.com"}, IPCIDR: []string{"10.0.0.0/8"}, Answer: []string{"10.0.0.1"}},
+	} {
+		_, err := route.NewDNSRule(nil, log.NewNOPFactory().Logger(), option.DNSRule{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: options,
+		})
+		require.Error(t, err)
+	}
+}
+
+func TestDNSRuleResponseInvert(t *testing.T) {
+	t.Parallel()
+	inside := netip.MustParseAddr("10.0.0.1")
+	outside := netip.MustParseAddr("1.1.1.1")
+	metadata := adapter.InboundContext{Domain: "example.com"}
+	otherMetadata := adapter.InboundContext{Domain: "example.org"}
+
+	rule := newDNSRule(t, option.DefaultDNSRule{
+		Domain: []string{"example.com"},
+		IPCIDR: []string{"10.0.0.0/8"},
+		Server: "remote",
+	})
+	require.True(t, rule.Match(&metadata))
+	require.False(t, rule.Match(&otherMetadata))
+	require.True(t, rule.MatchAddress(inside))
+	require.False(t, rule.MatchAddress(outside))
+
+	// invert only applies to the response addresses
+	invertRule := newDNSRule(t, option.DefaultDNSRule{
+		Domain: []string{"example.com"},
+		IPCIDR: []string{"10.0.0.0/8"},
+		Server: "remote",
+		Invert: true,
+	})
+	require.True(t, invertRule.Match(&metadata))
+	require.False(t, invertRule.Match(&otherMetadata))
+	require.False(t, invertRule.MatchAddress(inside))
+	require.True(t, invertRule.MatchAddress(outside))
+
+	queryRule := newDNSRule(t, option.DefaultDNSRule{
+		Domain: []string{"example.com"},
+		Server: "remote",
+		Invert: true,
+	})
+	require.False(t, queryRule.Match(&metadata))
+	require.True(t, queryRule.Match(&otherMetadata))
+}
+
+func TestDNSRuleQueryTypeLookup(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	router, err := route.NewRouter(context.Background(), logger, logger, option.RouteOptions{}, option.DNSOptions{
+		Servers: []option.DNSServerOptions{{Tag: "local", Address: "local"}},
+		Rules: []option.DNSRule{
+			{
+				Type: C.RuleTypeDefault,
+				DefaultOptions: option.DefaultDNSRule{
+					QueryType: []option.DNSQueryType{option.DNSQueryType(dnsmessage.TypeA)},
+					Answer:    []string{"10.0.0.1"},
+				},
+			},
+			{
+				Type: C.RuleTypeDefault,
+				DefaultOptions: option.DefaultDNSRule{
+					QueryType: []option.DNSQueryType{option.DNSQueryType(dnsmessage.TypeAAAA)},
+					Answer:    []string{"fd00::1"},
+				},
+			},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+	addresses, err := router.Lookup(context.Background(), "example.com", dns.DomainStrategyUseIPv4)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addresses)
+	addresses, err = router.Lookup(context.Background(), "example.com", dns.DomainStrategyUseIPv6)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("fd00::1")}, addresses)
+}
@@ -0,0 +1,38 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*QueryTypeItem)(nil)
+
+type QueryTypeItem struct {
+	typeList []option.DNSQueryType
+	typeMap  map[uint16]bool
+}
+
+func NewQueryTypeItem(typeList []option.DNSQueryType) *QueryTypeItem {
+	typeMap := make(map[uint16]bool)
+	for _, queryType := range typeList {
+		typeMap[uint16(queryType)] = true
+	}
+	return &QueryTypeItem{
+		typeList: typeList,
+		typeMap:  typeMap,
+	}
+}
+
+func (r *QueryTypeItem) Match(metadata *adapter.InboundContext) bool {
+	return metadata.QueryType != 0 && r.typeMap[metadata.QueryType]
+}
+
+func (r *QueryTypeItem) String() string {
+	if len(r.typeList) == 1 {
+		return "query_type=" + r.typeList[0].String()
+	}
+	return "query_type=[" + strings.Join(F.MapToString(r.typeList), " ") + "]"
+}
@@ -0,0 +1,45 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*RuleSetItem)(nil)
+
+type RuleSetItem struct {
+	tags     []string
+	ruleSets []adapter.RuleSet
+}
+
+func NewRuleSetItem(router adapter.Router, tags []string) (*RuleSetItem, error) {
+	ruleSets := make([]adapter.RuleSet, 0, len(tags))
+	for _, tag := range tags {
+		ruleSet, loaded := router.RuleSet(tag)
+		if !loaded {
+			return nil, E.New("rule-set not found: ", tag)
+		}
+		ruleSets = append(ruleSets, ruleSet)
+	}
+	return &RuleSetItem{tags, ruleSets}, nil
+}
+
+func (r *RuleSetItem) Match(metadata *adapter.InboundContext) bool {
+	for _, ruleSet := range r.ruleSets {
+		if ruleSet.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RuleSetItem) String() string {
+	if len(r.tags) == 1 {
+		return F.ToString("rule_set=", r.tags[0])
+	} else {
+		return F.ToString("rule_set=[", strings.Join(r.tags, " "), "]")
+	}
+}
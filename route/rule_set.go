@@ -0,0 +1,198 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/common/loader"
+	"github.com/sagernet/sing-box/common/ruleset"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+func NewRuleSet(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.RuleSet) (adapter.RuleSet, error) {
+	if options.Tag == "" {
+		return nil, E.New("missing tag")
+	}
+	switch options.Format {
+	case "", C.RuleSetFormatSource, C.RuleSetFormatBinary:
+	default:
+		return nil, E.New("unknown rule-set format: ", options.Format)
+	}
+	switch options.Type {
+	case C.RuleSetTypeLocal:
+		if options.Path == "" {
+			return nil, E.New("missing path")
+		}
+		return NewLocalRuleSet(router, logger, options), nil
+	case C.RuleSetTypeRemote:
+		if options.URL == "" {
+			return nil, E.New("missing url")
+		}
+		return NewRemoteRuleSet(ctx, router, logger, options), nil
+	default:
+		return nil, E.New("unknown rule-set type: ", options.Type)
+	}
+}
+
+type abstractRuleSet struct {
+	router    adapter.Router
+	logger    log.ContextLogger
+	tag       string
+	format    string
+	access    sync.RWMutex
+	rules     []adapter.Rule
+	updatedAt time.Time
+
+	containsProcessRule bool
+}
+
+func (s *abstractRuleSet) Tag() string {
+	return s.tag
+}
+
+func (s *abstractRuleSet) Match(metadata *adapter.InboundContext) bool {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	for _, rule := range s.rules {
+		if rule.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *abstractRuleSet) RuleCount() int {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return len(s.rules)
+}
+
+func (s *abstractRuleSet) ContainsProcessRule() bool {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.containsProcessRule
+}
+
+func (s *abstractRuleSet) UpdatedAt() time.Time {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.updatedAt
+}
+
+func (s *abstractRuleSet) setUpdatedAt(updatedAt time.Time) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.updatedAt = updatedAt
+}
+
+func (s *abstractRuleSet) loaderOptions(updateInterval time.Duration) loader.Options {
+	if updateInterval == 0 {
+		updateInterval = C.DefaultRuleSetInterval
+	}
+	return loader.Options{
+		Logger:         s.logger,
+		Name:           "rule-set " + s.tag,
+		UpdateInterval: updateInterval,
+		Load:           s.loadContent,
+		Describe: func() string {
+			return F.ToString(s.RuleCount(), " rules")
+		},
+		UpdatedAt:    s.UpdatedAt,
+		SetUpdatedAt: s.setUpdatedAt,
+	}
+}
+
+func (s *abstractRuleSet) loadContent(content []byte, updatedAt time.Time) error {
+	var plainRuleSet option.PlainRuleSet
+	var err error
+	format := s.format
+	if format == "" {
+		if ruleset.IsBinary(content) {
+			format = C.RuleSetFormatBinary
+		} else {
+			format = C.RuleSetFormatSource
+		}
+	}
+	switch format {
+	case C.RuleSetFormatSource:
+		err = json.Unmarshal(content, &plainRuleSet)
+	case C.RuleSetFormatBinary:
+		plainRuleSet, err = ruleset.Read(bytes.NewReader(content))
+	}
+	if err != nil {
+		return E.Cause(err, "decode rule-set")
+	}
+	rules := make([]adapter.Rule, len(plainRuleSet.Rules))
+	for i, ruleOptions := range plainRuleSet.Rules {
+		rules[i], err = newRuleSetRule(s.router, s.logger, ruleOptions)
+		if err != nil {
+			return E.Cause(err, "parse rules[", i, "]")
+		}
+	}
+	s.access.Lock()
+	s.rules = rules
+	s.updatedAt = updatedAt
+	s.containsProcessRule = common.Any(plainRuleSet.Rules, isProcessRule)
+	s.access.Unlock()
+	return nil
+}
+
+func newRuleSetRule(router adapter.Router, logger log.ContextLogger, options option.DefaultRule) (adapter.Rule, error) {
+	if !options.IsValid() {
+		return nil, E.New("missing conditions")
+	}
+	switch {
+	case len(options.Geosite) > 0:
+		return nil, E.New("geosite is not supported in rule-set")
+	case len(options.GeoIP) > 0, len(options.SourceGeoIP) > 0:
+		return nil, E.New("geoip is not supported in rule-set")
+	case len(options.RuleSet) > 0:
+		return nil, E.New("rule_set is not supported in rule-set")
+	}
+	return NewDefaultRule(router, logger, options)
+}
+
+var _ adapter.RuleSet = (*LocalRuleSet)(nil)
+
+type LocalRuleSet struct {
+	abstractRuleSet
+	loader *loader.Local
+}
+
+func NewLocalRuleSet(router adapter.Router, logger log.ContextLogger, options option.RuleSet) *LocalRuleSet {
+	ruleSet := &LocalRuleSet{
+		abstractRuleSet: abstractRuleSet{
+			router: router,
+			logger: logger,
+			tag:    options.Tag,
+			format: options.Format,
+		},
+	}
+	ruleSet.loader = loader.NewLocal(ruleSet.loaderOptions(time.Duration(options.UpdateInterval)), options.Path)
+	return ruleSet
+}
+
+func (s *LocalRuleSet) Type() string {
+	return C.RuleSetTypeLocal
+}
+
+func (s *LocalRuleSet) Start() error {
+	return s.loader.Start()
+}
+
+func (s *LocalRuleSet) Close() error {
+	return s.loader.Close()
+}
+
+func (s *LocalRuleSet) Update(ctx context.Context) error {
+	return s.loader.Update(ctx)
+}
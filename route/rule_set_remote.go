@@ -0,0 +1,59 @@
+package route
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/loader"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+)
+
+var _ adapter.RuleSet = (*RemoteRuleSet)(nil)
+
+type RemoteRuleSet struct {
+	abstractRuleSet
+	loader *loader.Remote
+}
+
+func NewRemoteRuleSet(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.RuleSet) *RemoteRuleSet {
+	ruleSet := &RemoteRuleSet{
+		abstractRuleSet: abstractRuleSet{
+			router: router,
+			logger: logger,
+			tag:    options.Tag,
+			format: options.Format,
+		},
+	}
+	ruleSet.loader = loader.NewRemote(ctx, loader.RemoteOptions{
+		Options:        ruleSet.loaderOptions(time.Duration(options.UpdateInterval)),
+		Router:         router,
+		URL:            options.URL,
+		DownloadDetour: options.DownloadDetour,
+		LoadCache: func(cacheFile adapter.CacheFile) *adapter.SavedRemoteContent {
+			return cacheFile.LoadRuleSet(options.Tag)
+		},
+		SaveCache: func(cacheFile adapter.CacheFile, content *adapter.SavedRemoteContent) error {
+			return cacheFile.SaveRuleSet(options.Tag, content)
+		},
+	})
+	return ruleSet
+}
+
+func (s *RemoteRuleSet) Type() string {
+	return C.RuleSetTypeRemote
+}
+
+func (s *RemoteRuleSet) Start() error {
+	return s.loader.Start()
+}
+
+func (s *RemoteRuleSet) Close() error {
+	return s.loader.Close()
+}
+
+func (s *RemoteRuleSet) Update(ctx context.Context) error {
+	return s.loader.Update(ctx)
+}
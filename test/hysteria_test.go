@@ -1,17 +1,42 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/netip"
+	"sync"
 	"testing"
+	"time"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/protocol/socks"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestHysteriaSelf(t *testing.T) {
 	if !C.QUIC_AVAILABLE {
 		t.Skip("QUIC not included")
 	}
+	t.Run("self", func(t *testing.T) {
+		testHysteriaSelf(t, false)
+	})
+	t.Run("port-hopping", func(t *testing.T) {
+		testHysteriaSelf(t, true)
+	})
+}
+
+func testHysteriaSelf(t *testing.T, portHopping bool) {
+	var serverPorts option.Listable[string]
+	var forwarder *dnatForwarder
+	if portHopping {
+		serverPorts = option.Listable[string]{F.ToString(hopPortStart, ":", hopPortEnd)}
+		forwarder = startDNATForwarder(t, hopPortStart, hopPortEnd, serverPort)
+	}
 	_, certPem, keyPem := createSelfSignedCertificate(t, "example.org")
 	startInstance(t, option.Options{
 		Inbounds: []option.Inbound{
@@ -57,10 +82,12 @@ func TestHysteriaSelf(t *testing.T) {
 						Server:     "127.0.0.1",
 						ServerPort: serverPort,
 					},
-					UpMbps:     100,
-					DownMbps:   100,
-					AuthString: "password",
-					Obfs:       "fuck me till the daylight",
+					ServerPorts: serverPorts,
+					HopInterval: option.Duration(time.Second),
+					UpMbps:      100,
+					DownMbps:    100,
+					AuthString:  "password",
+					Obfs:        "fuck me till the daylight",
 					TLS: &option.OutboundTLSOptions{
 						Enabled:         true,
 						ServerName:      "example.org",
@@ -81,6 +108,101 @@ func TestHysteriaSelf(t *testing.T) {
 		},
 	})
 	testSuitSimple1(t, clientPort, testPort)
+	if portHopping {
+		// keep the session busy over several hops
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+			testSuitSimple1(t, clientPort, testPort)
+		}
+		require.Greater(t, forwarder.usedPorts(), 1)
+		// the server keeps replying to the first flow, a second one means the session broke and was dialed again
+		require.Equal(t, 1, forwarder.repliedFlows())
+	}
+}
+
+const (
+	hopPortStart uint16 = 20000
+	hopPortEnd   uint16 = 20003
+)
+
+// dnatForwarder forwards UDP packets sent to a port range to the target port like an iptables DNAT rule:
+// each client address is a separate flow, and replies of the target go back through the port the flow started on.
+type dnatForwarder struct {
+	access       sync.Mutex
+	ports        map[uint16]bool
+	replyClients map[string]bool
+}
+
+func startDNATForwarder(t *testing.T, start uint16, end uint16, target uint16) *dnatForwarder {
+	forwarder := &dnatForwarder{
+		ports:        make(map[uint16]bool),
+		replyClients: make(map[string]bool),
+	}
+	for port := start; port <= end; port++ {
+		listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(port)})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			listener.Close()
+		})
+		go forwarder.loopForward(listener, port, target)
+	}
+	return forwarder
+}
+
+func (f *dnatForwarder) loopForward(listener *net.UDPConn, port uint16, target uint16) {
+	flows := make(map[string]*net.UDPConn)
+	defer func() {
+		for _, upstream := range flows {
+			upstream.Close()
+		}
+	}()
+	buffer := make([]byte, 65535)
+	for {
+		n, clientAddr, err := listener.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		f.access.Lock()
+		f.ports[port] = true
+		f.access.Unlock()
+		upstream, loaded := flows[clientAddr.String()]
+		if !loaded {
+			upstream, err = net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(target)})
+			if err != nil {
+				continue
+			}
+			flows[clientAddr.String()] = upstream
+			go f.loopReply(listener, upstream, clientAddr)
+		}
+		upstream.Write(buffer[:n])
+	}
+}
+
+func (f *dnatForwarder) loopReply(listener *net.UDPConn, upstream *net.UDPConn, clientAddr *net.UDPAddr) {
+	buffer := make([]byte, 65535)
+	for {
+		n, err := upstream.Read(buffer)
+		if err != nil {
+			return
+		}
+		f.access.Lock()
+		f.replyClients[clientAddr.String()] = true
+		f.access.Unlock()
+		listener.WriteToUDP(buffer[:n], clientAddr)
+	}
+}
+
+// usedPorts counts ports that received packets.
+func (f *dnatForwarder) usedPorts() int {
+	f.access.Lock()
+	defer f.access.Unlock()
+	return len(f.ports)
+}
+
+// repliedFlows counts client addresses that the target replied to.
+func (f *dnatForwarder) repliedFlows() int {
+	f.access.Lock()
+	defer f.access.Unlock()
+	return len(f.replyClients)
 }
 
 func TestHysteriaInbound(t *testing.T) {
@@ -173,3 +295,127 @@ func TestHysteriaOutbound(t *testing.T) {
 	})
 	testSuit(t, clientPort, testPort)
 }
+
+func TestHysteriaUsers(t *testing.T) {
+	if !C.QUIC_AVAILABLE {
+		t.Skip("QUIC not included")
+	}
+	_, certPem, keyPem := createSelfSignedCertificate(t, "example.org")
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in",
+				MixedOptions: option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.ListenAddress(netip.IPv4Unspecified()),
+						ListenPort: clientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in-2",
+				MixedOptions: option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.ListenAddress(netip.IPv4Unspecified()),
+						ListenPort: otherPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeHysteria,
+				HysteriaOptions: option.HysteriaInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.ListenAddress(netip.IPv4Unspecified()),
+						ListenPort: serverPort,
+					},
+					UpMbps:   100,
+					DownMbps: 100,
+					Users: []option.HysteriaUser{
+						{Name: "user1", AuthString: "password1"},
+						{Name: "user2", AuthString: "password2"},
+					},
+					TLS: &option.InboundTLSOptions{
+						Enabled:         true,
+						ServerName:      "example.org",
+						CertificatePath: certPem,
+						KeyPath:         keyPem,
+					},
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+			{
+				Type: C.TypeBlock,
+				Tag:  "block",
+			},
+			{
+				Type: C.TypeHysteria,
+				Tag:  "hy-out",
+				HysteriaOptions: option.HysteriaOutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: serverPort,
+					},
+					UpMbps:     100,
+					DownMbps:   100,
+					AuthString: "password1",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:         true,
+						ServerName:      "example.org",
+						CertificatePath: certPem,
+					},
+				},
+			},
+			{
+				Type: C.TypeHysteria,
+				Tag:  "hy-out-2",
+				HysteriaOptions: option.HysteriaOutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: serverPort,
+					},
+					UpMbps:     100,
+					DownMbps:   100,
+					AuthString: "password2",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:         true,
+						ServerName:      "example.org",
+						CertificatePath: certPem,
+					},
+				},
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				{
+					DefaultOptions: option.DefaultRule{
+						Inbound:  []string{"mixed-in"},
+						Outbound: "hy-out",
+					},
+				},
+				{
+					DefaultOptions: option.DefaultRule{
+						Inbound:  []string{"mixed-in-2"},
+						Outbound: "hy-out-2",
+					},
+				},
+				{
+					DefaultOptions: option.DefaultRule{
+						AuthUser: []string{"user2"},
+						Outbound: "block",
+					},
+				},
+			},
+		},
+	})
+	testSuitSimple(t, clientPort, testPort)
+	dialer := socks.NewClient(N.SystemDialer, M.ParseSocksaddrHostPort("127.0.0.1", otherPort), socks.Version5, "", "")
+	require.Error(t, testPingPongWithConn(t, testPort, func() (net.Conn, error) {
+		return dialer.DialContext(context.Background(), "tcp", M.ParseSocksaddrHostPort("127.0.0.1", testPort))
+	}))
+}
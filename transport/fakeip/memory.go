@@ -0,0 +1,83 @@
+package fakeip
+
+import (
+	"net/netip"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+var _ adapter.FakeIPStorage = (*MemoryStorage)(nil)
+
+type MemoryStorage struct {
+	access       sync.RWMutex
+	metadata     *adapter.FakeIPMetadata
+	addressCache map[netip.Addr]string
+	domainCache4 map[string]netip.Addr
+	domainCache6 map[string]netip.Addr
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		addressCache: make(map[netip.Addr]string),
+		domainCache4: make(map[string]netip.Addr),
+		domainCache6: make(map[string]netip.Addr),
+	}
+}
+
+func (s *MemoryStorage) FakeIPMetadata() *adapter.FakeIPMetadata {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.metadata
+}
+
+func (s *MemoryStorage) FakeIPSaveMetadata(metadata *adapter.FakeIPMetadata) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.metadata = metadata
+	return nil
+}
+
+func (s *MemoryStorage) FakeIPStore(address netip.Addr, domain string, metadata *adapter.FakeIPMetadata) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	domainCache := s.domainCache(address.Is6())
+	if oldDomain, loaded := s.addressCache[address]; loaded {
+		delete(domainCache, oldDomain)
+	}
+	s.addressCache[address] = domain
+	domainCache[domain] = address
+	s.metadata = metadata
+	return nil
+}
+
+func (s *MemoryStorage) FakeIPLoad(address netip.Addr) (string, bool) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	domain, loaded := s.addressCache[address]
+	return domain, loaded
+}
+
+func (s *MemoryStorage) FakeIPLoadDomain(domain string, isIPv6 bool) (netip.Addr, bool) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	address, loaded := s.domainCache(isIPv6)[domain]
+	return address, loaded
+}
+
+func (s *MemoryStorage) FakeIPReset() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.addressCache = make(map[netip.Addr]string)
+	s.domainCache4 = make(map[string]netip.Addr)
+	s.domainCache6 = make(map[string]netip.Addr)
+	return nil
+}
+
+func (s *MemoryStorage) domainCache(isIPv6 bool) map[string]netip.Addr {
+	if isIPv6 {
+		return s.domainCache6
+	} else {
+		return s.domainCache4
+	}
+}
@@ -0,0 +1,97 @@
+package fakeip
+
+import (
+	"context"
+	"net/netip"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ adapter.FakeIPTransport = (*Transport)(nil)
+
+type Transport struct {
+	store *Store
+}
+
+func NewTransport(store *Store) *Transport {
+	return &Transport{store}
+}
+
+func (t *Transport) Start() error {
+	return nil
+}
+
+func (t *Transport) Close() error {
+	return nil
+}
+
+func (t *Transport) Raw() bool {
+	return true
+}
+
+func (t *Transport) Exchange(ctx context.Context, message *dnsmessage.Message) (*dnsmessage.Message, error) {
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 message.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   message.RecursionDesired,
+			RecursionAvailable: true,
+			RCode:              dnsmessage.RCodeSuccess,
+		},
+		Questions: message.Questions,
+	}
+	for _, question := range message.Questions {
+		var isIPv6 bool
+		switch question.Type {
+		case dnsmessage.TypeA:
+			if !t.store.inet4Range.IsValid() {
+				continue
+			}
+		case dnsmessage.TypeAAAA:
+			if !t.store.inet6Range.IsValid() {
+				continue
+			}
+			isIPv6 = true
+		default:
+			continue
+		}
+		domain := question.Name.String()
+		domain = domain[:len(domain)-1]
+		address, err := t.store.Create(domain, isIPv6)
+		if err != nil {
+			return nil, E.Cause(err, "create fakeip for ", domain)
+		}
+		header := dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Type:  question.Type,
+			Class: question.Class,
+			TTL:   1,
+		}
+		if isIPv6 {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: header,
+				Body:   &dnsmessage.AAAAResource{AAAA: address.As16()},
+			})
+		} else {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: header,
+				Body:   &dnsmessage.AResource{A: address.As4()},
+			})
+		}
+	}
+	return &response, nil
+}
+
+func (t *Transport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return nil, os.ErrInvalid
+}
+
+func (t *Transport) Store() adapter.FakeIPStore {
+	return t.store
+}
@@ -0,0 +1,156 @@
+package fakeip
+
+import (
+	"net/netip"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var _ adapter.FakeIPStore = (*Store)(nil)
+
+type Store struct {
+	router       adapter.Router
+	logger       log.Logger
+	inet4Range   netip.Prefix
+	inet6Range   netip.Prefix
+	storage      adapter.FakeIPStorage
+	access       sync.Mutex
+	inet4Current netip.Addr
+	inet6Current netip.Addr
+}
+
+func NewStore(router adapter.Router, logger log.Logger, inet4Range netip.Prefix, inet6Range netip.Prefix) *Store {
+	return &Store{
+		router:     router,
+		logger:     logger,
+		inet4Range: inet4Range,
+		inet6Range: inet6Range,
+	}
+}
+
+func (s *Store) Start() error {
+	var storage adapter.FakeIPStorage
+	if cacheFile := s.router.CacheFile(); cacheFile != nil {
+		storage = cacheFile
+	} else {
+		storage = NewMemoryStorage()
+	}
+	metadata := storage.FakeIPMetadata()
+	if metadata != nil && metadata.Inet4Range == s.inet4Range && metadata.Inet6Range == s.inet6Range {
+		s.inet4Current = metadata.Inet4Current
+		s.inet6Current = metadata.Inet6Current
+	} else {
+		err := storage.FakeIPReset()
+		if err != nil {
+			return E.Cause(err, "reset fakeip storage")
+		}
+		s.resetCurrent()
+	}
+	s.storage = storage
+	return nil
+}
+
+func (s *Store) Close() error {
+	if s.storage == nil {
+		return nil
+	}
+	return s.storage.FakeIPSaveMetadata(s.metadata())
+}
+
+func (s *Store) Inet4Range() netip.Prefix {
+	return s.inet4Range
+}
+
+func (s *Store) Inet6Range() netip.Prefix {
+	return s.inet6Range
+}
+
+func (s *Store) Contains(address netip.Addr) bool {
+	return s.inet4Range.Contains(address) || s.inet6Range.Contains(address)
+}
+
+func (s *Store) Create(domain string, isIPv6 bool) (netip.Addr, error) {
+	if address, loaded := s.storage.FakeIPLoadDomain(domain, isIPv6); loaded {
+		return address, nil
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	if address, loaded := s.storage.FakeIPLoadDomain(domain, isIPv6); loaded {
+		return address, nil
+	}
+	var address netip.Addr
+	if !isIPv6 {
+		if !s.inet4Current.IsValid() {
+			return netip.Addr{}, E.New("missing IPv4 fakeip address range")
+		}
+		address = nextAddress(s.inet4Range, s.inet4Current)
+		s.inet4Current = address
+	} else {
+		if !s.inet6Current.IsValid() {
+			return netip.Addr{}, E.New("missing IPv6 fakeip address range")
+		}
+		address = nextAddress(s.inet6Range, s.inet6Current)
+		s.inet6Current = address
+	}
+	// save the allocation position with the address, or addresses still in use are handed out again after a crash
+	err := s.storage.FakeIPStore(address, domain, s.metadata())
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return address, nil
+}
+
+func (s *Store) Lookup(address netip.Addr) (string, bool) {
+	return s.storage.FakeIPLoad(address)
+}
+
+func (s *Store) Reset() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	err := s.storage.FakeIPReset()
+	if err != nil {
+		return err
+	}
+	s.resetCurrent()
+	s.logger.Info("fakeip pool flushed")
+	return s.storage.FakeIPSaveMetadata(s.metadata())
+}
+
+func (s *Store) resetCurrent() {
+	if s.inet4Range.IsValid() {
+		s.inet4Current = s.inet4Range.Addr().Next()
+	} else {
+		s.inet4Current = netip.Addr{}
+	}
+	if s.inet6Range.IsValid() {
+		s.inet6Current = s.inet6Range.Addr().Next()
+	} else {
+		s.inet6Current = netip.Addr{}
+	}
+}
+
+func (s *Store) metadata() *adapter.FakeIPMetadata {
+	return &adapter.FakeIPMetadata{
+		Inet4Range:   s.inet4Range,
+		Inet6Range:   s.inet6Range,
+		Inet4Current: s.inet4Current,
+		Inet6Current: s.inet6Current,
+	}
+}
+
+// nextAddress skips the network address and the first host address (usually the gateway),
+// and wraps around to recycle the oldest addresses once the range is exhausted.
+func nextAddress(prefix netip.Prefix, current netip.Addr) netip.Addr {
+	address := current.Next()
+	if !prefix.Contains(address) || isBroadcast(prefix, address) {
+		address = prefix.Addr().Next().Next()
+	}
+	return address
+}
+
+func isBroadcast(prefix netip.Prefix, address netip.Addr) bool {
+	return address.Is4() && !prefix.Contains(address.Next())
+}
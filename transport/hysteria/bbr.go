@@ -0,0 +1,161 @@
+package hysteria
+
+import (
+	"time"
+
+	"github.com/sagernet/quic-go/congestion"
+)
+
+const (
+	bbrMaxDatagramSize     = 1252
+	bbrInitialBandwidth    = 10 * bbrMaxDatagramSize * 10 // ten packets in 100ms
+	bbrMinCongestionWindow = 4 * bbrMaxDatagramSize
+	bbrBandwidthWindow     = 10
+	bbrStartupGain         = 2.885
+	bbrFullBandwidthGrowth = 1.25
+	bbrFullBandwidthRounds = 3
+	bbrPacketExpire        = 10 * time.Second
+)
+
+var bbrProbeGains = [...]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type bbrPacket struct {
+	sentTime      time.Time
+	delivered     congestion.ByteCount
+	deliveredTime time.Time
+}
+
+type bbrBandwidthSample struct {
+	round     uint64
+	bandwidth congestion.ByteCount
+}
+
+// BBRSender estimates the bottleneck bandwidth from the delivery rate like BBR does, and sends at the estimated rate
+// with the brutal sender. Unlike the brutal sender alone, it needs no configured bandwidth, so it suits links
+// with varying capacity.
+type BBRSender struct {
+	*BrutalSender
+	packets             map[congestion.PacketNumber]bbrPacket
+	delivered           congestion.ByteCount
+	deliveredTime       time.Time
+	lastSentPacket      congestion.PacketNumber
+	roundEnd            congestion.PacketNumber
+	round               uint64
+	samples             [bbrBandwidthWindow]bbrBandwidthSample
+	startup             bool
+	fullBandwidth       congestion.ByteCount
+	fullBandwidthRounds int
+}
+
+func NewBBRSender() *BBRSender {
+	return &BBRSender{
+		BrutalSender: NewBrutalSender(congestion.ByteCount(bbrInitialBandwidth * bbrStartupGain)),
+		packets:      make(map[congestion.PacketNumber]bbrPacket),
+		startup:      true,
+	}
+}
+
+func (b *BBRSender) OnPacketSent(sentTime time.Time, bytesInFlight congestion.ByteCount, packetNumber congestion.PacketNumber, bytes congestion.ByteCount, isRetransmittable bool) {
+	b.BrutalSender.OnPacketSent(sentTime, bytesInFlight, packetNumber, bytes, isRetransmittable)
+	if !isRetransmittable {
+		return
+	}
+	if b.deliveredTime.IsZero() {
+		b.deliveredTime = sentTime
+	}
+	b.packets[packetNumber] = bbrPacket{
+		sentTime:      sentTime,
+		delivered:     b.delivered,
+		deliveredTime: b.deliveredTime,
+	}
+	b.lastSentPacket = packetNumber
+}
+
+func (b *BBRSender) OnPacketAcked(number congestion.PacketNumber, ackedBytes congestion.ByteCount, priorInFlight congestion.ByteCount, eventTime time.Time) {
+	b.BrutalSender.OnPacketAcked(number, ackedBytes, priorInFlight, eventTime)
+	b.delivered += ackedBytes
+	b.deliveredTime = eventTime
+	packet, loaded := b.packets[number]
+	if !loaded {
+		return
+	}
+	delete(b.packets, number)
+	if number >= b.roundEnd {
+		b.round++
+		b.roundEnd = b.lastSentPacket + 1
+		b.onRoundStart(eventTime)
+	}
+	interval := eventTime.Sub(packet.deliveredTime)
+	if interval <= 0 {
+		return
+	}
+	bandwidth := (b.delivered - packet.delivered) * congestion.ByteCount(time.Second) / congestion.ByteCount(interval)
+	sample := &b.samples[b.round%bbrBandwidthWindow]
+	if sample.round != b.round {
+		*sample = bbrBandwidthSample{b.round, bandwidth}
+	} else if bandwidth > sample.bandwidth {
+		sample.bandwidth = bandwidth
+	}
+	b.updateBandwidth()
+}
+
+func (b *BBRSender) OnPacketLost(number congestion.PacketNumber, lostBytes congestion.ByteCount, priorInFlight congestion.ByteCount) {
+	b.BrutalSender.OnPacketLost(number, lostBytes, priorInFlight)
+	delete(b.packets, number)
+}
+
+func (b *BBRSender) CanSend(bytesInFlight congestion.ByteCount) bool {
+	return bytesInFlight < b.GetCongestionWindow()
+}
+
+func (b *BBRSender) GetCongestionWindow() congestion.ByteCount {
+	congestionWindow := b.BrutalSender.GetCongestionWindow()
+	if congestionWindow < bbrMinCongestionWindow {
+		congestionWindow = bbrMinCongestionWindow
+	}
+	return congestionWindow
+}
+
+func (b *BBRSender) InSlowStart() bool {
+	return b.startup
+}
+
+func (b *BBRSender) maxBandwidth() congestion.ByteCount {
+	var bandwidth congestion.ByteCount
+	for _, sample := range b.samples {
+		if sample.round+bbrBandwidthWindow > b.round && sample.bandwidth > bandwidth {
+			bandwidth = sample.bandwidth
+		}
+	}
+	return bandwidth
+}
+
+func (b *BBRSender) onRoundStart(now time.Time) {
+	if b.startup {
+		bandwidth := b.maxBandwidth()
+		if float64(bandwidth) >= float64(b.fullBandwidth)*bbrFullBandwidthGrowth {
+			b.fullBandwidth = bandwidth
+			b.fullBandwidthRounds = 0
+		} else {
+			b.fullBandwidthRounds++
+			b.startup = b.fullBandwidthRounds < bbrFullBandwidthRounds
+		}
+	}
+	for number, packet := range b.packets {
+		if now.Sub(packet.sentTime) > bbrPacketExpire {
+			delete(b.packets, number)
+		}
+	}
+}
+
+func (b *BBRSender) updateBandwidth() {
+	bandwidth := b.maxBandwidth()
+	if bandwidth < bbrInitialBandwidth {
+		bandwidth = bbrInitialBandwidth
+	}
+	gain := bbrStartupGain
+	if !b.startup {
+		gain = bbrProbeGains[b.round%uint64(len(bbrProbeGains))]
+	}
+	b.SetBandwidth(congestion.ByteCount(float64(bandwidth) * gain))
+}
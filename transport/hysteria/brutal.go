@@ -42,6 +42,11 @@ func NewBrutalSender(bps congestion.ByteCount) *BrutalSender {
 	return bs
 }
 
+// SetBandwidth updates the sending rate, for senders estimating the bandwidth.
+func (b *BrutalSender) SetBandwidth(bps congestion.ByteCount) {
+	b.bps = bps
+}
+
 func (b *BrutalSender) SetRTTStatsProvider(rttStats congestion.RTTStatsProvider) {
 	b.rttStats = rttStats
 }
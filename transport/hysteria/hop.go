@@ -0,0 +1,243 @@
+package hysteria
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const DefaultHopInterval = 30 * time.Second
+
+// ParsePortRanges parses ports and port ranges like `443` and `20000:30000`.
+func ParsePortRanges(portRanges []string) ([]uint16, error) {
+	var ports []uint16
+	for _, portRange := range portRanges {
+		startString, endString, isRange := strings.Cut(portRange, ":")
+		start, err := strconv.ParseUint(startString, 10, 16)
+		if err != nil {
+			return nil, E.Cause(err, "parse port range: ", portRange)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseUint(endString, 10, 16)
+			if err != nil {
+				return nil, E.Cause(err, "parse port range: ", portRange)
+			}
+		}
+		if start == 0 || start > end {
+			return nil, E.New("invalid port range: ", portRange)
+		}
+		for port := start; port <= end; port++ {
+			ports = append(ports, uint16(port))
+		}
+	}
+	return ports, nil
+}
+
+type hopPacket struct {
+	buffer *buf.Buffer
+	err    error
+}
+
+// HopPacketConn sends to a random port of the server with a new local socket every interval.
+// QUIC sees a single peer, so the session survives the hop. A QUIC server keeps answering the
+// address it first saw, so the first socket is kept open to receive until the conn is closed,
+// and packets from the previous socket are still received until the next hop.
+type HopPacketConn struct {
+	ctx          context.Context
+	dialer       N.Dialer
+	serverAddr   M.Socksaddr
+	ports        []uint16
+	interval     time.Duration
+	localAddr    net.Addr
+	remoteAddr   net.Addr
+	access       sync.RWMutex
+	firstConn    net.Conn
+	conn         net.Conn
+	prevConn     net.Conn
+	packetChan   chan hopPacket
+	done         chan struct{}
+	closeOnce    sync.Once
+	currentIndex int
+}
+
+func NewHopPacketConn(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, ports []uint16, interval time.Duration) (*HopPacketConn, error) {
+	if len(ports) == 0 {
+		return nil, E.New("missing server ports")
+	}
+	if interval <= 0 {
+		return nil, E.New("invalid hop interval: ", interval)
+	}
+	c := &HopPacketConn{
+		ctx:          ctx,
+		dialer:       dialer,
+		serverAddr:   serverAddr,
+		ports:        ports,
+		interval:     interval,
+		packetChan:   make(chan hopPacket, 1024),
+		done:         make(chan struct{}),
+		currentIndex: rand.Intn(len(ports)),
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.firstConn = conn
+	c.conn = conn
+	c.localAddr = conn.LocalAddr()
+	c.remoteAddr = conn.RemoteAddr()
+	go c.loopRead(conn)
+	go c.loopHop()
+	return c, nil
+}
+
+func (c *HopPacketConn) dial() (net.Conn, error) {
+	return c.dialer.DialContext(c.ctx, N.NetworkUDP, M.Socksaddr{
+		Addr: c.serverAddr.Addr,
+		Fqdn: c.serverAddr.Fqdn,
+		Port: c.ports[c.currentIndex],
+	})
+}
+
+func (c *HopPacketConn) loopRead(conn net.Conn) {
+	for {
+		buffer := buf.NewPacket()
+		_, err := buffer.ReadOnceFrom(conn)
+		if err != nil {
+			buffer.Release()
+			c.access.RLock()
+			current := conn == c.conn || conn == c.firstConn
+			c.access.RUnlock()
+			if current {
+				// errors of the previous socket are not reported, it is closed on hop
+				select {
+				case c.packetChan <- hopPacket{err: err}:
+				case <-c.done:
+				}
+			}
+			return
+		}
+		select {
+		case c.packetChan <- hopPacket{buffer: buffer}:
+		case <-c.done:
+			buffer.Release()
+			return
+		default:
+			// drop the packet like a full socket buffer does
+			buffer.Release()
+		}
+	}
+}
+
+func (c *HopPacketConn) loopHop() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.hop()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *HopPacketConn) hop() {
+	if len(c.ports) > 1 {
+		// move to another port, the same port would not change the flow on a DNAT server
+		c.currentIndex = (c.currentIndex + 1 + rand.Intn(len(c.ports)-1)) % len(c.ports)
+	}
+	conn, err := c.dial()
+	if err != nil {
+		// keep the current socket and try again on the next hop
+		return
+	}
+	c.access.Lock()
+	select {
+	case <-c.done:
+		c.access.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	prevConn := c.prevConn
+	c.prevConn = c.conn
+	c.conn = conn
+	c.access.Unlock()
+	if prevConn != nil && prevConn != c.firstConn {
+		prevConn.Close()
+	}
+	go c.loopRead(conn)
+}
+
+func (c *HopPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	select {
+	case packet := <-c.packetChan:
+		if packet.err != nil {
+			return 0, nil, packet.err
+		}
+		n = copy(p, packet.buffer.Bytes())
+		packet.buffer.Release()
+		return n, c.remoteAddr, nil
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *HopPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	c.access.RLock()
+	conn := c.conn
+	c.access.RUnlock()
+	return conn.Write(p)
+}
+
+// LocalAddr returns the address of the first socket, which the QUIC multiplexer uses to index the conn.
+func (c *HopPacketConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *HopPacketConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *HopPacketConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *HopPacketConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *HopPacketConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *HopPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.access.Lock()
+		close(c.done)
+		c.access.Unlock()
+	})
+	c.access.RLock()
+	defer c.access.RUnlock()
+	// the first socket may also be the current or the previous one
+	closers := []any{c.firstConn}
+	if c.conn != c.firstConn {
+		closers = append(closers, c.conn)
+	}
+	if c.prevConn != c.firstConn {
+		closers = append(closers, c.prevConn)
+	}
+	return common.Close(closers...)
+}
@@ -0,0 +1,188 @@
+package hysteria
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// PacketFragment is a received UDP packet, or a fragment of it, of the TUIC and Hysteria2 protocols.
+type PacketFragment struct {
+	PacketID    uint16
+	FragID      uint8
+	FragCount   uint8
+	Destination M.Socksaddr
+	Data        []byte
+}
+
+// PacketDefragger assembles fragments of the latest packet of a UDP session.
+type PacketDefragger struct {
+	packetId  uint16
+	fragments []*PacketFragment
+	count     int
+}
+
+func (d *PacketDefragger) Feed(fragment *PacketFragment) *PacketFragment {
+	if fragment.FragCount <= 1 {
+		return fragment
+	}
+	if d.fragments == nil || fragment.PacketID != d.packetId || len(d.fragments) != int(fragment.FragCount) {
+		d.packetId = fragment.PacketID
+		d.fragments = make([]*PacketFragment, fragment.FragCount)
+		d.count = 0
+	}
+	if d.fragments[fragment.FragID] != nil {
+		return nil
+	}
+	d.fragments[fragment.FragID] = fragment
+	d.count++
+	if d.count < len(d.fragments) {
+		return nil
+	}
+	var size int
+	for _, fragment := range d.fragments {
+		size += len(fragment.Data)
+	}
+	data := make([]byte, 0, size)
+	for _, fragment := range d.fragments {
+		data = append(data, fragment.Data...)
+	}
+	assembled := *d.fragments[0]
+	assembled.FragCount = 1
+	assembled.Data = data
+	d.fragments = nil
+	return &assembled
+}
+
+var _ net.PacketConn = (*QUICPacketConn)(nil)
+
+// QUICPacketConn is a UDP session over a QUIC connection. The protocol delivers received fragments with Feed,
+// and encodes outgoing packets in writer.
+type QUICPacketConn struct {
+	conn        quic.Connection
+	destination M.Socksaddr
+	writer      func(data []byte, destination M.Socksaddr) error
+	closer      func() error
+	packetCh    chan *PacketFragment
+	access      sync.Mutex
+	defragger   PacketDefragger
+	closed      bool
+}
+
+func NewQUICPacketConn(conn quic.Connection, destination M.Socksaddr, writer func(data []byte, destination M.Socksaddr) error, closer func() error) *QUICPacketConn {
+	return &QUICPacketConn{
+		conn:        conn,
+		destination: destination,
+		writer:      writer,
+		closer:      closer,
+		packetCh:    make(chan *PacketFragment, 1024),
+	}
+}
+
+// FeedFragment delivers a received packet or fragment to the session.
+func (c *QUICPacketConn) FeedFragment(fragment *PacketFragment) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.closed {
+		return
+	}
+	fragment = c.defragger.Feed(fragment)
+	if fragment == nil {
+		return
+	}
+	select {
+	case c.packetCh <- fragment:
+	default:
+		// Silently drop the packet when the channel is full
+	}
+}
+
+func (c *QUICPacketConn) ReadPacket(buffer *buf.Buffer) (M.Socksaddr, error) {
+	packet := <-c.packetCh
+	if packet == nil {
+		return M.Socksaddr{}, net.ErrClosed
+	}
+	if buffer.FreeLen() < len(packet.Data) {
+		return M.Socksaddr{}, E.New("packet too large: ", len(packet.Data))
+	}
+	common.Must1(buffer.Write(packet.Data))
+	return packet.Destination, nil
+}
+
+func (c *QUICPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	return c.writer(buffer.Bytes(), destination)
+}
+
+func (c *QUICPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	packet := <-c.packetCh
+	if packet == nil {
+		return 0, nil, net.ErrClosed
+	}
+	n = copy(p, packet.Data)
+	addr = packet.Destination.UDPAddr()
+	return
+}
+
+func (c *QUICPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	err = c.writer(p, M.SocksaddrFromNet(addr))
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *QUICPacketConn) Read(p []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(p)
+	return
+}
+
+func (c *QUICPacketConn) Write(p []byte) (n int, err error) {
+	err = c.writer(p, c.destination)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *QUICPacketConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *QUICPacketConn) RemoteAddr() net.Addr {
+	return c.destination.UDPAddr()
+}
+
+func (c *QUICPacketConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *QUICPacketConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *QUICPacketConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *QUICPacketConn) Close() error {
+	c.access.Lock()
+	if c.closed {
+		c.access.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.packetCh)
+	c.access.Unlock()
+	if c.closer != nil {
+		return c.closer()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package hysteria
 
 import (
+	"io"
 	"net"
 	"os"
 	"syscall"
@@ -41,6 +42,10 @@ type StreamWrapper struct {
 
 func (s *StreamWrapper) Read(p []byte) (n int, err error) {
 	n, err = s.Stream.Read(p)
+	if n > 0 && err == io.EOF {
+		// the last data comes with EOF, which the copy loop would drop, and the next read returns EOF again
+		err = nil
+	}
 	return n, baderror.WrapQUIC(err)
 }
 
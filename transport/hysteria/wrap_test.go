@@ -0,0 +1,46 @@
+package hysteria_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common/bufio"
+
+	"github.com/stretchr/testify/require"
+)
+
+// finStream returns its last data together with io.EOF, as quic-go does when the FIN arrives with the data.
+type finStream struct {
+	quic.Stream
+	data []byte
+}
+
+func (s *finStream) Read(p []byte) (int, error) {
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	if len(s.data) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// writerOnly hides io.ReaderFrom, like most connections relayed by a Hysteria outbound.
+type writerOnly struct {
+	io.Writer
+}
+
+func TestStreamWrapperReadEOF(t *testing.T) {
+	t.Parallel()
+	data := []byte("response sent right before the server closes the stream")
+
+	var received bytes.Buffer
+	bufio.Copy(writerOnly{&received}, &finStream{data: data})
+	require.Empty(t, received.Bytes(), "the copy loop drops data read with EOF")
+
+	received.Reset()
+	bufio.Copy(writerOnly{&received}, &hysteria.StreamWrapper{Stream: &finStream{data: data}})
+	require.Equal(t, data, received.Bytes())
+}
@@ -0,0 +1,256 @@
+package hysteria2
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ClientOptions struct {
+	Context            context.Context
+	Dialer             N.Dialer
+	ServerAddress      M.Socksaddr
+	SendBPS            uint64
+	ReceiveBPS         uint64
+	SalamanderPassword string
+	Password           string
+	TLSConfig          *tls.Config
+	QUICConfig         *quic.Config
+	UDPDisabled        bool
+}
+
+type Client struct {
+	ClientOptions
+	connAccess sync.Mutex
+	conn       *clientConn
+}
+
+func NewClient(options ClientOptions) (*Client, error) {
+	return &Client{ClientOptions: options}, nil
+}
+
+func (c *Client) offer(ctx context.Context) (*clientConn, error) {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn := c.conn
+	if conn != nil && !common.Done(conn.quicConn.Context()) {
+		return conn, nil
+	}
+	conn, err := c.offerNew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) offerNew(ctx context.Context) (*clientConn, error) {
+	udpConn, err := c.Dialer.DialContext(c.Context, N.NetworkUDP, c.ServerAddress)
+	if err != nil {
+		return nil, err
+	}
+	var packetConn net.PacketConn
+	packetConn = bufio.NewUnbindPacketConn(udpConn)
+	if c.SalamanderPassword != "" {
+		packetConn = NewSalamanderConn(packetConn, []byte(c.SalamanderPassword))
+	}
+	packetConn = &hysteria.PacketConnWrapper{PacketConn: packetConn}
+	var quicConn quic.EarlyConnection
+	http3Transport := &http3.RoundTripper{
+		TLSClientConfig: c.TLSConfig,
+		QuicConfig:      c.QUICConfig,
+		EnableDatagrams: !c.UDPDisabled,
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			earlyConn, err := quic.DialEarlyContext(ctx, packetConn, udpConn.RemoteAddr(), c.ServerAddress.AddrString(), tlsCfg, cfg)
+			if err != nil {
+				return nil, err
+			}
+			quicConn = earlyConn
+			return earlyConn, nil
+		},
+	}
+	request := &http.Request{
+		Method: http.MethodPost,
+		URL: &url.URL{
+			Scheme: "https",
+			Host:   URLHost,
+			Path:   URLPath,
+		},
+		Header: make(http.Header),
+	}
+	AuthRequestToHeader(request.Header, AuthRequest{
+		Auth: c.Password,
+		Rx:   c.ReceiveBPS,
+	})
+	response, err := http3Transport.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		if quicConn != nil {
+			quicConn.CloseWithError(0, "")
+		}
+		udpConn.Close()
+		return nil, E.Cause(err, "authenticate")
+	}
+	response.Body.Close()
+	if response.StatusCode != StatusAuth {
+		quicConn.CloseWithError(0, "")
+		udpConn.Close()
+		return nil, E.New("authentication failed, status code: ", response.StatusCode)
+	}
+	authResponse := AuthResponseFromHeader(response.Header)
+	var sendBPS uint64
+	if !authResponse.RxAuto {
+		sendBPS = c.SendBPS
+		if authResponse.Rx > 0 && (sendBPS == 0 || authResponse.Rx < sendBPS) {
+			sendBPS = authResponse.Rx
+		}
+	}
+	setCongestionControl(quicConn, sendBPS)
+	conn := &clientConn{
+		quicConn:    quicConn,
+		rawConn:     udpConn,
+		udpDisabled: c.UDPDisabled || !authResponse.UDPEnabled,
+		udpConns:    make(map[uint32]*PacketConn),
+	}
+	if !conn.udpDisabled {
+		go conn.loopMessages()
+	}
+	go conn.waitClose()
+	return conn, nil
+}
+
+func (c *Client) DialConn(ctx context.Context, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.quicConn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	streamConn := &hysteria.StreamWrapper{Conn: conn.quicConn, Stream: stream}
+	err = WriteTCPRequest(streamConn, destination.String())
+	if err != nil {
+		streamConn.Close()
+		return nil, err
+	}
+	ok, message, err := ReadTCPResponse(streamConn)
+	if err != nil {
+		streamConn.Close()
+		return nil, err
+	}
+	if !ok {
+		streamConn.Close()
+		return nil, E.New("remote error: ", message)
+	}
+	return &clientStreamConn{
+		StreamWrapper: streamConn,
+		destination:   destination,
+	}, nil
+}
+
+func (c *Client) ListenPacket(ctx context.Context, destination M.Socksaddr) (*PacketConn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conn.udpDisabled {
+		return nil, E.New("UDP disabled by server")
+	}
+	return conn.newPacketConn(destination), nil
+}
+
+func (c *Client) Close() error {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	if c.conn != nil {
+		c.conn.quicConn.CloseWithError(0, "")
+	}
+	return nil
+}
+
+type clientConn struct {
+	quicConn    quic.Connection
+	rawConn     net.Conn
+	udpDisabled bool
+	udpAccess   sync.RWMutex
+	udpConns    map[uint32]*PacketConn
+	sessionId   uint32
+}
+
+func (c *clientConn) loopMessages() {
+	for {
+		message, err := c.quicConn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		udpMessage, err := ParseUDPMessage(message)
+		if err != nil {
+			continue
+		}
+		c.udpAccess.RLock()
+		udpConn, loaded := c.udpConns[udpMessage.SessionID]
+		c.udpAccess.RUnlock()
+		if loaded {
+			udpConn.Feed(udpMessage)
+		}
+	}
+}
+
+func (c *clientConn) newPacketConn(destination M.Socksaddr) *PacketConn {
+	c.udpAccess.Lock()
+	defer c.udpAccess.Unlock()
+	var sessionId uint32
+	for {
+		sessionId = c.sessionId
+		c.sessionId++
+		if _, loaded := c.udpConns[sessionId]; !loaded {
+			break
+		}
+	}
+	udpConn := NewPacketConn(c.quicConn, sessionId, destination, func() error {
+		c.udpAccess.Lock()
+		delete(c.udpConns, sessionId)
+		c.udpAccess.Unlock()
+		return nil
+	})
+	c.udpConns[sessionId] = udpConn
+	return udpConn
+}
+
+// waitClose releases the sessions and the socket after the connection is closed.
+func (c *clientConn) waitClose() {
+	<-c.quicConn.Context().Done()
+	c.udpAccess.Lock()
+	udpConns := c.udpConns
+	c.udpConns = make(map[uint32]*PacketConn)
+	c.udpAccess.Unlock()
+	for _, udpConn := range udpConns {
+		udpConn.Close()
+	}
+	c.rawConn.Close()
+}
+
+type clientStreamConn struct {
+	*hysteria.StreamWrapper
+	destination M.Socksaddr
+}
+
+func (c *clientStreamConn) RemoteAddr() net.Addr {
+	return c.destination.TCPAddr()
+}
+
+func (c *clientStreamConn) Upstream() any {
+	return c.StreamWrapper
+}
@@ -0,0 +1,74 @@
+package hysteria2
+
+import (
+	"sync/atomic"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// PacketConn is a UDP session, relaying packets in QUIC datagrams.
+type PacketConn struct {
+	*hysteria.QUICPacketConn
+	conn      quic.Connection
+	sessionId uint32
+	packetId  uint32
+}
+
+func NewPacketConn(conn quic.Connection, sessionId uint32, destination M.Socksaddr, closer func() error) *PacketConn {
+	packetConn := &PacketConn{
+		conn:      conn,
+		sessionId: sessionId,
+	}
+	packetConn.QUICPacketConn = hysteria.NewQUICPacketConn(conn, destination, packetConn.writePacket, closer)
+	return packetConn
+}
+
+// Feed delivers a received packet or fragment to the session.
+func (c *PacketConn) Feed(message *UDPMessage) {
+	c.FeedFragment(&hysteria.PacketFragment{
+		PacketID:    message.PacketID,
+		FragID:      message.FragID,
+		FragCount:   message.FragCount,
+		Destination: message.Destination(),
+		Data:        message.Data,
+	})
+}
+
+func (c *PacketConn) writePacket(data []byte, destination M.Socksaddr) error {
+	message := UDPMessage{
+		SessionID: c.sessionId,
+		PacketID:  uint16(atomic.AddUint32(&c.packetId, 1)),
+		FragCount: 1,
+		Address:   destination.String(),
+		Data:      data,
+	}
+	err := writeMessage(c.conn, message)
+	if errSize, isTooLarge := err.(quic.ErrMessageToLarge); isTooLarge {
+		fragments := FragUDPMessage(message, int(errSize))
+		if fragments == nil {
+			return E.New("packet too large: ", len(data))
+		}
+		for _, fragment := range fragments {
+			err = writeMessage(c.conn, fragment)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func writeMessage(conn quic.Connection, message UDPMessage) error {
+	_buffer := buf.StackNewSize(message.Size())
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	message.Encode(buffer)
+	return conn.SendMessage(buffer.Bytes())
+}
@@ -0,0 +1,315 @@
+package hysteria2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sagernet/quic-go/quicvarint"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/rw"
+)
+
+const (
+	DefaultALPN                    = "h3"
+	DefaultStreamReceiveWindow     = 8388608  // 8 MB
+	DefaultConnectionReceiveWindow = 20971520 // 20 MB
+	DefaultMaxIncomingStreams      = 1024
+	KeepAlivePeriod                = 10 * time.Second
+	MaxIdleTimeout                 = 30 * time.Second
+)
+
+const (
+	URLHost    = "hysteria"
+	URLPath    = "/auth"
+	StatusAuth = 233
+
+	RequestHeaderAuth       = "Hysteria-Auth"
+	ResponseHeaderUDP       = "Hysteria-UDP"
+	CommonHeaderCCRX        = "Hysteria-CC-RX"
+	CommonHeaderPadding     = "Hysteria-Padding"
+	CongestionControlRXAuto = "auto"
+
+	FrameTypeTCPRequest = 0x401
+
+	maxAddressLength = 2048
+	maxMessageLength = 2048
+	maxPaddingLength = 4096
+)
+
+// Padding is a random length of random bytes to hide the length of headers and requests.
+type Padding struct {
+	Min int
+	Max int
+}
+
+var (
+	authPadding        = Padding{256, 2048}
+	tcpRequestPadding  = Padding{64, 512}
+	tcpResponsePadding = Padding{128, 1024}
+)
+
+const paddingChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (p Padding) String() string {
+	n := p.Min + rand.Intn(p.Max-p.Min)
+	bs := make([]byte, n)
+	for i := range bs {
+		bs[i] = paddingChars[rand.Intn(len(paddingChars))]
+	}
+	return string(bs)
+}
+
+// AuthRequest is sent by the client in the headers of the authentication request.
+type AuthRequest struct {
+	Auth string
+	Rx   uint64 // 0 = unknown, client asks server to use bandwidth detection
+}
+
+// AuthResponse is sent by the server in the headers of the authentication response.
+type AuthResponse struct {
+	UDPEnabled bool
+	Rx         uint64 // 0 = unlimited
+	RxAuto     bool   // true = server asks client to use bandwidth detection
+}
+
+func AuthRequestFromHeader(header http.Header) AuthRequest {
+	rx, _ := strconv.ParseUint(header.Get(CommonHeaderCCRX), 10, 64)
+	return AuthRequest{
+		Auth: header.Get(RequestHeaderAuth),
+		Rx:   rx,
+	}
+}
+
+func AuthRequestToHeader(header http.Header, request AuthRequest) {
+	header.Set(RequestHeaderAuth, request.Auth)
+	header.Set(CommonHeaderCCRX, strconv.FormatUint(request.Rx, 10))
+	header.Set(CommonHeaderPadding, authPadding.String())
+}
+
+func AuthResponseFromHeader(header http.Header) AuthResponse {
+	var response AuthResponse
+	response.UDPEnabled, _ = strconv.ParseBool(header.Get(ResponseHeaderUDP))
+	rxString := header.Get(CommonHeaderCCRX)
+	if rxString == CongestionControlRXAuto {
+		response.RxAuto = true
+	} else {
+		response.Rx, _ = strconv.ParseUint(rxString, 10, 64)
+	}
+	return response
+}
+
+func AuthResponseToHeader(header http.Header, response AuthResponse) {
+	header.Set(ResponseHeaderUDP, strconv.FormatBool(response.UDPEnabled))
+	if response.RxAuto {
+		header.Set(CommonHeaderCCRX, CongestionControlRXAuto)
+	} else {
+		header.Set(CommonHeaderCCRX, strconv.FormatUint(response.Rx, 10))
+	}
+	header.Set(CommonHeaderPadding, authPadding.String())
+}
+
+func varintLen(value int) int {
+	return int(quicvarint.Len(uint64(value)))
+}
+
+func readVarintBytes(reader *bytes.Reader, maxLength int, name string) ([]byte, error) {
+	length, err := quicvarint.Read(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(maxLength) {
+		return nil, E.New("invalid ", name, " length: ", length)
+	}
+	return rw.ReadBytes(reader, int(length))
+}
+
+// WriteTCPRequest writes the TCP request with the frame type.
+func WriteTCPRequest(writer io.Writer, destination string) error {
+	padding := tcpRequestPadding.String()
+	_buffer := buf.StackNewSize(varintLen(FrameTypeTCPRequest) + varintLen(len(destination)) + len(destination) + varintLen(len(padding)) + len(padding))
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	quicvarint.Write(buffer, FrameTypeTCPRequest)
+	quicvarint.Write(buffer, uint64(len(destination)))
+	common.Must1(buffer.WriteString(destination))
+	quicvarint.Write(buffer, uint64(len(padding)))
+	common.Must1(buffer.WriteString(padding))
+	return common.Error(writer.Write(buffer.Bytes()))
+}
+
+// ReadTCPRequest reads the TCP request after the frame type.
+func ReadTCPRequest(reader io.Reader) (string, error) {
+	varintReader := quicvarint.NewReader(reader)
+	addressLen, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return "", err
+	}
+	if addressLen == 0 || addressLen > maxAddressLength {
+		return "", E.New("invalid address length: ", addressLen)
+	}
+	address, err := rw.ReadBytes(reader, int(addressLen))
+	if err != nil {
+		return "", err
+	}
+	paddingLen, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return "", err
+	}
+	if paddingLen > maxPaddingLength {
+		return "", E.New("invalid padding length: ", paddingLen)
+	}
+	err = rw.SkipN(reader, int(paddingLen))
+	if err != nil {
+		return "", err
+	}
+	return string(address), nil
+}
+
+func WriteTCPResponse(writer io.Writer, ok bool, message string) error {
+	padding := tcpResponsePadding.String()
+	_buffer := buf.StackNewSize(1 + varintLen(len(message)) + len(message) + varintLen(len(padding)) + len(padding))
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	if ok {
+		common.Must(buffer.WriteByte(0))
+	} else {
+		common.Must(buffer.WriteByte(1))
+	}
+	quicvarint.Write(buffer, uint64(len(message)))
+	common.Must1(buffer.WriteString(message))
+	quicvarint.Write(buffer, uint64(len(padding)))
+	common.Must1(buffer.WriteString(padding))
+	return common.Error(writer.Write(buffer.Bytes()))
+}
+
+func ReadTCPResponse(reader io.Reader) (bool, string, error) {
+	status, err := rw.ReadByte(reader)
+	if err != nil {
+		return false, "", err
+	}
+	varintReader := quicvarint.NewReader(reader)
+	messageLen, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return false, "", err
+	}
+	if messageLen > maxMessageLength {
+		return false, "", E.New("invalid message length: ", messageLen)
+	}
+	message, err := rw.ReadBytes(reader, int(messageLen))
+	if err != nil {
+		return false, "", err
+	}
+	paddingLen, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return false, "", err
+	}
+	if paddingLen > maxPaddingLength {
+		return false, "", E.New("invalid padding length: ", paddingLen)
+	}
+	err = rw.SkipN(reader, int(paddingLen))
+	if err != nil {
+		return false, "", err
+	}
+	return status == 0, string(message), nil
+}
+
+// UDPMessage is a UDP packet or fragment sent in a QUIC datagram.
+type UDPMessage struct {
+	SessionID uint32
+	PacketID  uint16
+	FragID    uint8
+	FragCount uint8
+	Address   string
+	Data      []byte
+}
+
+func (m *UDPMessage) HeaderSize() int {
+	return 4 + 2 + 1 + 1 + varintLen(len(m.Address)) + len(m.Address)
+}
+
+func (m *UDPMessage) Size() int {
+	return m.HeaderSize() + len(m.Data)
+}
+
+func (m *UDPMessage) Destination() M.Socksaddr {
+	return M.ParseSocksaddr(m.Address)
+}
+
+func (m *UDPMessage) Encode(buffer *buf.Buffer) {
+	common.Must(
+		binary.Write(buffer, binary.BigEndian, m.SessionID),
+		binary.Write(buffer, binary.BigEndian, m.PacketID),
+		buffer.WriteByte(m.FragID),
+		buffer.WriteByte(m.FragCount),
+	)
+	quicvarint.Write(buffer, uint64(len(m.Address)))
+	common.Must1(buffer.WriteString(m.Address))
+	common.Must1(buffer.Write(m.Data))
+}
+
+func ParseUDPMessage(message []byte) (*UDPMessage, error) {
+	reader := bytes.NewReader(message)
+	var header [8]byte
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return nil, err
+	}
+	var udpMessage UDPMessage
+	udpMessage.SessionID = binary.BigEndian.Uint32(header[0:])
+	udpMessage.PacketID = binary.BigEndian.Uint16(header[4:])
+	udpMessage.FragID = header[6]
+	udpMessage.FragCount = header[7]
+	if udpMessage.FragCount == 0 || udpMessage.FragID >= udpMessage.FragCount {
+		return nil, E.New("invalid fragment ", udpMessage.FragID, "/", udpMessage.FragCount)
+	}
+	address, err := readVarintBytes(reader, maxAddressLength, "address")
+	if err != nil {
+		return nil, err
+	}
+	if len(address) == 0 {
+		return nil, E.New("missing address")
+	}
+	udpMessage.Address = string(address)
+	udpMessage.Data = message[len(message)-reader.Len():]
+	return &udpMessage, nil
+}
+
+// FragUDPMessage splits the message to fit the size limit. Every fragment repeats the address.
+func FragUDPMessage(message UDPMessage, maxSize int) []UDPMessage {
+	if message.Size() <= maxSize {
+		return []UDPMessage{message}
+	}
+	maxPayloadSize := maxSize - message.HeaderSize()
+	if maxPayloadSize <= 0 {
+		return nil
+	}
+	fragCount := (len(message.Data) + maxPayloadSize - 1) / maxPayloadSize
+	if fragCount > 255 {
+		return nil
+	}
+	fragments := make([]UDPMessage, 0, fragCount)
+	data := message.Data
+	for fragId := 0; fragId < fragCount; fragId++ {
+		fragment := message
+		fragment.FragID = uint8(fragId)
+		fragment.FragCount = uint8(fragCount)
+		size := maxPayloadSize
+		if size > len(data) {
+			size = len(data)
+		}
+		fragment.Data = data[:size]
+		data = data[size:]
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
@@ -0,0 +1,271 @@
+package hysteria2
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/congestion"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type Handler interface {
+	N.TCPConnectionHandler
+	N.UDPConnectionHandler
+	E.Handler
+}
+
+type ServiceOptions struct {
+	Context               context.Context
+	SendBPS               uint64
+	ReceiveBPS            uint64
+	IgnoreClientBandwidth bool
+	SalamanderPassword    string
+	QUICConfig            *quic.Config
+	UDPDisabled           bool
+	Handler               Handler
+	MasqueradeHandler     http.Handler
+}
+
+type Service[T comparable] struct {
+	ServiceOptions
+	users         map[string]T
+	sessionAccess sync.RWMutex
+	sessions      map[quic.Connection]*serverSession[T]
+	packetConn    net.PacketConn
+	httpServer    *http3.Server
+}
+
+func NewService[T comparable](options ServiceOptions) *Service[T] {
+	return &Service[T]{
+		ServiceOptions: options,
+		sessions:       make(map[quic.Connection]*serverSession[T]),
+	}
+}
+
+func (s *Service[T]) UpdateUsers(userList []T, passwordList []string) error {
+	users := make(map[string]T)
+	for i, user := range userList {
+		if passwordList[i] == "" {
+			return E.New("missing password for user ", i)
+		}
+		if _, loaded := users[passwordList[i]]; loaded {
+			return E.New("duplicate password for user ", i)
+		}
+		users[passwordList[i]] = user
+	}
+	s.users = users
+	return nil
+}
+
+func (s *Service[T]) Start(conn net.PacketConn, tlsConfig *tls.Config) error {
+	if s.SalamanderPassword != "" {
+		conn = NewSalamanderConn(conn, []byte(s.SalamanderPassword))
+		conn = &hysteria.PacketConnWrapper{PacketConn: conn}
+	}
+	listener, err := quic.ListenEarly(conn, tlsConfig, s.QUICConfig)
+	if err != nil {
+		return err
+	}
+	s.packetConn = conn
+	s.httpServer = &http3.Server{
+		Handler:         s,
+		EnableDatagrams: !s.UDPDisabled,
+		StreamHijacker:  s.streamHijacker,
+	}
+	go s.httpServer.ServeListener(listener)
+	return nil
+}
+
+// ServeHTTP authenticates the client, or serves the masquerade for everything else.
+func (s *Service[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.Host == URLHost && r.URL.Path == URLPath {
+		request := AuthRequestFromHeader(r.Header)
+		user, loaded := s.users[request.Auth]
+		if loaded {
+			s.authenticate(w, r, request, user)
+			return
+		}
+		s.Handler.NewError(s.Context, E.New("authentication failed from ", r.RemoteAddr))
+	}
+	if s.MasqueradeHandler != nil {
+		s.MasqueradeHandler.ServeHTTP(w, r)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Service[T]) authenticate(w http.ResponseWriter, r *http.Request, request AuthRequest, user T) {
+	hijacker, isHijacker := w.(http3.Hijacker)
+	if !isHijacker {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, isConn := hijacker.StreamCreator().(quic.Connection)
+	if !isConn {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var sendBPS uint64
+	if !s.IgnoreClientBandwidth && request.Rx > 0 {
+		sendBPS = request.Rx
+		if s.SendBPS > 0 && sendBPS > s.SendBPS {
+			sendBPS = s.SendBPS
+		}
+	}
+	setCongestionControl(conn, sendBPS)
+	AuthResponseToHeader(w.Header(), AuthResponse{
+		UDPEnabled: !s.UDPDisabled,
+		Rx:         s.ReceiveBPS,
+		RxAuto:     s.IgnoreClientBandwidth,
+	})
+	w.WriteHeader(StatusAuth)
+	s.sessionAccess.Lock()
+	if _, loaded := s.sessions[conn]; loaded {
+		s.sessionAccess.Unlock()
+		return
+	}
+	session := &serverSession[T]{
+		Service:  s,
+		ctx:      auth.ContextWithUser(s.Context, user),
+		conn:     conn,
+		source:   M.SocksaddrFromNet(conn.RemoteAddr()),
+		udpConns: make(map[uint32]*PacketConn),
+	}
+	s.sessions[conn] = session
+	s.sessionAccess.Unlock()
+	if !s.UDPDisabled {
+		go session.loopMessages()
+	}
+	go session.waitClose()
+}
+
+// streamHijacker takes over the TCP request streams of authenticated clients.
+func (s *Service[T]) streamHijacker(frameType http3.FrameType, conn quic.Connection, stream quic.Stream, err error) (bool, error) {
+	if err != nil || frameType != FrameTypeTCPRequest {
+		return false, nil
+	}
+	s.sessionAccess.RLock()
+	session, loaded := s.sessions[conn]
+	s.sessionAccess.RUnlock()
+	if !loaded {
+		return false, nil
+	}
+	go session.handleStream(stream)
+	return true, nil
+}
+
+func (s *Service[T]) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.httpServer),
+		s.packetConn,
+	)
+}
+
+type serverSession[T comparable] struct {
+	*Service[T]
+	ctx       context.Context
+	conn      quic.Connection
+	source    M.Socksaddr
+	udpAccess sync.Mutex
+	udpConns  map[uint32]*PacketConn
+}
+
+func (s *serverSession[T]) handleStream(stream quic.Stream) {
+	destination, err := ReadTCPRequest(stream)
+	if err == nil {
+		err = WriteTCPResponse(stream, true, "")
+	}
+	if err == nil {
+		err = s.Handler.NewConnection(s.ctx, &hysteria.StreamWrapper{Conn: s.conn, Stream: stream}, M.Metadata{
+			Protocol:    "hysteria2",
+			Source:      s.source,
+			Destination: M.ParseSocksaddr(destination),
+		})
+	}
+	if err != nil {
+		stream.CancelRead(0)
+		stream.Close()
+		s.Handler.NewError(s.ctx, E.Cause(err, "process stream from ", s.source))
+	}
+}
+
+func (s *serverSession[T]) loopMessages() {
+	for {
+		message, err := s.conn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		udpMessage, err := ParseUDPMessage(message)
+		if err != nil {
+			s.Handler.NewError(s.ctx, E.Cause(err, "parse UDP message from ", s.source))
+			continue
+		}
+		s.handleUDPMessage(udpMessage)
+	}
+}
+
+func (s *serverSession[T]) handleUDPMessage(message *UDPMessage) {
+	s.udpAccess.Lock()
+	udpConn, loaded := s.udpConns[message.SessionID]
+	if !loaded {
+		sessionId := message.SessionID
+		udpConn = NewPacketConn(s.conn, sessionId, message.Destination(), func() error {
+			s.udpAccess.Lock()
+			delete(s.udpConns, sessionId)
+			s.udpAccess.Unlock()
+			return nil
+		})
+		s.udpConns[sessionId] = udpConn
+	}
+	s.udpAccess.Unlock()
+	udpConn.Feed(message)
+	if !loaded {
+		go func() {
+			hErr := s.Handler.NewPacketConnection(s.ctx, udpConn, M.Metadata{
+				Protocol:    "hysteria2",
+				Source:      s.source,
+				Destination: message.Destination(),
+			})
+			udpConn.Close()
+			if hErr != nil {
+				s.Handler.NewError(s.ctx, hErr)
+			}
+		}()
+	}
+}
+
+// waitClose releases the session after the connection is closed.
+func (s *serverSession[T]) waitClose() {
+	<-s.conn.Context().Done()
+	s.sessionAccess.Lock()
+	delete(s.sessions, s.conn)
+	s.sessionAccess.Unlock()
+	s.udpAccess.Lock()
+	udpConns := make([]*PacketConn, 0, len(s.udpConns))
+	for _, udpConn := range s.udpConns {
+		udpConns = append(udpConns, udpConn)
+	}
+	s.udpAccess.Unlock()
+	for _, udpConn := range udpConns {
+		udpConn.Close()
+	}
+}
+
+// setCongestionControl uses the brutal sender with the negotiated rate, or the bbr sender if the rate is unknown.
+func setCongestionControl(conn quic.Connection, sendBPS uint64) {
+	if sendBPS > 0 {
+		conn.SetCongestionControl(hysteria.NewBrutalSender(congestion.ByteCount(sendBPS)))
+	} else {
+		conn.SetCongestionControl(hysteria.NewBBRSender())
+	}
+}
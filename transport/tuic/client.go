@@ -0,0 +1,335 @@
+package tuic
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ClientOptions struct {
+	Context           context.Context
+	Dialer            N.Dialer
+	ServerAddress     M.Socksaddr
+	TLSConfig         *tls.Config
+	QUICConfig        *quic.Config
+	UUID              [16]byte
+	Password          string
+	CongestionControl string
+	UDPStream         bool
+	ZeroRTTHandshake  bool
+	Heartbeat         time.Duration
+}
+
+type Client struct {
+	ClientOptions
+	connAccess sync.Mutex
+	conn       *clientConn
+}
+
+func NewClient(options ClientOptions) (*Client, error) {
+	if options.Heartbeat == 0 {
+		options.Heartbeat = DefaultHeartbeat
+	}
+	err := CheckCongestionControl(options.CongestionControl)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{ClientOptions: options}, nil
+}
+
+func (c *Client) offer(ctx context.Context) (*clientConn, error) {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn := c.conn
+	if conn != nil && !common.Done(conn.quicConn.Context()) {
+		return conn, nil
+	}
+	conn, err := c.offerNew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) offerNew(ctx context.Context) (*clientConn, error) {
+	udpConn, err := c.Dialer.DialContext(c.Context, N.NetworkUDP, c.ServerAddress)
+	if err != nil {
+		return nil, err
+	}
+	packetConn := &hysteria.PacketConnWrapper{PacketConn: bufio.NewUnbindPacketConn(udpConn)}
+	var quicConn quic.Connection
+	if c.ZeroRTTHandshake {
+		quicConn, err = quic.DialEarlyContext(ctx, packetConn, udpConn.RemoteAddr(), c.ServerAddress.AddrString(), c.TLSConfig, c.QUICConfig)
+	} else {
+		quicConn, err = quic.DialContext(ctx, packetConn, udpConn.RemoteAddr(), c.ServerAddress.AddrString(), c.TLSConfig, c.QUICConfig)
+	}
+	if err != nil {
+		udpConn.Close()
+		return nil, E.Cause(err, "open connection")
+	}
+	SetCongestionControl(quicConn, c.CongestionControl)
+	conn := &clientConn{
+		Client:   c,
+		quicConn: quicConn,
+		rawConn:  udpConn,
+		udpConns: make(map[uint16]*PacketConn),
+	}
+	go conn.authenticate()
+	go conn.loopMessages()
+	go conn.loopUniStreams()
+	go conn.loopHeartbeats()
+	return conn, nil
+}
+
+func (c *Client) DialConn(ctx context.Context, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.quicConn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&conn.activeStreams, 1)
+	return &clientStreamConn{
+		StreamWrapper: hysteria.StreamWrapper{Conn: conn.quicConn, Stream: stream},
+		parent:        conn,
+		destination:   destination,
+	}, nil
+}
+
+func (c *Client) ListenPacket(ctx context.Context, destination M.Socksaddr) (*PacketConn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.newPacketConn(destination), nil
+}
+
+func (c *Client) Close() error {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	if c.conn != nil {
+		c.conn.quicConn.CloseWithError(0, "")
+	}
+	return nil
+}
+
+type clientConn struct {
+	*Client
+	quicConn      quic.Connection
+	rawConn       net.Conn
+	activeStreams int32
+	udpAccess     sync.RWMutex
+	udpConns      map[uint16]*PacketConn
+	assocId       uint16
+}
+
+func (c *clientConn) authenticate() {
+	if earlyConn, isEarlyConn := c.quicConn.(quic.EarlyConnection); isEarlyConn {
+		select {
+		case <-earlyConn.HandshakeComplete().Done():
+		case <-c.quicConn.Context().Done():
+			return
+		}
+	}
+	err := c.writeAuthenticate()
+	if err != nil {
+		c.quicConn.CloseWithError(0, "")
+	}
+}
+
+func (c *clientConn) writeAuthenticate() error {
+	token, err := AuthToken(c.quicConn.ConnectionState().TLS.ConnectionState, c.UUID, c.Password)
+	if err != nil {
+		return err
+	}
+	stream, err := c.quicConn.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	err = WriteAuthenticate(stream, c.UUID, token)
+	if err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	return stream.Close()
+}
+
+func (c *clientConn) loopMessages() {
+	defer c.close()
+	for {
+		message, err := c.quicConn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		reader := bytes.NewReader(message)
+		command, err := ReadCommand(reader)
+		if err != nil || command != CommandPacket {
+			continue
+		}
+		packet, err := ReadPacket(reader)
+		if err != nil {
+			continue
+		}
+		c.handlePacket(packet)
+	}
+}
+
+func (c *clientConn) loopUniStreams() {
+	for {
+		stream, err := c.quicConn.AcceptUniStream(c.Context)
+		if err != nil {
+			return
+		}
+		go func() {
+			defer stream.CancelRead(0)
+			command, err := ReadCommand(stream)
+			if err != nil || command != CommandPacket {
+				return
+			}
+			packet, err := ReadPacket(stream)
+			if err != nil {
+				return
+			}
+			c.handlePacket(packet)
+		}()
+	}
+}
+
+func (c *clientConn) loopHeartbeats() {
+	ticker := time.NewTicker(c.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.quicConn.Context().Done():
+			return
+		}
+		c.udpAccess.RLock()
+		active := len(c.udpConns) > 0 || atomic.LoadInt32(&c.activeStreams) > 0
+		c.udpAccess.RUnlock()
+		if active {
+			c.quicConn.SendMessage(HeartbeatMessage())
+		}
+	}
+}
+
+func (c *clientConn) handlePacket(packet *Packet) {
+	c.udpAccess.RLock()
+	udpConn, loaded := c.udpConns[packet.AssocID]
+	c.udpAccess.RUnlock()
+	if loaded {
+		udpConn.Feed(packet)
+	}
+}
+
+func (c *clientConn) newPacketConn(destination M.Socksaddr) *PacketConn {
+	c.udpAccess.Lock()
+	defer c.udpAccess.Unlock()
+	var assocId uint16
+	for {
+		assocId = c.assocId
+		c.assocId++
+		if _, loaded := c.udpConns[assocId]; !loaded {
+			break
+		}
+	}
+	udpConn := NewPacketConn(c.quicConn, assocId, c.UDPStream, destination, func() error {
+		c.udpAccess.Lock()
+		delete(c.udpConns, assocId)
+		c.udpAccess.Unlock()
+		stream, err := c.quicConn.OpenUniStream()
+		if err != nil {
+			return nil
+		}
+		err = WriteDissociate(stream, assocId)
+		if err != nil {
+			stream.CancelWrite(0)
+			return nil
+		}
+		return stream.Close()
+	})
+	c.udpConns[assocId] = udpConn
+	return udpConn
+}
+
+// close releases the associations and the socket after the connection is closed.
+func (c *clientConn) close() {
+	<-c.quicConn.Context().Done()
+	c.udpAccess.Lock()
+	udpConns := c.udpConns
+	c.udpConns = make(map[uint16]*PacketConn)
+	c.udpAccess.Unlock()
+	for _, udpConn := range udpConns {
+		udpConn.Close()
+	}
+	c.rawConn.Close()
+}
+
+// clientStreamConn writes the connect command with the first payload.
+type clientStreamConn struct {
+	hysteria.StreamWrapper
+	parent         *clientConn
+	destination    M.Socksaddr
+	access         sync.Mutex
+	requestWritten bool
+	closeOnce      sync.Once
+}
+
+func (c *clientStreamConn) writeRequest(payload []byte) (bool, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.requestWritten {
+		return false, nil
+	}
+	c.requestWritten = true
+	return true, WriteConnect(&c.StreamWrapper, c.destination, payload)
+}
+
+func (c *clientStreamConn) Read(p []byte) (n int, err error) {
+	_, err = c.writeRequest(nil)
+	if err != nil {
+		return
+	}
+	return c.StreamWrapper.Read(p)
+}
+
+func (c *clientStreamConn) Write(p []byte) (n int, err error) {
+	written, err := c.writeRequest(p)
+	if written {
+		if err != nil {
+			return
+		}
+		return len(p), nil
+	}
+	return c.StreamWrapper.Write(p)
+}
+
+func (c *clientStreamConn) RemoteAddr() net.Addr {
+	return c.destination.TCPAddr()
+}
+
+func (c *clientStreamConn) Close() error {
+	c.closeOnce.Do(func() {
+		atomic.AddInt32(&c.parent.activeStreams, -1)
+	})
+	return c.StreamWrapper.Close()
+}
+
+func (c *clientStreamConn) Upstream() any {
+	return &c.StreamWrapper
+}
@@ -0,0 +1,29 @@
+package tuic
+
+import (
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	CongestionControlCubic = "cubic"
+	CongestionControlBBR   = "bbr"
+)
+
+func CheckCongestionControl(name string) error {
+	switch name {
+	case "", CongestionControlCubic, CongestionControlBBR:
+		return nil
+	default:
+		return E.New("unknown congestion control: ", name)
+	}
+}
+
+// SetCongestionControl replaces the cubic sender of quic-go if another one is selected.
+func SetCongestionControl(conn quic.Connection, name string) {
+	switch name {
+	case CongestionControlBBR:
+		conn.SetCongestionControl(hysteria.NewBBRSender())
+	}
+}
@@ -0,0 +1,97 @@
+package tuic
+
+import (
+	"sync/atomic"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// PacketConn is a UDP association, relaying packets in datagrams or in unidirectional streams.
+type PacketConn struct {
+	*hysteria.QUICPacketConn
+	conn      quic.Connection
+	assocId   uint16
+	udpStream bool
+	packetId  uint32
+}
+
+func NewPacketConn(conn quic.Connection, assocId uint16, udpStream bool, destination M.Socksaddr, closer func() error) *PacketConn {
+	packetConn := &PacketConn{
+		conn:      conn,
+		assocId:   assocId,
+		udpStream: udpStream,
+	}
+	packetConn.QUICPacketConn = hysteria.NewQUICPacketConn(conn, destination, packetConn.writePacket, closer)
+	return packetConn
+}
+
+// Feed delivers a received packet or fragment to the association.
+func (c *PacketConn) Feed(packet *Packet) {
+	c.FeedFragment(&hysteria.PacketFragment{
+		PacketID:    packet.PacketID,
+		FragID:      packet.FragID,
+		FragCount:   packet.FragTotal,
+		Destination: packet.Destination,
+		Data:        packet.Data,
+	})
+}
+
+func (c *PacketConn) writePacket(data []byte, destination M.Socksaddr) error {
+	packet := Packet{
+		AssocID:     c.assocId,
+		PacketID:    uint16(atomic.AddUint32(&c.packetId, 1)),
+		FragTotal:   1,
+		Destination: destination,
+		Data:        data,
+	}
+	if c.udpStream {
+		return writePacketStream(c.conn, packet)
+	}
+	err := writePacketMessage(c.conn, packet)
+	if errSize, isTooLarge := err.(quic.ErrMessageToLarge); isTooLarge {
+		fragments := FragmentPacket(packet, int(errSize))
+		if fragments == nil {
+			return E.New("packet too large: ", len(data))
+		}
+		for _, fragment := range fragments {
+			err = writePacketMessage(c.conn, fragment)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func writePacketMessage(conn quic.Connection, packet Packet) error {
+	_buffer := buf.StackNewSize(packet.Len())
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	packet.Encode(buffer)
+	return conn.SendMessage(buffer.Bytes())
+}
+
+func writePacketStream(conn quic.Connection, packet Packet) error {
+	stream, err := conn.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	_buffer := buf.StackNewSize(packet.Len())
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	packet.Encode(buffer)
+	_, err = stream.Write(buffer.Bytes())
+	if err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	return stream.Close()
+}
@@ -0,0 +1,290 @@
+package tuic
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/rw"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	Version = 5
+
+	CommandAuthenticate = 0
+	CommandConnect      = 1
+	CommandPacket       = 2
+	CommandDissociate   = 3
+	CommandHeartbeat    = 4
+)
+
+const (
+	UDPRelayModeNative = "native"
+	UDPRelayModeQUIC   = "quic"
+)
+
+const (
+	DefaultALPN        = "h3"
+	DefaultHeartbeat   = 10 * time.Second
+	DefaultAuthTimeout = 3 * time.Second
+
+	authTokenLen = 32
+)
+
+const (
+	addressTypeDomain = 0x00
+	addressTypeIPv4   = 0x01
+	addressTypeIPv6   = 0x02
+	addressTypeNone   = 0xff
+)
+
+var AddressSerializer = M.NewSerializer(
+	M.AddressFamilyByte(addressTypeDomain, M.AddressFamilyFqdn),
+	M.AddressFamilyByte(addressTypeIPv4, M.AddressFamilyIPv4),
+	M.AddressFamilyByte(addressTypeIPv6, M.AddressFamilyIPv6),
+)
+
+// ParseUUID parses the user ID, or maps other strings to a UUID like VMess does.
+func ParseUUID(userId string) ([16]byte, error) {
+	if userId == "" {
+		return uuid.Nil, E.New("missing UUID")
+	}
+	user := uuid.FromStringOrNil(userId)
+	if user == uuid.Nil {
+		user = uuid.NewV5(user, userId)
+	}
+	return user, nil
+}
+
+// AuthToken exports the token of the user from the TLS connection, with the UUID as the label and the password as the
+// context.
+func AuthToken(state tls.ConnectionState, userId [16]byte, password string) ([]byte, error) {
+	return state.ExportKeyingMaterial(string(userId[:]), []byte(password), authTokenLen)
+}
+
+func ReadCommand(reader io.Reader) (byte, error) {
+	version, err := rw.ReadByte(reader)
+	if err != nil {
+		return 0, err
+	}
+	if version != Version {
+		return 0, E.New("unknown version: ", version)
+	}
+	return rw.ReadByte(reader)
+}
+
+func encodeCommand(buffer *buf.Buffer, command byte) {
+	common.Must(
+		buffer.WriteByte(Version),
+		buffer.WriteByte(command),
+	)
+}
+
+func ReadAddress(reader io.Reader) (M.Socksaddr, error) {
+	addressType, err := rw.ReadByte(reader)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	var destination M.Socksaddr
+	switch addressType {
+	case addressTypeNone:
+		return M.Socksaddr{}, nil
+	case addressTypeIPv4:
+		var address [4]byte
+		_, err = io.ReadFull(reader, address[:])
+		destination.Addr = netip.AddrFrom4(address)
+	case addressTypeIPv6:
+		var address [16]byte
+		_, err = io.ReadFull(reader, address[:])
+		destination.Addr = netip.AddrFrom16(address)
+	case addressTypeDomain:
+		var domainLen byte
+		domainLen, err = rw.ReadByte(reader)
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		var domain []byte
+		domain, err = rw.ReadBytes(reader, int(domainLen))
+		destination = M.ParseSocksaddrHostPort(string(domain), 0)
+	default:
+		return M.Socksaddr{}, E.New("unknown address type: ", addressType)
+	}
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &destination.Port)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	return destination, nil
+}
+
+func addressLen(destination M.Socksaddr) int {
+	if !destination.IsValid() {
+		return 1
+	}
+	return AddressSerializer.AddrPortLen(destination)
+}
+
+func encodeAddress(buffer *buf.Buffer, destination M.Socksaddr) {
+	if !destination.IsValid() {
+		common.Must(buffer.WriteByte(addressTypeNone))
+		return
+	}
+	common.Must(AddressSerializer.WriteAddrPort(buffer, destination))
+}
+
+func WriteAuthenticate(writer io.Writer, userId [16]byte, token []byte) error {
+	_buffer := buf.StackNewSize(2 + 16 + authTokenLen)
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	encodeCommand(buffer, CommandAuthenticate)
+	common.Must(
+		common.Error(buffer.Write(userId[:])),
+		common.Error(buffer.Write(token)),
+	)
+	return common.Error(writer.Write(buffer.Bytes()))
+}
+
+// ReadAuthenticate reads the authenticate command after the command header.
+func ReadAuthenticate(reader io.Reader) (userId [16]byte, token []byte, err error) {
+	_, err = io.ReadFull(reader, userId[:])
+	if err != nil {
+		return
+	}
+	token, err = rw.ReadBytes(reader, authTokenLen)
+	return
+}
+
+// WriteConnect writes the connect command with the first payload.
+func WriteConnect(writer io.Writer, destination M.Socksaddr, payload []byte) error {
+	_buffer := buf.StackNewSize(2 + addressLen(destination) + len(payload))
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	encodeCommand(buffer, CommandConnect)
+	encodeAddress(buffer, destination)
+	common.Must1(buffer.Write(payload))
+	return common.Error(writer.Write(buffer.Bytes()))
+}
+
+func WriteDissociate(writer io.Writer, assocId uint16) error {
+	var header [4]byte
+	header[0] = Version
+	header[1] = CommandDissociate
+	binary.BigEndian.PutUint16(header[2:], assocId)
+	return common.Error(writer.Write(header[:]))
+}
+
+// ReadDissociate reads the dissociate command after the command header.
+func ReadDissociate(reader io.Reader) (uint16, error) {
+	var assocId uint16
+	err := binary.Read(reader, binary.BigEndian, &assocId)
+	return assocId, err
+}
+
+func HeartbeatMessage() []byte {
+	return []byte{Version, CommandHeartbeat}
+}
+
+type Packet struct {
+	AssocID     uint16
+	PacketID    uint16
+	FragTotal   uint8
+	FragID      uint8
+	Destination M.Socksaddr
+	Data        []byte
+}
+
+func (p *Packet) HeaderLen() int {
+	return 2 + 8 + addressLen(p.Destination)
+}
+
+func (p *Packet) Len() int {
+	return p.HeaderLen() + len(p.Data)
+}
+
+func (p *Packet) Encode(buffer *buf.Buffer) {
+	encodeCommand(buffer, CommandPacket)
+	common.Must(
+		binary.Write(buffer, binary.BigEndian, p.AssocID),
+		binary.Write(buffer, binary.BigEndian, p.PacketID),
+		buffer.WriteByte(p.FragTotal),
+		buffer.WriteByte(p.FragID),
+		binary.Write(buffer, binary.BigEndian, uint16(len(p.Data))),
+	)
+	encodeAddress(buffer, p.Destination)
+	common.Must1(buffer.Write(p.Data))
+}
+
+// ReadPacket reads the packet command after the command header.
+func ReadPacket(reader io.Reader) (*Packet, error) {
+	var packet Packet
+	var header [8]byte
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return nil, err
+	}
+	packet.AssocID = binary.BigEndian.Uint16(header[0:])
+	packet.PacketID = binary.BigEndian.Uint16(header[2:])
+	packet.FragTotal = header[4]
+	packet.FragID = header[5]
+	size := binary.BigEndian.Uint16(header[6:])
+	if packet.FragTotal == 0 || packet.FragID >= packet.FragTotal {
+		return nil, E.New("invalid fragment ", packet.FragID, "/", packet.FragTotal)
+	}
+	packet.Destination, err = ReadAddress(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read destination")
+	}
+	packet.Data, err = rw.ReadBytes(reader, int(size))
+	if err != nil {
+		return nil, err
+	}
+	return &packet, nil
+}
+
+// FragmentPacket splits the packet to fit the size limit. Only the first fragment has the address.
+func FragmentPacket(packet Packet, maxSize int) []Packet {
+	if packet.Len() <= maxSize {
+		return []Packet{packet}
+	}
+	firstPayloadSize := maxSize - packet.HeaderLen()
+	payloadSize := maxSize - (2 + 8 + 1)
+	if firstPayloadSize <= 0 {
+		return nil
+	}
+	fragTotal := 1 + (len(packet.Data)-firstPayloadSize+payloadSize-1)/payloadSize
+	if fragTotal > 255 {
+		return nil
+	}
+	fragments := make([]Packet, 0, fragTotal)
+	data := packet.Data
+	for fragId := 0; fragId < fragTotal; fragId++ {
+		fragment := packet
+		fragment.FragTotal = uint8(fragTotal)
+		fragment.FragID = uint8(fragId)
+		size := payloadSize
+		if fragId == 0 {
+			size = firstPayloadSize
+		} else {
+			fragment.Destination = M.Socksaddr{}
+		}
+		if size > len(data) {
+			size = len(data)
+		}
+		fragment.Data = data[:size]
+		data = data[size:]
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
@@ -0,0 +1,311 @@
+package tuic
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid"
+)
+
+type Handler interface {
+	N.TCPConnectionHandler
+	N.UDPConnectionHandler
+	E.Handler
+}
+
+type serviceUser[T comparable] struct {
+	user     T
+	password string
+}
+
+type Service[T comparable] struct {
+	users       map[[16]byte]serviceUser[T]
+	authTimeout time.Duration
+	handler     Handler
+}
+
+func NewService[T comparable](handler Handler, authTimeout time.Duration) *Service[T] {
+	if authTimeout == 0 {
+		authTimeout = DefaultAuthTimeout
+	}
+	return &Service[T]{
+		authTimeout: authTimeout,
+		handler:     handler,
+	}
+}
+
+func (s *Service[T]) UpdateUsers(userList []T, userIdList []string, passwordList []string) error {
+	users := make(map[[16]byte]serviceUser[T])
+	for i, user := range userList {
+		key, err := ParseUUID(userIdList[i])
+		if err != nil {
+			return err
+		}
+		if _, loaded := users[key]; loaded {
+			return E.New("duplicate UUID: ", userIdList[i])
+		}
+		users[key] = serviceUser[T]{user, passwordList[i]}
+	}
+	s.users = users
+	return nil
+}
+
+// NewConnection serves the QUIC connection until it is closed.
+func (s *Service[T]) NewConnection(ctx context.Context, conn quic.Connection) error {
+	session := &serverSession[T]{
+		Service:       s,
+		ctx:           ctx,
+		conn:          conn,
+		source:        M.SocksaddrFromNet(conn.RemoteAddr()),
+		authenticated: make(chan struct{}),
+		udpConns:      make(map[uint16]*PacketConn),
+	}
+	go session.loopUniStreams()
+	go session.loopMessages()
+	go session.loopStreams()
+	select {
+	case <-session.authenticated:
+	case <-conn.Context().Done():
+	case <-time.After(s.authTimeout):
+		conn.CloseWithError(0, "")
+		return E.New("authentication timeout")
+	}
+	<-conn.Context().Done()
+	session.closeUDP()
+	return nil
+}
+
+type serverSession[T comparable] struct {
+	*Service[T]
+	ctx           context.Context
+	conn          quic.Connection
+	source        M.Socksaddr
+	authAccess    sync.Mutex
+	authenticated chan struct{}
+	authUser      T
+	udpAccess     sync.Mutex
+	udpConns      map[uint16]*PacketConn
+}
+
+func (s *serverSession[T]) waitAuth() bool {
+	select {
+	case <-s.authenticated:
+		return true
+	case <-s.conn.Context().Done():
+		return false
+	}
+}
+
+func (s *serverSession[T]) userContext() context.Context {
+	return auth.ContextWithUser(s.ctx, s.authUser)
+}
+
+func (s *serverSession[T]) loopUniStreams() {
+	for {
+		stream, err := s.conn.AcceptUniStream(s.ctx)
+		if err != nil {
+			return
+		}
+		go func() {
+			hErr := s.handleUniStream(stream)
+			stream.CancelRead(0)
+			if hErr != nil {
+				s.handler.NewError(s.ctx, E.Cause(hErr, "process unidirectional stream from ", s.source))
+			}
+		}()
+	}
+}
+
+func (s *serverSession[T]) handleUniStream(stream quic.ReceiveStream) error {
+	command, err := ReadCommand(stream)
+	if err != nil {
+		return err
+	}
+	switch command {
+	case CommandAuthenticate:
+		return s.handleAuthenticate(stream)
+	case CommandPacket:
+		var packet *Packet
+		packet, err = ReadPacket(stream)
+		if err != nil {
+			return err
+		}
+		if !s.waitAuth() {
+			return nil
+		}
+		s.handlePacket(packet, true)
+		return nil
+	case CommandDissociate:
+		var assocId uint16
+		assocId, err = ReadDissociate(stream)
+		if err != nil {
+			return err
+		}
+		if !s.waitAuth() {
+			return nil
+		}
+		s.udpAccess.Lock()
+		udpConn, loaded := s.udpConns[assocId]
+		s.udpAccess.Unlock()
+		if loaded {
+			udpConn.Close()
+		}
+		return nil
+	default:
+		return E.New("unknown command on unidirectional stream: ", command)
+	}
+}
+
+func (s *serverSession[T]) handleAuthenticate(stream quic.ReceiveStream) error {
+	userId, token, err := ReadAuthenticate(stream)
+	if err != nil {
+		return err
+	}
+	user, loaded := s.users[userId]
+	if !loaded {
+		s.conn.CloseWithError(0, "")
+		return E.New("unknown UUID: ", uuid.UUID(userId))
+	}
+	if earlyConn, isEarlyConn := s.conn.(quic.EarlyConnection); isEarlyConn {
+		<-earlyConn.HandshakeComplete().Done()
+	}
+	expectedToken, err := AuthToken(s.conn.ConnectionState().TLS.ConnectionState, userId, user.password)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(token, expectedToken) != 1 {
+		s.conn.CloseWithError(0, "")
+		return E.New("wrong password for user ", uuid.UUID(userId))
+	}
+	s.authAccess.Lock()
+	defer s.authAccess.Unlock()
+	select {
+	case <-s.authenticated:
+	default:
+		s.authUser = user.user
+		close(s.authenticated)
+	}
+	return nil
+}
+
+func (s *serverSession[T]) loopMessages() {
+	for {
+		message, err := s.conn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		reader := bytes.NewReader(message)
+		command, err := ReadCommand(reader)
+		if err != nil {
+			s.handler.NewError(s.ctx, E.Cause(err, "read message from ", s.source))
+			continue
+		}
+		switch command {
+		case CommandPacket:
+			var packet *Packet
+			packet, err = ReadPacket(reader)
+			if err != nil {
+				s.handler.NewError(s.ctx, E.Cause(err, "read packet from ", s.source))
+				continue
+			}
+			if !s.waitAuth() {
+				return
+			}
+			s.handlePacket(packet, false)
+		case CommandHeartbeat:
+		default:
+			s.handler.NewError(s.ctx, E.New("unknown command in message from ", s.source, ": ", command))
+		}
+	}
+}
+
+func (s *serverSession[T]) handlePacket(packet *Packet, udpStream bool) {
+	s.udpAccess.Lock()
+	udpConn, loaded := s.udpConns[packet.AssocID]
+	if !loaded {
+		assocId := packet.AssocID
+		udpConn = NewPacketConn(s.conn, assocId, udpStream, packet.Destination, func() error {
+			s.udpAccess.Lock()
+			delete(s.udpConns, assocId)
+			s.udpAccess.Unlock()
+			return nil
+		})
+		s.udpConns[assocId] = udpConn
+	}
+	s.udpAccess.Unlock()
+	udpConn.Feed(packet)
+	if !loaded {
+		go func() {
+			hErr := s.handler.NewPacketConnection(s.userContext(), udpConn, M.Metadata{
+				Protocol:    "tuic",
+				Source:      s.source,
+				Destination: packet.Destination,
+			})
+			udpConn.Close()
+			if hErr != nil {
+				s.handler.NewError(s.ctx, hErr)
+			}
+		}()
+	}
+}
+
+func (s *serverSession[T]) loopStreams() {
+	for {
+		stream, err := s.conn.AcceptStream(s.ctx)
+		if err != nil {
+			return
+		}
+		go func() {
+			hErr := s.handleStream(stream)
+			if hErr != nil {
+				stream.CancelRead(0)
+				stream.Close()
+				s.handler.NewError(s.ctx, E.Cause(hErr, "process stream from ", s.source))
+			}
+		}()
+	}
+}
+
+func (s *serverSession[T]) handleStream(stream quic.Stream) error {
+	command, err := ReadCommand(stream)
+	if err != nil {
+		return err
+	}
+	if command != CommandConnect {
+		return E.New("unknown command on stream: ", command)
+	}
+	destination, err := ReadAddress(stream)
+	if err != nil {
+		return E.Cause(err, "read destination")
+	}
+	if !s.waitAuth() {
+		return nil
+	}
+	return s.handler.NewConnection(s.userContext(), &hysteria.StreamWrapper{Conn: s.conn, Stream: stream}, M.Metadata{
+		Protocol:    "tuic",
+		Source:      s.source,
+		Destination: destination,
+	})
+}
+
+func (s *serverSession[T]) closeUDP() {
+	s.udpAccess.Lock()
+	udpConns := make([]*PacketConn, 0, len(s.udpConns))
+	for _, udpConn := range s.udpConns {
+		udpConns = append(udpConns, udpConn)
+	}
+	s.udpAccess.Unlock()
+	for _, udpConn := range udpConns {
+		udpConn.Close()
+	}
+}
@@ -0,0 +1,177 @@
+package vless
+
+import (
+	"net"
+	"sync"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/gofrs/uuid"
+)
+
+type Client struct {
+	key [16]byte
+}
+
+func NewClient(userId string) (*Client, error) {
+	key, err := ParseUUID(userId)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{key}, nil
+}
+
+// ParseUUID parses the user ID, or maps other strings to a UUID like VMess does.
+func ParseUUID(userId string) ([16]byte, error) {
+	if userId == "" {
+		return uuid.Nil, E.New("missing UUID")
+	}
+	user := uuid.FromStringOrNil(userId)
+	if user == uuid.Nil {
+		user = uuid.NewV5(user, userId)
+	}
+	return user, nil
+}
+
+// DialEarlyConn returns a connection sending the request with the first payload.
+func (c *Client) DialEarlyConn(conn net.Conn, destination M.Socksaddr) *Conn {
+	return &Conn{
+		Conn: conn,
+		request: Request{
+			UUID:        c.key,
+			Command:     CommandTCP,
+			Destination: destination,
+		},
+	}
+}
+
+// DialEarlyPacketConn returns a connection sending packets to the destination in a stream.
+func (c *Client) DialEarlyPacketConn(conn net.Conn, destination M.Socksaddr) *PacketConn {
+	return &PacketConn{
+		Conn: Conn{
+			Conn: conn,
+			request: Request{
+				UUID:        c.key,
+				Command:     CommandUDP,
+				Destination: destination,
+			},
+		},
+	}
+}
+
+type Conn struct {
+	net.Conn
+	request        Request
+	access         sync.Mutex
+	requestWritten bool
+	responseRead   bool
+}
+
+func (c *Conn) writeRequest(payload []byte) (bool, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.requestWritten {
+		return false, nil
+	}
+	c.requestWritten = true
+	return true, WriteRequest(c.Conn, c.request, payload)
+}
+
+func (c *Conn) Read(p []byte) (n int, err error) {
+	if !c.responseRead {
+		_, err = c.writeRequest(nil)
+		if err != nil {
+			return
+		}
+		err = ReadResponse(c.Conn)
+		if err != nil {
+			return 0, E.Cause(err, "read response")
+		}
+		c.responseRead = true
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *Conn) Write(p []byte) (n int, err error) {
+	written, err := c.writeRequest(p)
+	if written {
+		if err != nil {
+			return
+		}
+		return len(p), nil
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *Conn) Upstream() any {
+	return c.Conn
+}
+
+type PacketConn struct {
+	Conn
+}
+
+func (c *PacketConn) ReadPacket(buffer *buf.Buffer) (M.Socksaddr, error) {
+	if !c.responseRead {
+		_, err := c.writeRequest(nil)
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		err = ReadResponse(c.Conn.Conn)
+		if err != nil {
+			return M.Socksaddr{}, E.Cause(err, "read response")
+		}
+		c.responseRead = true
+	}
+	err := ReadPacket(c.Conn.Conn, buffer)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	return c.request.Destination, nil
+}
+
+func (c *PacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	EncodePacket(buffer)
+	written, err := c.writeRequest(buffer.Bytes())
+	if written {
+		return err
+	}
+	return common.Error(c.Conn.Conn.Write(buffer.Bytes()))
+}
+
+func (c *PacketConn) Read(p []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(p)
+	return
+}
+
+func (c *PacketConn) Write(p []byte) (n int, err error) {
+	return c.WriteTo(p, c.request.Destination)
+}
+
+func (c *PacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	buffer := buf.With(p)
+	destination, err := c.ReadPacket(buffer)
+	if err != nil {
+		return
+	}
+	n = buffer.Len()
+	addr = destination.UDPAddr()
+	return
+}
+
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	return bufio.WritePacket(c, p, addr)
+}
+
+func (c *PacketConn) FrontHeadroom() int {
+	return 2
+}
+
+func (c *PacketConn) RemoteAddr() net.Addr {
+	return c.request.Destination.UDPAddr()
+}
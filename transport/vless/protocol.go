@@ -0,0 +1,139 @@
+package vless
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/sagernet/sing-vmess"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/rw"
+)
+
+const (
+	Version    = 0
+	CommandTCP = 1
+	CommandUDP = 2
+	CommandMux = 3
+)
+
+// AddressSerializer is the VMess address format, with the port before the address.
+var AddressSerializer = vmess.AddressSerializer
+
+type Request struct {
+	UUID        [16]byte
+	Command     byte
+	Destination M.Socksaddr
+}
+
+func ReadRequest(reader io.Reader) (*Request, error) {
+	var request Request
+	version, err := rw.ReadByte(reader)
+	if err != nil {
+		return nil, err
+	}
+	if version != Version {
+		return nil, E.New("unknown version: ", version)
+	}
+	_, err = io.ReadFull(reader, request.UUID[:])
+	if err != nil {
+		return nil, err
+	}
+	addonsLen, err := rw.ReadByte(reader)
+	if err != nil {
+		return nil, err
+	}
+	if addonsLen > 0 {
+		addons, err := rw.ReadBytes(reader, int(addonsLen))
+		if err != nil {
+			return nil, err
+		}
+		// addons only carry the XTLS flow as protobuf field 1, which is not supported
+		if len(addons) > 1 && addons[0] == 0x0a && addons[1] > 0 {
+			return nil, E.New("flow is not supported")
+		}
+	}
+	request.Command, err = rw.ReadByte(reader)
+	if err != nil {
+		return nil, err
+	}
+	switch request.Command {
+	case CommandTCP, CommandUDP:
+		request.Destination, err = AddressSerializer.ReadAddrPort(reader)
+		if err != nil {
+			return nil, E.Cause(err, "read destination")
+		}
+	case CommandMux:
+		return nil, E.New("mux.cool is not supported")
+	default:
+		return nil, E.New("unknown command: ", request.Command)
+	}
+	return &request, nil
+}
+
+func RequestLen(request Request) int {
+	return 19 + AddressSerializer.AddrPortLen(request.Destination)
+}
+
+func WriteRequest(writer io.Writer, request Request, payload []byte) error {
+	_buffer := buf.StackNewSize(RequestLen(request) + len(payload))
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	EncodeRequest(buffer, request)
+	common.Must1(buffer.Write(payload))
+	return common.Error(writer.Write(buffer.Bytes()))
+}
+
+func EncodeRequest(buffer *buf.Buffer, request Request) {
+	common.Must(
+		buffer.WriteByte(Version),
+		common.Error(buffer.Write(request.UUID[:])),
+		buffer.WriteByte(0),
+		buffer.WriteByte(request.Command),
+		AddressSerializer.WriteAddrPort(buffer, request.Destination),
+	)
+}
+
+func ReadResponse(reader io.Reader) error {
+	version, err := rw.ReadByte(reader)
+	if err != nil {
+		return err
+	}
+	if version != Version {
+		return E.New("unknown version: ", version)
+	}
+	addonsLen, err := rw.ReadByte(reader)
+	if err != nil {
+		return err
+	}
+	if addonsLen > 0 {
+		err = rw.SkipN(reader, int(addonsLen))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPacket reads a length-prefixed packet of a UDP stream.
+func ReadPacket(reader io.Reader, buffer *buf.Buffer) error {
+	var length uint16
+	err := binary.Read(reader, binary.BigEndian, &length)
+	if err != nil {
+		return err
+	}
+	if buffer.FreeLen() < int(length) {
+		return io.ErrShortBuffer
+	}
+	_, err = buffer.ReadFullFrom(reader, int(length))
+	return err
+}
+
+// EncodePacket prepends the length to the packet, in the headroom of the buffer.
+func EncodePacket(buffer *buf.Buffer) {
+	length := buffer.Len()
+	binary.BigEndian.PutUint16(buffer.ExtendHeader(2), uint16(length))
+}
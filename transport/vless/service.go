@@ -0,0 +1,145 @@
+package vless
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid"
+)
+
+type Handler interface {
+	N.TCPConnectionHandler
+	N.UDPConnectionHandler
+}
+
+type Service[T comparable] struct {
+	users   map[[16]byte]T
+	handler Handler
+}
+
+func NewService[T comparable](handler Handler) *Service[T] {
+	return &Service[T]{
+		handler: handler,
+	}
+}
+
+func (s *Service[T]) UpdateUsers(userList []T, userIdList []string) error {
+	users := make(map[[16]byte]T)
+	for i, user := range userList {
+		key, err := ParseUUID(userIdList[i])
+		if err != nil {
+			return err
+		}
+		if _, loaded := users[key]; loaded {
+			return E.New("duplicate UUID: ", userIdList[i])
+		}
+		users[key] = user
+	}
+	s.users = users
+	return nil
+}
+
+func (s *Service[T]) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
+	request, err := ReadRequest(conn)
+	if err != nil {
+		return err
+	}
+	user, loaded := s.users[request.UUID]
+	if !loaded {
+		return E.New("unknown UUID: ", uuid.UUID(request.UUID))
+	}
+	ctx = auth.ContextWithUser(ctx, user)
+	metadata.Protocol = "vless"
+	metadata.Destination = request.Destination
+	if request.Command == CommandTCP {
+		return s.handler.NewConnection(ctx, &serverConn{Conn: conn}, metadata)
+	} else {
+		return s.handler.NewPacketConnection(ctx, &serverPacketConn{serverConn{Conn: conn}, request.Destination}, metadata)
+	}
+}
+
+// serverConn writes the response with the first payload.
+type serverConn struct {
+	net.Conn
+	responseWritten bool
+}
+
+func (c *serverConn) Write(p []byte) (n int, err error) {
+	if c.responseWritten {
+		return c.Conn.Write(p)
+	}
+	_buffer := buf.StackNewSize(2 + len(p))
+	defer common.KeepAlive(_buffer)
+	buffer := common.Dup(_buffer)
+	defer buffer.Release()
+	common.Must(
+		buffer.WriteByte(Version),
+		buffer.WriteByte(0),
+	)
+	common.Must1(buffer.Write(p))
+	_, err = c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+	c.responseWritten = true
+	return len(p), nil
+}
+
+func (c *serverConn) Upstream() any {
+	return c.Conn
+}
+
+type serverPacketConn struct {
+	serverConn
+	destination M.Socksaddr
+}
+
+func (c *serverPacketConn) ReadPacket(buffer *buf.Buffer) (M.Socksaddr, error) {
+	err := ReadPacket(c.Conn, buffer)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	return c.destination, nil
+}
+
+func (c *serverPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	EncodePacket(buffer)
+	return common.Error(c.serverConn.Write(buffer.Bytes()))
+}
+
+func (c *serverPacketConn) Read(p []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(p)
+	return
+}
+
+func (c *serverPacketConn) Write(p []byte) (n int, err error) {
+	return c.WriteTo(p, c.destination)
+}
+
+func (c *serverPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	buffer := buf.With(p)
+	destination, err := c.ReadPacket(buffer)
+	if err != nil {
+		return
+	}
+	n = buffer.Len()
+	addr = destination.UDPAddr()
+	return
+}
+
+func (c *serverPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	return bufio.WritePacket(c, p, addr)
+}
+
+func (c *serverPacketConn) FrontHeadroom() int {
+	return 2
+}